@@ -0,0 +1,73 @@
+// Command arbo-migrate rewrites the arbo items stored before numbers were stored as such, turning their
+// numeric string attributes into N attributes, so that they can be read as numbers and queried.
+//
+//	go run ./cmd/arbo-migrate -dry
+//	go run ./cmd/arbo-migrate
+//
+// It is safe to run again: items already holding numbers are left alone.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/ptr"
+	log "github.com/sirupsen/logrus"
+	"plumbus/pkg/model/arbo"
+	"plumbus/pkg/repo"
+	"strings"
+)
+
+func main() {
+
+	dry := flag.Bool("dry", false, "report the items to rewrite without writing them")
+	tables := flag.String("tables", arbo.Table+","+arbo.DailyTable, "comma separated tables to migrate")
+	flag.Parse()
+
+	ctx := context.Background()
+	db := repo.NewDynamo()
+
+	for _, t := range strings.Split(*tables, ",") {
+		scanned, changed, err := migrate(ctx, db, strings.TrimSpace(t), *dry)
+		fields := log.Fields{"table": t, "scanned": scanned, "changed": changed, "dry": *dry}
+		if err != nil {
+			log.WithFields(fields).WithError(err).Fatal("migration failed")
+		}
+		log.WithFields(fields).Info("migrated")
+	}
+}
+
+// migrate rewrites the items of a table holding numeric strings, a page at a time, unless this is a dry run.
+func migrate(ctx context.Context, db repo.Store, table string, dry bool) (scanned, changed int, err error) {
+
+	var werr error
+	err = db.ScanPages(ctx, &dynamodb.ScanInput{TableName: ptr.String(table)}, func(items []map[string]types.AttributeValue, _ map[string]types.AttributeValue) bool {
+
+		var rr []types.WriteRequest
+		for _, item := range items {
+			scanned++
+			out, ok, invalid := arbo.Migrate(item)
+			if !ok {
+				continue
+			}
+			if len(invalid) > 0 {
+				log.WithFields(log.Fields{"table": table, "item": item["ID"], "attributes": invalid}).Warn("not a number, stored as NULL")
+			}
+			changed++
+			rr = append(rr, types.WriteRequest{PutRequest: &types.PutRequest{Item: out}})
+		}
+
+		if dry || len(rr) == 0 {
+			return true
+		}
+
+		werr = db.BatchWrite(ctx, table, rr)
+		return werr == nil
+	})
+
+	if err == nil {
+		err = werr
+	}
+	return
+}
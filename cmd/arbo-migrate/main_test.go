@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/ptr"
+	"plumbus/pkg/model/arbo"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/nums"
+	"plumbus/test"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+
+	db := repo.NewMemory(arbo.Schema)
+
+	legacy := map[string]types.AttributeValue{
+		"ID":      &types.AttributeValueMemberS{Value: "1"},
+		"Named":   &types.AttributeValueMemberS{Value: "one"},
+		"Spend":   &types.AttributeValueMemberS{Value: "12.5"},
+		"Revenue": &types.AttributeValueMemberS{Value: ""},
+		"Clicks":  &types.AttributeValueMemberS{Value: "n/a"},
+		"ROI":     &types.AttributeValueMemberNULL{Value: true},
+		"sTime":   &types.AttributeValueMemberS{Value: "2022-01-21 18:00:00"},
+	}
+	current := (&arbo.Entity{ID: "2", Spend: nums.N(3)}).WriteRequest().PutRequest.Item
+
+	for _, item := range []map[string]types.AttributeValue{legacy, current} {
+		if err := db.Put(test.CTX, &dynamodb.PutItemInput{TableName: ptr.String(arbo.Table), Item: item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if scanned, changed, err := migrate(test.CTX, db, arbo.Table, true); err != nil || scanned != 2 || changed != 1 {
+		t.Fatal("unexpected dry run ", scanned, changed, err)
+	}
+
+	var e arbo.Entity
+	if err := db.Get(test.CTX, arbo.Table, "ID", "1", &e); err != nil || e.Spend != nums.N(12.5) || e.Clicks.Valid {
+		t.Error("expected legacy strings to still be read as numbers ", e.Spend, e.Clicks, err)
+	}
+
+	if _, changed, err := migrate(test.CTX, db, arbo.Table, false); err != nil || changed != 1 {
+		t.Fatal("unexpected migration ", changed, err)
+	}
+
+	var item map[string]types.AttributeValue
+	out, _ := db.Query(test.CTX, &dynamodb.QueryInput{
+		TableName:                 ptr.String(arbo.Table),
+		KeyConditionExpression:    ptr.String("ID = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":id": &types.AttributeValueMemberS{Value: "1"}},
+	})
+	if len(out.Items) == 1 {
+		item = out.Items[0]
+	}
+
+	if n, ok := item["Spend"].(*types.AttributeValueMemberN); !ok || n.Value != "12.5" {
+		t.Error("expected an N attribute ", item["Spend"])
+	}
+	for _, k := range []string{"Revenue", "Clicks"} {
+		if _, ok := item[k].(*types.AttributeValueMemberNULL); !ok {
+			t.Error("expected ", k, " to be NULL ", item[k])
+		}
+	}
+	if s, ok := item["sTime"].(*types.AttributeValueMemberS); !ok || s.Value != "2022-01-21 18:00:00" {
+		t.Error("expected other attributes to be left alone ", item["sTime"])
+	}
+
+	if _, changed, err := migrate(test.CTX, db, arbo.Table, false); err != nil || changed != 0 {
+		t.Error("expected a second run to change nothing ", changed, err)
+	}
+}
@@ -3,6 +3,7 @@ module plumbus
 go 1.17
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/aws/aws-lambda-go v1.27.1
 	github.com/aws/aws-sdk-go-v2 v1.11.2
 	github.com/aws/aws-sdk-go-v2/config v1.11.0
@@ -10,8 +11,10 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.10.0
 	github.com/aws/aws-sdk-go-v2/service/lambda v1.15.0
 	github.com/aws/smithy-go v1.9.0
+	github.com/google/uuid v1.3.0
 	github.com/leekchan/accounting v1.0.0
 	github.com/rs/zerolog v1.26.1
+	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/objx v0.3.0
 	github.com/uris77/auth0 v0.0.0-20200303040845-37c0873555b7
@@ -31,10 +34,8 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sso v1.6.2 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.11.1 // indirect
 	github.com/cockroachdb/apd v1.1.0 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/lestrrat-go/jwx v0.9.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24 // indirect
 	golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e // indirect
 )
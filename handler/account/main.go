@@ -7,6 +7,7 @@ import (
 	"errors"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	faas "github.com/aws/aws-sdk-go-v2/service/lambda"
@@ -28,8 +29,12 @@ import (
 
 var posRegexp = regexp.MustCompile(`all|in|fam`)
 
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
 func init() {
 	logs.Init()
+	db = repo.NewDynamo()
 }
 
 func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -38,7 +43,10 @@ func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.API
 	case http.MethodOptions:
 		return api.K()
 	case http.MethodGet:
-		return get(ctx, req.QueryStringParameters["pos"])
+		if _, ok := req.QueryStringParameters["token"]; ok {
+			return token(ctx)
+		}
+		return get(ctx, req.QueryStringParameters["pos"], req.QueryStringParameters["limit"], req.QueryStringParameters["cursor"])
 	case http.MethodPut:
 		return put(ctx)
 	case http.MethodPatch:
@@ -60,36 +68,59 @@ func post(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
 		FilterExpression: ptr.String("Included = :v1"),
 	}
 
-	var aa []account.Entity
-	if err := repo.Scan(ctx, &in, &aa); err != nil {
-		return api.Err(err)
-	}
-
 	var wg sync.WaitGroup
+	var err error
 
-	for _, a := range aa {
-		wg.Add(1)
-		go func(a account.Entity) {
-			defer wg.Done()
-			var data = sam.NewRequestBytes(http.MethodPut, map[string]string{"accountID": a.ID})
-			if _, err := sam.NewEvent(ctx, campaign.Handler, data); err != nil {
-				log.WithError(err).Error("invoking request response to post data for campaigns with account ", a.ID)
-			}
-		}(a)
+	// accounts are streamed a page at a time
+	// so that large tables are never held
+	// in memory all at once.
+	if e := db.ScanPages(ctx, &in, func(items []map[string]types.AttributeValue, _ map[string]types.AttributeValue) bool {
+
+		var aa []account.Entity
+		if err = attributevalue.UnmarshalListOfMaps(items, &aa); err != nil {
+			return false
+		}
+
+		for _, a := range aa {
+			wg.Add(1)
+			go func(a account.Entity) {
+				defer wg.Done()
+				var data = sam.NewRequestBytes(http.MethodPut, map[string]string{"accountID": a.ID})
+				if _, err := sam.NewEvent(ctx, campaign.Handler, data); err != nil {
+					log.WithError(err).Error("invoking request response to post data for campaigns with account ", a.ID)
+				}
+			}(a)
+		}
+
+		return true
+	}); e != nil {
+		err = e
 	}
 
 	wg.Wait()
 
+	if err != nil {
+		return api.Err(err)
+	}
+
 	return api.K()
 }
 
 // get scans the db for all accounts where the included value is true, false, or either.
-func get(ctx context.Context, pos string) (events.APIGatewayV2HTTPResponse, error) {
+// When a limit is given, a single page of accounts is returned along with the cursor of the next page. Included
+// accounts are filtered within the scan, which evaluates limit accounts before filtering them, so a page may hold
+// fewer accounts than the limit, or none, before the last page. Accounts are sorted by name within a page only.
+func get(ctx context.Context, pos, limit, cursor string) (events.APIGatewayV2HTTPResponse, error) {
 
 	if !posRegexp.MatchString(pos) {
 		return api.Err(errors.New("unknown pos: " + pos))
 	}
 
+	size, err := api.Limit(limit)
+	if err != nil {
+		return api.Err(err)
+	}
+
 	in := dynamodb.ScanInput{TableName: ptr.String(account.Table)}
 	if pos != "all" {
 		in.FilterExpression = ptr.String("Included = :v1")
@@ -98,13 +129,25 @@ func get(ctx context.Context, pos string) (events.APIGatewayV2HTTPResponse, erro
 		}
 	}
 
+	var next string
 	var aa []account.Entity
-	if err := repo.Scan(ctx, &in, &aa); err != nil {
+	if size > 0 {
+		next, err = repo.ScanPage(ctx, db, &in, size, cursor, &aa)
+	} else {
+		err = db.Scan(ctx, &in, &aa)
+	}
+
+	if err != nil {
 		return api.Err(err)
 	}
 
+	// a scan returns accounts in no particular order, and a page cannot be sorted against the pages around it.
 	sort.Sort(account.ByName(aa))
 
+	if size > 0 && pos == "all" {
+		return api.Page(&aa, next)
+	}
+
 	if pos == "all" {
 		if bytes, err := json.Marshal(&aa); err != nil {
 			return api.Err(err)
@@ -172,6 +215,10 @@ func get(ctx context.Context, pos string) (events.APIGatewayV2HTTPResponse, erro
 
 	wg.Wait()
 
+	if size > 0 {
+		return api.Page(&aa, next)
+	}
+
 	return api.JSON(&aa)
 }
 
@@ -194,7 +241,7 @@ func put(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
 	var x account.Entity
 	for _, a := range aa {
 
-		if err = repo.Get(ctx, account.Table, "ID", a.ID, &x); err != nil {
+		if err = db.Get(ctx, account.Table, "ID", a.ID, &x); err != nil {
 			return api.Err(err)
 		}
 
@@ -202,12 +249,31 @@ func put(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
 			continue
 		}
 
-		if err = repo.Put(ctx, x.PutItemInput()); err != nil {
+		if err = db.Put(ctx, x.PutItemInput()); err != nil {
 			return api.Err(err)
 		}
 	}
 
-	return get(ctx, "all")
+	return get(ctx, "all", "", "")
+}
+
+// token returns the health of the facebook token, including when it expires and a warning when it needs attention.
+func token(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+
+	data, _ := json.Marshal(map[string]string{"node": "token"})
+	out, err := sam.NewReqRes(ctx, fb.Handler, data)
+	if err != nil {
+		return api.Err(err)
+	} else if err = fb.Err(out); err != nil {
+		return api.Err(err)
+	}
+
+	var s fb.TokenStatus
+	if err = json.Unmarshal(out.Payload, &s); err != nil {
+		return api.Err(err)
+	}
+
+	return api.JSON(s)
 }
 
 // patch will toggle account inclusion.
@@ -216,12 +282,12 @@ func put(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
 func patch(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
 
 	var x account.Entity
-	if err := repo.Get(ctx, account.Table, "ID", id, &x); err != nil {
+	if err := db.Get(ctx, account.Table, "ID", id, &x); err != nil {
 		return api.Err(err)
 	}
 
 	x.Included = !x.Included
-	if err := repo.Put(ctx, x.PutItemInput()); err != nil {
+	if err := db.Put(ctx, x.PutItemInput()); err != nil {
 		return api.Err(err)
 	}
 
@@ -1,27 +1,59 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
 	"net/http"
+	"plumbus/pkg/model/account"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
+	"plumbus/pkg/repo"
 	"plumbus/pkg/sam"
 	"plumbus/test"
+	"sort"
+	"strings"
 	"testing"
 )
 
-//func TestHandleGetAll(t *testing.T) {
-//	req := sam.NewRequest(http.MethodGet, map[string]string{"pos": "all"})
-//	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-//		t.Error(res.StatusCode, res.Body)
-//	}
-//}
-//
-//func TestHandleGetIn(t *testing.T) {
-//	req := sam.NewRequest(http.MethodGet, map[string]string{"pos": "in"})
-//	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-//		t.Error(res.StatusCode, res.Body)
-//	}
-//}
+// seed replaces the handler store with memory holding a single excluded account.
+func seed(t *testing.T) {
+	db = repo.NewMemory(account.Schema)
+	a := account.Entity{ID: "302191798223982", Named: "test account", Created: "2021-12-01T10:00:00-0500", Stated: 1}
+	if err := db.Put(test.CTX, a.PutItemInput()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleGetAll(t *testing.T) {
+	seed(t)
+	req := sam.NewRequest(http.MethodGet, map[string]string{"pos": "all"})
+	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
+		t.Error(res.StatusCode, res.Body)
+	} else {
+		var aa []map[string]interface{}
+		if err := json.Unmarshal([]byte(res.Body), &aa); err != nil || len(aa) != 1 {
+			t.Error(err, res.Body)
+		}
+	}
+}
+
+func TestHandleGetIn(t *testing.T) {
+	seed(t)
+	req := sam.NewRequest(http.MethodGet, map[string]string{"pos": "in"})
+	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
+		t.Error(res.StatusCode, res.Body)
+	} else {
+		var aa []map[string]interface{}
+		if err := json.Unmarshal([]byte(res.Body), &aa); err != nil || len(aa) != 0 {
+			t.Error("expected no included accounts", err, res.Body)
+		}
+	}
+}
 
 func TestHandlePost(t *testing.T) {
+	seed(t)
 	req := sam.NewRequest(http.MethodPost, nil)
 	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
 		t.Error(res.StatusCode, res.Body)
@@ -34,17 +66,78 @@ func TestHandlePost(t *testing.T) {
 //		t.Error(res.StatusCode, res.Body)
 //	}
 //}
-//
-//func TestHandlePatch(t *testing.T) {
-//	req := sam.NewRequest(http.MethodPatch, map[string]string{"id": "302191798223982"})
-//	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-//		t.Error(res.StatusCode, res.Body)
-//	} else {
-//		pretty.Print(res.Body)
-//	}
-//	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-//		t.Error(res.StatusCode, res.Body)
-//	} else {
-//		pretty.Print(res.Body)
-//	}
-//}
+
+func TestHandlePatch(t *testing.T) {
+	seed(t)
+	req := sam.NewRequest(http.MethodPatch, map[string]string{"id": "302191798223982"})
+	for _, want := range []bool{true, false} {
+		if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
+			t.Error(res.StatusCode, res.Body)
+		}
+		var a account.Entity
+		if err := db.Get(test.CTX, account.Table, "ID", "302191798223982", &a); err != nil {
+			t.Fatal(err)
+		} else if a.Included != want {
+			t.Error("expected included ", want)
+		}
+	}
+}
+
+func TestHandleGetToken(t *testing.T) {
+
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		var req map[string]string
+		if _ = json.Unmarshal(payload, &req); req["node"] != "token" {
+			return test.Error(errors.New("unexpected node " + req["node"]))
+		}
+		return []byte(`{"valid":true,"warning":"the token expires in 48h0m0s"}`)
+	}})
+
+	var s fb.TokenStatus
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"token": ""}))
+	if _ = json.Unmarshal([]byte(res.Body), &s); res.StatusCode != http.StatusOK || !s.Valid || s.Warning == "" {
+		t.Error(res.StatusCode, res.Body)
+	}
+}
+
+func TestHandleGetInPages(t *testing.T) {
+
+	db = repo.NewMemory(account.Schema)
+	for i, n := range []string{"delta", "alpha", "echo", "charlie", "bravo", "foxtrot", "golf"} {
+		a := account.Entity{ID: fmt.Sprint(100 + i), Named: n, Included: i%2 == 0}
+		if err := db.Put(test.CTX, a.PutItemInput()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sam.Use(test.Lambdas{campaign.Handler: test.API(func(events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+		return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusNotFound}
+	})})
+
+	var got []string
+	par := map[string]string{"pos": "in", "limit": "3"}
+	for pages := 0; pages < 10; pages++ {
+		res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, par))
+		var page struct {
+			Data   []account.Entity `json:"data"`
+			Cursor string           `json:"cursor"`
+		}
+		if err := json.Unmarshal([]byte(res.Body), &page); err != nil {
+			t.Fatal(err, res.Body)
+		}
+		if !sort.IsSorted(account.ByName(page.Data)) {
+			t.Error("expected a page sorted by name ", res.Body)
+		}
+		for _, a := range page.Data {
+			got = append(got, a.Named)
+		}
+		if page.Cursor == "" {
+			break
+		}
+		par["cursor"] = page.Cursor
+	}
+
+	if sort.Strings(got); strings.Join(got, ",") != "bravo,delta,echo,golf" {
+		t.Error("expected every included account and no other across pages ", got)
+	}
+}
@@ -0,0 +1,52 @@
+// Package provides functionality for refreshing, returning and updating the ads of an account.
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"plumbus/pkg/model/ad"
+	"plumbus/pkg/node"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/logs"
+)
+
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
+// level describes ads, which have no budget of their own and may be filtered by campaign and ad set.
+var level = node.Level{
+	Name:  "ad",
+	Node:  "ads",
+	Kind:  "ad",
+	Table: ad.Table,
+	Filters: []node.Filter{
+		{Param: "campaignID", Attribute: "CampaignID"},
+		{Param: "adsetID", Attribute: "AdSetID"},
+	},
+	List: func() node.List { return &ads{} },
+}
+
+// ads are the ads fb payloads and db items decode into.
+type ads []ad.Entity
+
+func (aa *ads) Nodes() []node.Node {
+	nn := make([]node.Node, len(*aa))
+	for i := range *aa {
+		nn[i] = &(*aa)[i]
+	}
+	return nn
+}
+
+func init() {
+	logs.Init()
+	db = repo.NewDynamo(repo.WithWorkers(4))
+}
+
+func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return level.Handle(ctx, db, req)
+}
+
+func main() {
+	lambda.Start(handle)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"plumbus/pkg/model/ad"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/sam"
+	"plumbus/test"
+	"testing"
+)
+
+func TestHandlePutGetPatch(t *testing.T) {
+
+	db = repo.NewMemory(ad.Schema, campaign.ChangeSchema)
+
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		var req map[string]interface{}
+		_ = json.Unmarshal(payload, &req)
+		if req["node"] == "ads" {
+			b, _ := json.Marshal([]ad.Entity{
+				{AccountID: "1", ID: "a1", CampaignID: "c1", AdSetID: "s1", Stated: campaign.Active},
+				{AccountID: "1", ID: "a2", CampaignID: "c1", AdSetID: "s2", Stated: campaign.Active},
+			})
+			return b
+		}
+		return []byte(`{"success":true}`)
+	}})
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, map[string]string{"accountID": "1"})); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	var ee []ad.Entity
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"accountID": "1", "campaignID": "c1", "adsetID": "s2"}))
+	if _ = json.Unmarshal([]byte(res.Body), &ee); len(ee) != 1 || ee[0].ID != "a2" {
+		t.Error("expected the ad of the ad set ", res.Body)
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "ID": "a2", "budget": "100"})); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected a budget change of an ad to be rejected, got ", res.StatusCode)
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "ID": "a2", "status": "PAUSED"})); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	var change campaign.Change
+	if err := db.Get(test.CTX, campaign.ChangeTable, "CampaignID", "a2", &change); err != nil || change.Level != "ad" || change.Stated != "PAUSED" {
+		t.Error("expected the change to be recorded as an ad change ", change, err)
+	}
+
+	res, _ = handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"accountID": "1", "IDs": "a2"}))
+	if _ = json.Unmarshal([]byte(res.Body), &ee); len(ee) != 1 || ee[0].Stated != "PAUSED" {
+		t.Error("expected the status to persist ", res.Body)
+	}
+}
@@ -0,0 +1,50 @@
+// Package provides functionality for refreshing, returning and updating the ad sets of an account.
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/node"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/logs"
+)
+
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
+// level describes ad sets, which have a budget of their own and may be filtered by campaign.
+var level = node.Level{
+	Name:    "ad set",
+	Node:    "adsets",
+	Kind:    "adset",
+	Table:   adset.Table,
+	Budget:  true,
+	Filters: []node.Filter{{Param: "campaignID", Attribute: "CampaignID"}},
+	List:    func() node.List { return &adSets{} },
+}
+
+// adSets are the ad sets fb payloads and db items decode into.
+type adSets []adset.Entity
+
+func (ss *adSets) Nodes() []node.Node {
+	nn := make([]node.Node, len(*ss))
+	for i := range *ss {
+		nn[i] = &(*ss)[i]
+	}
+	return nn
+}
+
+func init() {
+	logs.Init()
+	db = repo.NewDynamo(repo.WithWorkers(4))
+}
+
+func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return level.Handle(ctx, db, req)
+}
+
+func main() {
+	lambda.Start(handle)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"net/http"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/sam"
+	"plumbus/test"
+	"testing"
+)
+
+func TestHandlePutGetPatch(t *testing.T) {
+
+	db = repo.NewMemory(adset.Schema, campaign.ChangeSchema)
+
+	var sent []map[string]interface{}
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		var req map[string]interface{}
+		_ = json.Unmarshal(payload, &req)
+		sent = append(sent, req)
+		switch req["node"] {
+		case "adsets":
+			b, _ := json.Marshal([]adset.Entity{
+				{AccountID: "1", ID: "s1", CampaignID: "c1", Stated: campaign.Active, DailyBudget: "2000", Spend: "12"},
+				{AccountID: "1", ID: "s2", CampaignID: "c2", Stated: campaign.Active, Spend: "3"},
+			})
+			return b
+		case "campaigns_status":
+			return []byte(`[{"ID":"s1","status":"PAUSED"},{"ID":"s2","status":"PAUSED","error":"graph: permission denied"}]`)
+		default:
+			return []byte(`{"success":true}`)
+		}
+	}})
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, map[string]string{"accountID": "1"})); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	get := func(par map[string]string) (ee []adset.Entity) {
+		res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, par))
+		_ = json.Unmarshal([]byte(res.Body), &ee)
+		return
+	}
+
+	if ee := get(map[string]string{"accountID": "1", "campaignID": "c1"}); len(ee) != 1 || ee[0].ID != "s1" || ee[0].Refreshed == "" {
+		t.Error("expected the refreshed ad set of the campaign ", ee)
+	}
+
+	par := map[string]string{"accountID": "1", "ID": "s1", "budget": "2500", "ruleID": "r1"}
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, par)); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	} else if last := sent[len(sent)-1]; last["node"] != "budget" || last["daily_budget"] != "2500" {
+		t.Error("unexpected fb request ", last)
+	}
+
+	var change campaign.Change
+	if err := db.Get(test.CTX, campaign.ChangeTable, "CampaignID", "s1", &change); err != nil || change.RuleID != "r1" || change.Level != "adset" || change.DailyBudget != "2500" {
+		t.Error("expected the change to be recorded for cooldowns ", change, err)
+	}
+
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "status": "PAUSED"}))
+	var ss []fb.Status
+	if _ = json.Unmarshal([]byte(res.Body), &ss); res.StatusCode != http.StatusOK || len(ss) != 2 {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	ee := get(map[string]string{"accountID": "1", "IDs": "s1,s2"})
+	for _, e := range ee {
+		if e.ID == "s1" && (e.Stated != "PAUSED" || e.DailyBudget != "2500") || e.ID == "s2" && e.Stated != campaign.Active {
+			t.Error("expected only confirmed changes to persist ", e)
+		}
+	}
+}
+
+func TestHandleGetPagesOfCampaign(t *testing.T) {
+
+	db = repo.NewMemory(adset.Schema)
+
+	var rr []types.WriteRequest
+	for i, c := range []string{"c1", "c2", "c2", "c1", "c2", "c1"} {
+		r, _ := adset.Entity{AccountID: "1", ID: fmt.Sprint("s", i), CampaignID: c}.WriteRequest()
+		rr = append(rr, r)
+	}
+	_ = db.BatchWrite(test.CTX, adset.Table, rr)
+
+	var got []string
+	par := map[string]string{"accountID": "1", "campaignID": "c1", "limit": "2"}
+	for pages := 0; pages < 10; pages++ {
+		res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, par))
+		var page struct {
+			Data   []adset.Entity `json:"data"`
+			Cursor string         `json:"cursor"`
+		}
+		_ = json.Unmarshal([]byte(res.Body), &page)
+		for _, e := range page.Data {
+			if e.CampaignID != "c1" {
+				t.Error("expected only ad sets of the campaign ", e)
+			}
+			got = append(got, e.ID)
+		}
+		if page.Cursor == "" {
+			break
+		}
+		par["cursor"] = page.Cursor
+	}
+
+	if len(got) != 3 {
+		t.Error("expected every ad set of the campaign across pages ", got)
+	}
+}
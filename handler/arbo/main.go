@@ -1,26 +1,46 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/ptr"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"net/http"
 	"plumbus/pkg/api"
 	"plumbus/pkg/model/arbo"
 	"plumbus/pkg/repo"
+	"plumbus/pkg/secret"
 	"plumbus/pkg/util/logs"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
-var client = &http.Client{}
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
+// session signs in to Arbotron and keeps its cookies in db; tests point it at a local server.
+var session *arbo.Session
+
+var (
+	// workers bounds the fetches running at once.
+	workers = 4
+
+	// timeout bounds a single fetch, reading its response included.
+	timeout = 30 * time.Second
+)
 
 func init() {
 	logs.Init()
+	db = repo.NewDynamo()
+	session = arbo.NewSession(db, secret.FromEnv())
 }
 
 func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -28,87 +48,249 @@ func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.API
 	switch req.RequestContext.HTTP.Method {
 	case http.MethodOptions:
 		return api.K()
+	case http.MethodGet:
+		return getClients(ctx)
+	case http.MethodPost:
+		return postClient(ctx, req.Body)
+	case http.MethodDelete:
+		return delClient(ctx, req.QueryStringParameters["id"])
 	case http.MethodPut:
-		return put(ctx)
+		return put(ctx, req.QueryStringParameters)
 	default:
 		return api.Nada()
 	}
 }
 
-func put(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+// put fetches the campaigns of every enabled client, or of the client of a clientID, for each day between
+// optional from and to dates (YYYY-MM-DD), today by default. Every day is kept in the daily table, today's
+// campaigns also replace those of the arbo table. Fetches run concurrently and a failed one does not keep
+// the others from being written; the response reports each fetch, with a 207 status when some failed and
+// a 502 status when all of them did.
+func put(ctx context.Context, params map[string]string) (events.APIGatewayV2HTTPResponse, error) {
 
-	var ee []arbo.Entity
+	now := time.Now()
 
-	for _, c := range arbo.Clients() {
-		if arr, err := fetch(ctx, c); err != nil {
-			log.WithError(err).Error("fetch ", c)
-			return api.Err(err)
-		} else {
-			ee = append(ee, arr...)
-		}
+	days, err := arbo.Days(params["from"], params["to"], now)
+	if err != nil {
+		return api.Err(err)
+	}
+
+	var cc []arbo.Client
+	if cc, err = clients(ctx, params["clientID"]); err != nil {
+		return api.Err(err)
 	}
 
+	ee, reports := fetchAll(ctx, cc, days)
+
 	log.Info("total arbo entities fetched: ", len(ee))
 
-	var rr []types.WriteRequest
+	today := now.UTC().Format(arbo.Layout)
+
+	var rr, daily []types.WriteRequest
 	for _, e := range ee {
-		rr = append(rr, e.WriteRequest())
+		if e.Date == today {
+			rr = append(rr, e.WriteRequest())
+		}
+		daily = append(daily, e.WriteRequest())
 	}
 
-	if err := repo.BatchWrite(ctx, arbo.Table, rr); err != nil {
-		log.WithError(err).Error("writing arbo data")
-		return api.Err(err)
+	if len(daily) > 0 {
+		if err = db.BatchWrite(ctx, arbo.DailyTable, daily); err != nil {
+			log.WithError(err).Error("writing daily arbo data")
+			return api.Err(err)
+		}
+	}
+
+	if len(rr) > 0 {
+		if err = db.BatchWrite(ctx, arbo.Table, rr); err != nil {
+			log.WithError(err).Error("writing arbo data")
+			return api.Err(err)
+		}
 	}
 
 	log.Trace("all entities saved")
 
+	sum := arbo.Summary{Written: len(ee), Reports: reports}
+	for _, r := range reports {
+		if r.Error != "" {
+			sum.Failed++
+		}
+	}
+
+	if sum.Failed > 0 && sum.Failed == len(reports) {
+		return api.BadGateway(sum)
+	} else if sum.Failed > 0 {
+		return api.MultiStatus(sum)
+	}
+
+	return api.JSON(sum)
+}
+
+// fetchAll fetches the campaigns of every client on every day with a bounded number of workers,
+// returning the entities of the successful fetches and a report of each fetch in client and day order.
+func fetchAll(ctx context.Context, cc []arbo.Client, days []time.Time) (ee []arbo.Entity, reports []arbo.Report) {
+
+	type task struct {
+		client arbo.Client
+		day    time.Time
+	}
+
+	var tt []task
+	for _, c := range cc {
+		for _, d := range days {
+			tt = append(tt, task{c, d})
+		}
+	}
+
+	results := make([][]arbo.Entity, len(tt))
+	reports = make([]arbo.Report, len(tt))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := range tt {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+
+			t := tt[i]
+			r := arbo.Report{ClientID: t.client.ID, Name: t.client.Name, Date: t.day.Format(arbo.Layout)}
+
+			tctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			arr, n, err := fetch(tctx, t.client, t.day)
+			r.Duration = time.Since(start).Milliseconds()
+			r.Bytes = n
+
+			if err != nil {
+				log.WithError(err).Error("fetch ", t.client, " ", r.Date)
+				r.Error = err.Error()
+			} else {
+				r.Entities = len(arr)
+				results[i] = arr
+			}
+			reports[i] = r
+		}(i)
+	}
+	wg.Wait()
+
+	for _, arr := range results {
+		ee = append(ee, arr...)
+	}
+	return
+}
+
+// clients returns the enabled clients, or the configured client of the given id whether enabled or not.
+func clients(ctx context.Context, id string) ([]arbo.Client, error) {
+
+	var cc []arbo.Client
+	if err := db.Scan(ctx, &dynamodb.ScanInput{TableName: ptr.String(arbo.ClientTable)}, &cc); err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		return arbo.Enabled(cc), nil
+	}
+
+	for _, c := range cc {
+		if strconv.Itoa(c.ID) == id {
+			return []arbo.Client{c}, nil
+		}
+	}
+
+	return nil, errors.New("unknown client " + id)
+}
+
+// getClients returns every configured client in id order.
+func getClients(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+
+	var cc []arbo.Client
+	if err := db.Scan(ctx, &dynamodb.ScanInput{TableName: ptr.String(arbo.ClientTable)}, &cc); err != nil {
+		return api.Err(err)
+	}
+
+	sort.Slice(cc, func(i, j int) bool { return cc[i].ID < cc[j].ID })
+
+	return api.JSON(cc)
+}
+
+// postClient adds a client or replaces the name and enabled flag of an existing one.
+func postClient(ctx context.Context, body string) (events.APIGatewayV2HTTPResponse, error) {
+
+	var c arbo.Client
+	if err := json.Unmarshal([]byte(body), &c); err != nil {
+		return api.Err(err)
+	} else if err = c.Validate(); err != nil {
+		return api.Err(err)
+	}
+
+	c.Updated = time.Now().UTC().Format(time.RFC3339)
+
+	if item, err := c.Item(); err != nil {
+		return api.Err(err)
+	} else if err = db.Put(ctx, &dynamodb.PutItemInput{Item: item, TableName: ptr.String(arbo.ClientTable)}); err != nil {
+		return api.Err(err)
+	}
+
+	return api.JSON(c)
+}
+
+func delClient(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error) {
+
+	if _, err := strconv.Atoi(id); err != nil {
+		return api.Err(errors.New("invalid client id " + id))
+	}
+
+	in := &dynamodb.DeleteItemInput{
+		TableName: ptr.String(arbo.ClientTable),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberN{
+				Value: id,
+			},
+		},
+	}
+
+	if err := db.Delete(ctx, in); err != nil {
+		return api.Err(err)
+	}
+
 	return api.K()
 }
 
-func fetch(ctx context.Context, c arbo.Client) ([]arbo.Entity, error) {
+// fetch returns the campaigns of a client on a day along with the number of bytes of the decoded response.
+func fetch(ctx context.Context, c arbo.Client, day time.Time) ([]arbo.Entity, int64, error) {
 
 	log.Trace("fetching ", c)
 
-	var err error
-
-	var res *http.Response
-	if res, err = client.Do(arbo.NewRequest(ctx, c)); err != nil {
+	res, err := session.Do(arbo.NewRequest(ctx, session.Base, c, day))
+	if err != nil {
 		log.WithError(err).Error("campaign request failed for ", c)
-		return nil, err
+		return nil, 0, err
 	}
 
 	log.Trace("campaign request response status code ", res.StatusCode)
 
 	defer func(Body io.ReadCloser) {
-		if err = Body.Close(); err != nil {
+		if err := Body.Close(); err != nil {
 			log.WithError(err).Error("error closing body")
 		}
 	}(res.Body)
 
-	var zr *gzip.Reader
-	if zr, err = gzip.NewReader(res.Body); err != nil {
-		log.WithError(err).Error("gzip reader failed")
-		return nil, err
+	pay, n, err := arbo.Decode(res)
+	if err != nil {
+		log.WithError(err).Error("decoding campaigns of ", c)
+		return nil, n, err
 	}
 
-	var out bytes.Buffer
-	var wrt int64
-	if wrt, err = io.Copy(&out, zr); err != nil {
-		log.WithError(err).Error("writing failed")
-		return nil, err
-	}
-
-	log.Trace("bytes copied: ", wrt)
+	log.Trace("fetched entities: ", len(pay.Data), " in bytes: ", n)
 
-	var pay arbo.Payload
-	if err = json.Unmarshal(out.Bytes(), &pay); err != nil {
-		log.WithError(err).Error("error unmarshalling into payload")
-		return nil, err
+	for i := range pay.Data {
+		pay.Data[i].Date = day.Format(arbo.Layout)
 	}
 
-	log.Trace("fetched entities: ", len(pay.Data))
-
-	return pay.Data, nil
+	return pay.Data, n, nil
 }
 
 func main() {
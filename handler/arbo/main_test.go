@@ -1,15 +1,194 @@
 package main
 
 import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go/ptr"
 	"net/http"
+	"net/http/httptest"
+	"plumbus/pkg/model/arbo"
+	"plumbus/pkg/repo"
 	"plumbus/pkg/sam"
+	"plumbus/pkg/secret"
 	"plumbus/test"
+	"strings"
 	"testing"
+	"time"
 )
 
+// stub points the handler at a local Arbotron with the given clients configured.
+func stub(t *testing.T, data map[string]string, cc ...arbo.Client) *test.Arbotron {
+
+	site := &test.Arbotron{User: "u", Pass: "p", Data: data}
+	srv := httptest.NewServer(site)
+	t.Cleanup(srv.Close)
+
+	db = repo.NewMemory(arbo.Schema, arbo.DailySchema, arbo.SessionSchema, arbo.ClientSchema)
+	session = arbo.NewSession(db, secret.Stub{arbo.UserSecret: "u", arbo.PassSecret: "p"}, arbo.WithBase(srv.URL))
+
+	for _, c := range cc {
+		body, _ := json.Marshal(c)
+		if res, _ := handle(test.CTX, post(string(body))); res.StatusCode != http.StatusOK {
+			t.Fatal(res.StatusCode, res.Body)
+		}
+	}
+
+	return site
+}
+
+func post(body string) events.APIGatewayV2HTTPRequest {
+	req := sam.NewRequest(http.MethodPost, nil)
+	req.Body = body
+	return req
+}
+
 func TestHandlePut(t *testing.T) {
+
+	site := stub(t, map[string]string{
+		"155": `{"data":[{"cid":"1","name":"one","spend":"1.5"}]}`,
+		"173": `{"data":[{"cid":"2","name":"two","spend":"2"}]}`,
+	}, arbo.Client{ID: 155, Name: "amg", Enabled: true}, arbo.Client{ID: 173, Name: "inuvo", Enabled: true})
+
 	req := sam.NewRequest(http.MethodPut, nil)
 	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-		t.Error(res.StatusCode, res.Body)
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	var e map[string]interface{}
+	if err := db.Get(test.CTX, arbo.Table, "ID", "2", &e); err != nil || e["Named"] != "two" {
+		t.Error("expected the entity of the second client ", e, err)
+	}
+
+	site.Expire()
+	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK || site.Logins() != 2 {
+		t.Error("expected to sign in again ", res.StatusCode, res.Body, site.Logins())
+	}
+}
+
+func TestHandleClients(t *testing.T) {
+
+	site := stub(t, nil, arbo.Client{ID: 173, Name: "inuvo", Enabled: true}, arbo.Client{ID: 132, Name: "cbsi"})
+
+	var cc []arbo.Client
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, nil))
+	if _ = json.Unmarshal([]byte(res.Body), &cc); len(cc) != 2 || cc[0].ID != 132 || cc[0].Enabled || cc[1].Updated == "" {
+		t.Error("expected both clients in id order ", res.Body)
+	}
+
+	if res, _ = handle(test.CTX, post(`{"id":0,"name":"none"}`)); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected an invalid client to be rejected ", res.StatusCode)
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodPut, nil)); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	} else if f := site.Fetched(); len(f) != 1 || f[0][:4] != "173 " {
+		t.Error("expected only the enabled client to be fetched ", f)
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodDelete, map[string]string{"id": "173"})); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+	res, _ = handle(test.CTX, sam.NewRequest(http.MethodGet, nil))
+	if _ = json.Unmarshal([]byte(res.Body), &cc); len(cc) != 1 || cc[0].ID != 132 {
+		t.Error("expected the client to be deleted ", res.Body)
+	}
+}
+
+func TestHandlePutBackfill(t *testing.T) {
+
+	site := stub(t, map[string]string{"132": `{"data":[{"cid":"3","name":"three"}]}`},
+		arbo.Client{ID: 132, Name: "cbsi"})
+
+	to := time.Now().UTC().AddDate(0, 0, -1)
+	from := to.AddDate(0, 0, -2)
+	par := map[string]string{"from": from.Format(arbo.Layout), "to": to.Format(arbo.Layout), "clientID": "132"}
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, par)); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	want := map[string]bool{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		want["132 "+d.Format("January 2, 2006")+" - "+d.Format("January 2, 2006")] = true
+	}
+	if f := site.Fetched(); len(f) != 3 || !want[f[0]] || !want[f[1]] || !want[f[2]] {
+		t.Error("expected a request per day ", f)
+	}
+
+	var daily []arbo.Entity
+	if err := db.Scan(test.CTX, &dynamodb.ScanInput{TableName: ptr.String(arbo.DailyTable)}, &daily); err != nil || len(daily) != 3 {
+		t.Error("expected a daily entity per day ", len(daily), err)
+	}
+
+	var e map[string]interface{}
+	if _ = db.Get(test.CTX, arbo.Table, "ID", "3", &e); len(e) != 0 {
+		t.Error("expected past days to leave today's campaigns alone ", e)
+	}
+
+	for _, p := range []map[string]string{
+		{"from": "2022-01-02", "to": "2022-01-01"},
+		{"from": "2022-01-01", "to": "2022-03-01"},
+		{"to": time.Now().UTC().AddDate(0, 0, 2).Format(arbo.Layout)},
+		{"clientID": "999"},
+	} {
+		if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, p)); res.StatusCode != http.StatusBadRequest {
+			t.Error("expected ", p, " to be rejected, got ", res.StatusCode)
+		}
+	}
+}
+
+func TestHandlePutIsolatesFailures(t *testing.T) {
+
+	site := stub(t, map[string]string{
+		"132": `{"data":[{"cid":"3","name":"three"}]}`,
+		"155": `<html>oops</html>`,
+		"173": `{"data":[{"cid":"4","name":"four"},{"cid":"5","name":"five"}]}`,
+	}, arbo.Client{ID: 132, Name: "cbsi", Enabled: true}, arbo.Client{ID: 155, Name: "amg", Enabled: true},
+		arbo.Client{ID: 173, Name: "inuvo", Enabled: true}, arbo.Client{ID: 180, Name: "slow", Enabled: true})
+	site.Delay = map[string]time.Duration{"180": time.Second}
+
+	defer func(d time.Duration) { timeout = d }(timeout)
+	timeout = 100 * time.Millisecond
+
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, nil))
+	if res.StatusCode != http.StatusMultiStatus {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	var sum arbo.Summary
+	if err := json.Unmarshal([]byte(res.Body), &sum); err != nil || sum.Written != 3 || sum.Failed != 2 || len(sum.Reports) != 4 {
+		t.Fatal("unexpected summary ", res.Body, err)
+	}
+
+	if r := sum.Reports[0]; r.ClientID != 132 || r.Entities != 1 || r.Bytes == 0 || r.Error != "" {
+		t.Error("expected the healthy client to be reported ", r)
+	}
+	if r := sum.Reports[1]; r.ClientID != 155 || !strings.Contains(r.Error, "got an HTML page") {
+		t.Error("expected the broken client to be reported ", r)
+	}
+	if r := sum.Reports[3]; r.ClientID != 180 || r.Error == "" || r.Duration >= 1000 {
+		t.Error("expected the slow client to time out ", r)
+	}
+
+	var e map[string]interface{}
+	if err := db.Get(test.CTX, arbo.Table, "ID", "5", &e); err != nil || e["Named"] != "five" {
+		t.Error("expected the entities of healthy clients to be written ", e, err)
+	}
+}
+
+func TestHandlePutAllFailed(t *testing.T) {
+
+	stub(t, map[string]string{"155": `<html>oops</html>`, "173": `<html>down</html>`}, arbo.Client{ID: 155, Name: "amg", Enabled: true},
+		arbo.Client{ID: 173, Name: "inuvo", Enabled: true})
+
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, nil))
+	if res.StatusCode != http.StatusBadGateway {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	var sum arbo.Summary
+	if err := json.Unmarshal([]byte(res.Body), &sum); err != nil || sum.Written != 0 || sum.Failed != 2 || len(sum.Reports) != 2 {
+		t.Error("expected every failed fetch to be reported ", res.Body, err)
 	}
 }
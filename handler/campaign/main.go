@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -15,21 +16,30 @@ import (
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"plumbus/pkg/api"
+	"plumbus/pkg/graph"
 	"plumbus/pkg/model/arbo"
+	"plumbus/pkg/model/breakdown"
 	"plumbus/pkg/model/campaign"
 	"plumbus/pkg/model/fb"
+	"plumbus/pkg/model/snapshot"
 	"plumbus/pkg/model/sovrn"
 	"plumbus/pkg/repo"
 	"plumbus/pkg/sam"
 	"plumbus/pkg/util/logs"
 	"plumbus/pkg/util/nums"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
 func init() {
 	logs.Init()
+	db = repo.NewDynamo(repo.WithWorkers(4))
 }
 
 func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -38,26 +48,49 @@ func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.API
 	case http.MethodOptions:
 		return api.K()
 	case http.MethodGet:
+		if _, ok := req.QueryStringParameters["series"]; ok {
+			return series(ctx, req.QueryStringParameters)
+		} else if _, ok = req.QueryStringParameters["breakdowns"]; ok {
+			return segments(ctx, req.QueryStringParameters)
+		}
 		return get(ctx, req)
 	case http.MethodPatch:
 		return patch(ctx, req)
 	case http.MethodPut:
+		if csv, ok := req.QueryStringParameters["breakdowns"]; ok {
+			return putSegments(ctx, req.QueryStringParameters["accountID"], csv)
+		}
 		return put(ctx, req)
 	default:
 		return api.Nada()
 	}
 }
 
-// put gets all campaign entities from the fb handler for the given account
-// and refreshes them with performance data from the arbo or sovrn handler
-// and updates the campaign entity in the database
+// put gets all campaign entities from the fb handler for the given account, with insights for every lookback window
+// or those of the optional windows (csv) parameter, and refreshes them with performance data from the arbo or sovrn
+// handler and updates the campaign entity in the database
 func put(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 
 	accountID := req.QueryStringParameters["accountID"]
 
+	windows := campaign.Windows
+	if csv, ok := req.QueryStringParameters["windows"]; ok {
+		windows = nil
+		for _, s := range strings.Split(csv, ",") {
+			if w := campaign.Window(strings.TrimSpace(s)); w == "" || w == campaign.Today {
+				continue
+			} else if err := w.Validate(); err != nil {
+				return api.Err(err)
+			} else {
+				windows = append(windows, w)
+			}
+		}
+	}
+
 	param := map[string]interface{}{
-		"node": "campaigns",
-		"ID":   accountID,
+		"node":    "campaigns",
+		"ID":      accountID,
+		"windows": windows,
 	}
 
 	data, _ := json.Marshal(param)
@@ -67,6 +100,10 @@ func put(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGat
 	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
 		log.WithError(err).Error()
 		return api.Err(err)
+	} else if err = fb.Err(out); errors.Is(err, graph.ErrThrottled) {
+		return api.Throttled(err)
+	} else if err != nil {
+		return api.Err(err)
 	}
 
 	var cc []campaign.Entity
@@ -75,9 +112,12 @@ func put(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGat
 		return api.Err(err)
 	}
 
+	now := time.Now()
+
 	var wg sync.WaitGroup
+	var mu sync.Mutex
 	var ids []string
-	var rr []types.WriteRequest
+	var rr, ss []types.WriteRequest
 	for _, c := range cc {
 		wg.Add(1)
 		go func(c campaign.Entity) {
@@ -87,18 +127,31 @@ func put(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGat
 			if r, err := refresh(ctx, &c); err != nil {
 				log.WithError(err).Warn()
 			} else {
+				s, err := snapshot.New(c, now).WriteRequest()
+				mu.Lock()
 				ids = append(ids, c.ID)
 				rr = append(rr, r)
+				if err != nil {
+					log.WithError(err).Error("while marshalling a snapshot of campaign ", c.ID)
+				} else {
+					ss = append(ss, s)
+				}
+				mu.Unlock()
 			}
 		}(c)
 	}
 
 	wg.Wait()
 
-	if err = repo.BatchWrite(ctx, campaign.Table, rr); err != nil {
+	if err = db.BatchWrite(ctx, campaign.Table, rr); err != nil {
 		return api.Err(err)
 	}
 
+	// the day's snapshot of each campaign is overwritten by every refresh, so that the last refresh of a day wins.
+	if err = db.BatchWrite(ctx, snapshot.Table, ss); err != nil {
+		log.WithError(err).Error("while writing campaign snapshots for account ", accountID)
+	}
+
 	if cc, err = batch(ctx, accountID, ids); err != nil {
 		return api.Err(err)
 	}
@@ -112,9 +165,9 @@ func refresh(ctx context.Context, c *campaign.Entity) (r types.WriteRequest, err
 	var a arbo.Entity
 	var s sovrn.Entity
 
-	if err = repo.Get(ctx, arbo.Table, "ID", c.ID, &a); err != nil {
+	if err = db.Get(ctx, arbo.Table, "ID", c.ID, &a); err != nil {
 		log.WithError(err).Warn()
-	} else if err = repo.Get(ctx, sovrn.Table, "UTM", c.UTM, &s); err != nil {
+	} else if err = db.Get(ctx, sovrn.Table, "UTM", c.UTM, &s); err != nil {
 		log.WithError(err).Warn()
 	}
 
@@ -129,9 +182,9 @@ func refresh(ctx context.Context, c *campaign.Entity) (r types.WriteRequest, err
 	}
 
 	if a.ID == c.ID {
-		c.Revenue = nums.Float64(a.Revenue)
-		c.Profit = nums.Float64(a.Profit)
-		c.ROI = nums.Float64(a.Roi)
+		c.Revenue = a.Revenue.Float
+		c.Profit = a.Profit.Float
+		c.ROI = a.Roi.Float
 	} else {
 		c.Revenue = s.Revenue
 		c.Profit = c.Revenue - c.Spent()
@@ -152,36 +205,124 @@ func refresh(ctx context.Context, c *campaign.Entity) (r types.WriteRequest, err
 	return
 }
 
-// patch modifies either a single campaign status or the status of every campaign under an account
+// patch modifies either a single campaign status or the status of every campaign under an account,
+// or given a budget parameter in minor currency units, the daily budget of a single campaign;
+// the optional ruleID parameter identifies the rule making the change, which is otherwise considered manual.
 func patch(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 
 	var err error
 	status := campaign.Status(req.QueryStringParameters["status"])
 	accountID := req.QueryStringParameters["accountID"]
+	ruleID := req.QueryStringParameters["ruleID"]
+
+	if budget, ok := req.QueryStringParameters["budget"]; ok {
+		ID := req.QueryStringParameters["ID"]
+		if ID == "" {
+			return api.Err(errors.New("budget requires a campaign ID"))
+		} else if n, err := strconv.ParseInt(budget, 10, 64); err != nil || n <= 0 {
+			return api.Err(errors.New("budget must be a positive integer of minor currency units"))
+		} else if err = updateBudget(ctx, accountID, ID, budget, ruleID); errors.Is(err, graph.ErrThrottled) {
+			return api.Throttled(err)
+		} else if err != nil {
+			return api.Err(err)
+		}
+		return api.K()
+	}
 
 	if ID := req.QueryStringParameters["ID"]; ID != "" {
-		if err = update(ctx, accountID, ID, status); err != nil {
+		if err = update(ctx, accountID, ID, status, ruleID); errors.Is(err, graph.ErrThrottled) {
+			return api.Throttled(err)
+		} else if err != nil {
 			return api.Err(err)
 		}
 		return api.K()
 	}
 
-	var cc []campaign.Entity
-	if cc, err = query(ctx, accountID); err != nil {
+	if err = status.Validate(); err != nil {
 		return api.Err(err)
 	}
 
-	for _, c := range cc {
-		if err = update(ctx, accountID, c.ID, status); err != nil {
+	var ids []string
+	if csv := req.QueryStringParameters["IDs"]; csv != "" {
+		for _, ID := range strings.Split(csv, ",") {
+			if ID = strings.TrimSpace(ID); ID != "" {
+				ids = append(ids, ID)
+			}
+		}
+	} else {
+		var cc []campaign.Entity
+		if cc, err = query(ctx, accountID); err != nil {
 			return api.Err(err)
 		}
+		for _, c := range cc {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return api.JSON([]fb.Status{})
+	}
+
+	var ss []fb.Status
+	if ss, err = updateAll(ctx, accountID, ids, status, ruleID); errors.Is(err, graph.ErrThrottled) {
+		return api.Throttled(err)
+	} else if err != nil && ss == nil {
+		return api.Err(err)
+	}
+
+	return api.JSON(ss)
+}
+
+// updateAll modifies the status of many campaigns of an account in fb through a single batch and, for each
+// campaign fb changed, modifies its status in the db and records the change. It returns the outcome of each,
+// along with the error failing every campaign when fb changed none.
+func updateAll(ctx context.Context, accountID string, ids []string, status campaign.Status, ruleID string) (ss []fb.Status, err error) {
+
+	for _, ID := range ids {
+		ss = append(ss, fb.Status{ID: ID, Status: status})
+	}
+
+	param := map[string]interface{}{
+		"node":      "campaigns_status",
+		"campaigns": ss,
+	}
+
+	data, _ := json.Marshal(param)
+
+	var out *faas.InvokeOutput
+	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err == nil {
+		if err = fb.Err(out); err == nil {
+			var confirmed []fb.Status
+			if err = json.Unmarshal(out.Payload, &confirmed); err == nil && len(confirmed) != len(ss) {
+				err = fmt.Errorf("facebook reported %d of %d campaigns", len(confirmed), len(ss))
+			} else if err == nil {
+				ss = confirmed
+			}
+		}
+	}
+
+	if err != nil {
+		log.WithError(err).Error("while changing the status of campaigns")
+		for i := range ss {
+			ss[i].Error = err.Error()
+		}
+		return ss, err
+	}
+
+	for i, s := range ss {
+		if s.Error != "" {
+			continue
+		}
+		if err := persist(ctx, accountID, s.ID, s.Status, ruleID); err != nil {
+			ss[i].Error = err.Error()
+		}
 	}
 
-	return api.K()
+	return ss, nil
 }
 
-// update modifies a campaign status in fb and if successful, modifies a campaign status in the db
-func update(ctx context.Context, accountID, ID string, status campaign.Status) (err error) {
+// update modifies a campaign status in fb and only once fb confirmed it, persists it.
+func update(ctx context.Context, accountID, ID string, status campaign.Status, ruleID string) (err error) {
 
 	param := map[string]interface{}{
 		"node":   "campaign",
@@ -190,11 +331,22 @@ func update(ctx context.Context, accountID, ID string, status campaign.Status) (
 	}
 
 	data, _ := json.Marshal(param)
-	if _, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
+
+	var out *faas.InvokeOutput
+	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
+		log.WithError(err).Error()
+		return
+	} else if err = confirm(out, ID); err != nil {
 		log.WithError(err).Error()
 		return
 	}
 
+	return persist(ctx, accountID, ID, status, ruleID)
+}
+
+// persist modifies a campaign status in the db and records the change so that rules can honor their cooldowns.
+func persist(ctx context.Context, accountID, ID string, status campaign.Status, ruleID string) (err error) {
+
 	in := &dynamodb.UpdateItemInput{
 		TableName: ptr.String(campaign.Table),
 		Key: map[string]types.AttributeValue{
@@ -210,14 +362,84 @@ func update(ctx context.Context, accountID, ID string, status campaign.Status) (
 				Value: status.String(),
 			},
 		},
-		UpdateExpression: ptr.String("set Circ = :v1"),
+		UpdateExpression: ptr.String("set Stated = :v1"),
 	}
 
-	if _, err = repo.Update(ctx, in); err != nil {
+	if _, err = db.Update(ctx, in); err != nil {
 		log.WithError(err).Error()
+		return
 	}
 
-	return
+	record(ctx, campaign.Change{CampaignID: ID, AccountID: accountID, RuleID: ruleID, Stated: status, Changed: time.Now()})
+	return nil
+}
+
+// updateBudget modifies a campaign daily budget in fb and if successful, modifies the campaign daily budget
+// in the db and records the change so that rules can honor their cooldowns.
+func updateBudget(ctx context.Context, accountID, ID, budget, ruleID string) (err error) {
+
+	param := map[string]interface{}{
+		"node":         "budget",
+		"ID":           ID,
+		"daily_budget": budget,
+	}
+
+	data, _ := json.Marshal(param)
+
+	var out *faas.InvokeOutput
+	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
+		log.WithError(err).Error()
+		return
+	} else if err = confirm(out, ID); err != nil {
+		log.WithError(err).Error()
+		return
+	}
+
+	in := &dynamodb.UpdateItemInput{
+		TableName: ptr.String(campaign.Table),
+		Key: map[string]types.AttributeValue{
+			"AccountID": &types.AttributeValueMemberS{Value: accountID},
+			"ID":        &types.AttributeValueMemberS{Value: ID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: budget},
+		},
+		UpdateExpression: ptr.String("set DailyBudget = :v1"),
+	}
+
+	if _, err = db.Update(ctx, in); err != nil {
+		log.WithError(err).Error()
+		return
+	}
+
+	record(ctx, campaign.Change{CampaignID: ID, AccountID: accountID, RuleID: ruleID, DailyBudget: budget, Changed: time.Now()})
+	return nil
+}
+
+// confirm returns the error with which fb failed to update a campaign, or an error when fb did not report success.
+func confirm(out *faas.InvokeOutput, ID string) error {
+	if err := fb.Err(out); err != nil {
+		return err
+	}
+	var res graph.Result
+	if err := json.Unmarshal(out.Payload, &res); err != nil || !res.Success {
+		return fmt.Errorf("facebook did not confirm the update of campaign %s: %s", ID, out.Payload)
+	}
+	return nil
+}
+
+// record persists the latest change of a campaign. The change already happened in fb and the db by then,
+// so failing to record it is only logged, at the cost of rules not honoring their cooldown for it.
+func record(ctx context.Context, change campaign.Change) {
+
+	item, err := attributevalue.MarshalMap(&change)
+	if err == nil {
+		err = db.Put(ctx, &dynamodb.PutItemInput{TableName: ptr.String(campaign.ChangeTable), Item: item})
+	}
+
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"CampaignID": change.CampaignID}).Error("while recording a campaign change")
+	}
 }
 
 // get returns all campaign entities from the db that match the given accountID and campaignIDS (csv) parameters.
@@ -230,12 +452,18 @@ func get(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGat
 		return api.Err(errors.New("request missing accountID"))
 	}
 
-	var err error
-	var campaignIDS string
+	size, err := api.Limit(req.QueryStringParameters["limit"])
+	if err != nil {
+		return api.Err(err)
+	}
+
+	var next, campaignIDS string
 	var cc []campaign.Entity
 
 	if campaignIDS, found = req.QueryStringParameters["campaignIDS"]; found {
 		cc, err = batch(ctx, accountID, strings.Split(campaignIDS, ","))
+	} else if size > 0 {
+		next, err = repo.QueryPage(ctx, db, queryInput(accountID), size, req.QueryStringParameters["cursor"], &cc)
 	} else {
 		cc, err = query(ctx, accountID)
 	}
@@ -244,18 +472,164 @@ func get(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGat
 		return api.Err(err)
 	}
 
-	if len(cc) == 0 {
+	if len(cc) == 0 && next == "" {
 		return api.Empty()
 	}
 
 	for i := range cc {
 		cc[i].SetFormat()
 	}
+
+	if size > 0 && campaignIDS == "" {
+		return api.Page(cc, next)
+	}
+
 	return api.JSON(cc)
 }
 
+// series returns the daily snapshots of a campaign, given a campaignID, or the daily totals of every campaign of an
+// account, given an accountID, in date order between optional from and to dates (YYYY-MM-DD), the last 30 days by default.
+func series(ctx context.Context, params map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+
+	from, to, err := snapshot.Range(params["from"], params["to"])
+	if err != nil {
+		return api.Err(err)
+	}
+
+	in := &dynamodb.QueryInput{
+		TableName:              ptr.String(snapshot.Table),
+		KeyConditionExpression: ptr.String("#k = :v1 AND #d BETWEEN :v2 AND :v3"),
+		ExpressionAttributeNames: map[string]string{
+			"#d": "Date",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v2": &types.AttributeValueMemberS{Value: from},
+			":v3": &types.AttributeValueMemberS{Value: to},
+		},
+	}
+
+	campaignID, accountID := params["campaignID"], params["accountID"]
+	if campaignID != "" {
+		in.ExpressionAttributeNames["#k"] = "CampaignID"
+		in.ExpressionAttributeValues[":v1"] = &types.AttributeValueMemberS{Value: campaignID}
+	} else if accountID != "" {
+		in.IndexName = ptr.String(snapshot.AccountIndex)
+		in.ExpressionAttributeNames["#k"] = "AccountID"
+		in.ExpressionAttributeValues[":v1"] = &types.AttributeValueMemberS{Value: accountID}
+	} else {
+		return api.Err(errors.New("series requires a campaignID or accountID"))
+	}
+
+	out, err := db.Query(ctx, in)
+	if err != nil {
+		return api.Err(err)
+	}
+
+	ee := []snapshot.Entity{}
+	if err = attributevalue.UnmarshalListOfMaps(out.Items, &ee); err != nil {
+		return api.Err(err)
+	}
+
+	if campaignID == "" {
+		ee = snapshot.Totals(accountID, ee)
+	}
+
+	return api.JSON(ee)
+}
+
+// putSegments gets today's insights of every campaign of the given account split by the given breakdowns (csv)
+// from the fb handler and writes them to the db, replacing earlier refreshes of the same segments.
+func putSegments(ctx context.Context, accountID, csv string) (events.APIGatewayV2HTTPResponse, error) {
+
+	dd, err := breakdown.Parse(csv)
+	if err != nil {
+		return api.Err(err)
+	} else if accountID == "" {
+		return api.Err(errors.New("request missing accountID"))
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"node": "breakdowns", "ID": accountID, "breakdowns": dd})
+
+	var out *faas.InvokeOutput
+	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
+		log.WithError(err).Error()
+		return api.Err(err)
+	} else if err = fb.Err(out); errors.Is(err, graph.ErrThrottled) {
+		return api.Throttled(err)
+	} else if err != nil {
+		return api.Err(err)
+	}
+
+	var ee []breakdown.Entity
+	if err = json.Unmarshal(out.Payload, &ee); err != nil {
+		log.WithError(err).Error()
+		return api.Err(err)
+	}
+
+	var rr []types.WriteRequest
+	for _, e := range ee {
+		if r, err := e.WriteRequest(); err != nil {
+			log.WithError(err).Error("while marshalling segment ", e.Segment, " of campaign ", e.CampaignID)
+		} else {
+			rr = append(rr, r)
+		}
+	}
+
+	if err = db.BatchWrite(ctx, breakdown.Table, rr); err != nil {
+		return api.Err(err)
+	}
+
+	return api.JSON(ee)
+}
+
+// segments returns the stored segments of a campaign, given a campaignID, optionally only those of the given
+// breakdowns (csv), ordered by spend so that the segments costing the most come first.
+func segments(ctx context.Context, params map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+
+	campaignID := params["campaignID"]
+	if campaignID == "" {
+		return api.Err(errors.New("breakdowns require a campaignID"))
+	}
+
+	var of string
+	if csv := params["breakdowns"]; csv != "" {
+		dd, err := breakdown.Parse(csv)
+		if err != nil {
+			return api.Err(err)
+		}
+		of = breakdown.Join(dd)
+	}
+
+	out, err := db.Query(ctx, &dynamodb.QueryInput{
+		TableName:              ptr.String(breakdown.Table),
+		KeyConditionExpression: ptr.String("CampaignID = :v1"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: campaignID},
+		},
+	})
+	if err != nil {
+		return api.Err(err)
+	}
+
+	var all []breakdown.Entity
+	if err = attributevalue.UnmarshalListOfMaps(out.Items, &all); err != nil {
+		return api.Err(err)
+	}
+
+	ee := []breakdown.Entity{}
+	for _, e := range all {
+		if of == "" || e.Breakdown == of {
+			ee = append(ee, e)
+		}
+	}
+
+	sort.SliceStable(ee, func(i, j int) bool { return nums.Float64(ee[i].Spend) > nums.Float64(ee[j].Spend) })
+
+	return api.JSON(ee)
+}
+
 // batch returns a campaign entity array from the db where a campaign account ID and the given campaign ids are equal to
-// the given parameters
+// the given parameters; keys the db could not process, even after retries, are reported through a repo.BatchError.
 func batch(ctx context.Context, accountID string, ids []string) (cc []campaign.Entity, err error) {
 
 	var keys []map[string]types.AttributeValue
@@ -280,7 +654,7 @@ func batch(ctx context.Context, accountID string, ids []string) (cc []campaign.E
 	}
 
 	var out *dynamodb.BatchGetItemOutput
-	if out, err = repo.BatchGet(ctx, in); err != nil {
+	if out, err = db.BatchGet(ctx, in); err != nil {
 		log.WithError(err).Error()
 	} else if err = attributevalue.UnmarshalListOfMaps(out.Responses[campaign.Table], &cc); err != nil {
 		log.WithError(err).Error()
@@ -294,18 +668,8 @@ func batch(ctx context.Context, accountID string, ids []string) (cc []campaign.E
 // query returns a campaign entity array from the db where the accountID is equal to the given parameter.
 func query(ctx context.Context, accountID string) (cc []campaign.Entity, err error) {
 
-	in := &dynamodb.QueryInput{
-		TableName:              ptr.String(campaign.Table),
-		KeyConditionExpression: ptr.String("AccountID = :v1"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":v1": &types.AttributeValueMemberS{
-				Value: accountID,
-			},
-		},
-	}
-
 	var out *dynamodb.QueryOutput
-	if out, err = repo.Query(ctx, in); err != nil {
+	if out, err = db.Query(ctx, queryInput(accountID)); err != nil {
 		log.WithError(err).Error()
 	} else if err = attributevalue.UnmarshalListOfMaps(out.Items, &cc); err != nil {
 		log.WithError(err).Error()
@@ -316,6 +680,19 @@ func query(ctx context.Context, accountID string) (cc []campaign.Entity, err err
 	return
 }
 
+// queryInput returns the input for querying every campaign owned by the given account.
+func queryInput(accountID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              ptr.String(campaign.Table),
+		KeyConditionExpression: ptr.String("AccountID = :v1"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{
+				Value: accountID,
+			},
+		},
+	}
+}
+
 func main() {
 	lambda.Start(handle)
 }
@@ -2,14 +2,42 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/ptr"
 	"net/http"
+	"plumbus/pkg/graph"
+	"plumbus/pkg/model/arbo"
+	"plumbus/pkg/model/breakdown"
 	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
+	"plumbus/pkg/model/snapshot"
+	"plumbus/pkg/model/sovrn"
+	"plumbus/pkg/repo"
 	"plumbus/pkg/sam"
+	"plumbus/pkg/util/nums"
 	"plumbus/pkg/util/pretty"
 	"plumbus/test"
 	"testing"
+	"time"
 )
 
+// seed replaces the handler store with memory holding two campaigns of one account.
+func seed(t *testing.T) {
+	db = repo.NewMemory(campaign.Schema)
+	var rr []types.WriteRequest
+	for _, id := range []string{"23849761526340551", "23849761526450551"} {
+		c := campaign.Entity{AccountID: "544877570187911", ID: id, Named: id + " test", Stated: campaign.Active, Spend: "10"}
+		rr = append(rr, c.WriteRequest())
+	}
+	if err := db.BatchWrite(test.CTX, campaign.Table, rr); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestHandlePut(t *testing.T) {
 	req := sam.NewRequest(http.MethodPut, map[string]string{"accountID": "264100649065412"})
 	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
@@ -21,25 +49,348 @@ func TestHandlePut(t *testing.T) {
 	}
 }
 
-//func TestHandleGetByAccountID(t *testing.T) {
-//	par := map[string]string{"accountID": "1450566098533975"}
-//	req := sam.NewRequest(http.MethodGet, par)
-//	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-//		t.Error(res.StatusCode, res.Body)
-//	} else {
-//		var cc []campaign.Entity
-//		json.Unmarshal([]byte(res.Body), &cc)
-//		pretty.Print(cc)
-//	}
-//}
-//
-//func TestHandleGetByAccountIDAndCampaignIDS(t *testing.T) {
-//	par := map[string]string{
-//		"accountID":   "544877570187911",
-//		"campaignIDS": "23849761526340551,23849761526450551",
-//	}
-//	req := sam.NewRequest(http.MethodGet, par)
-//	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
-//		t.Error(res.StatusCode, res.Body)
-//	}
-//}
+func TestHandleGetByAccountID(t *testing.T) {
+	seed(t)
+	par := map[string]string{"accountID": "544877570187911"}
+	req := sam.NewRequest(http.MethodGet, par)
+	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
+		t.Error(res.StatusCode, res.Body)
+	} else {
+		var cc []campaign.Entity
+		if err := json.Unmarshal([]byte(res.Body), &cc); err != nil || len(cc) != 2 {
+			t.Error(err, res.Body)
+		}
+	}
+}
+
+func TestHandleGetByAccountIDAndCampaignIDS(t *testing.T) {
+	seed(t)
+	par := map[string]string{
+		"accountID":   "544877570187911",
+		"campaignIDS": "23849761526450551",
+	}
+	req := sam.NewRequest(http.MethodGet, par)
+	if res, _ := handle(test.CTX, req); res.StatusCode != http.StatusOK {
+		t.Error(res.StatusCode, res.Body)
+	} else {
+		var cc []campaign.Entity
+		if err := json.Unmarshal([]byte(res.Body), &cc); err != nil || len(cc) != 1 || cc[0].ID != "23849761526450551" {
+			t.Error(err, res.Body)
+		}
+	}
+}
+
+func TestHandleGetPage(t *testing.T) {
+	seed(t)
+	par := map[string]string{"accountID": "544877570187911", "limit": "1"}
+	for _, want := range []string{"23849761526340551", "23849761526450551"} {
+		req := sam.NewRequest(http.MethodGet, par)
+		res, _ := handle(test.CTX, req)
+		if res.StatusCode != http.StatusOK {
+			t.Fatal(res.StatusCode, res.Body)
+		}
+		var page struct {
+			Data   []campaign.Entity `json:"data"`
+			Cursor string            `json:"cursor"`
+		}
+		if err := json.Unmarshal([]byte(res.Body), &page); err != nil || len(page.Data) != 1 || page.Data[0].ID != want {
+			t.Fatal(err, res.Body)
+		}
+		par["cursor"] = page.Cursor
+	}
+}
+
+func TestHandlePatchBudget(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, campaign.ChangeSchema)
+	c := campaign.Entity{AccountID: "1", ID: "2", Stated: campaign.Active, DailyBudget: "5000"}
+	_ = db.BatchWrite(test.CTX, campaign.Table, []types.WriteRequest{c.WriteRequest()})
+
+	var sent map[string]interface{}
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		_ = json.Unmarshal(payload, &sent)
+		return []byte(`{"success":true}`)
+	}})
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "budget": "0"})); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected a bad request without an ID, got ", res.StatusCode)
+	}
+
+	par := map[string]string{"accountID": "1", "ID": "2", "budget": "6000", "ruleID": "r1"}
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, par)); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	if sent["node"] != "budget" || sent["daily_budget"] != "6000" {
+		t.Error("unexpected fb request ", sent)
+	}
+
+	out, _ := db.Query(test.CTX, queryInput("1"))
+	var cc []campaign.Entity
+	_ = attributevalue.UnmarshalListOfMaps(out.Items, &cc)
+	if len(cc) != 1 || cc[0].DailyBudget != "6000" {
+		t.Error("expected the daily budget to be persisted ", cc)
+	}
+
+	var change campaign.Change
+	if err := db.Get(test.CTX, campaign.ChangeTable, "CampaignID", "2", &change); err != nil || change.RuleID != "r1" || change.DailyBudget != "6000" {
+		t.Error("expected the change to be recorded ", change, err)
+	}
+}
+
+func TestInsightsRoundTrip(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema)
+	c := campaign.Entity{AccountID: "1", ID: "2", Insights: map[campaign.Window]campaign.Insight{campaign.Last7d: {Spend: "350"}}}
+	_ = db.BatchWrite(test.CTX, campaign.Table, []types.WriteRequest{c.WriteRequest()})
+
+	out, _ := db.Query(test.CTX, queryInput("1"))
+	var cc []campaign.Entity
+	if err := attributevalue.UnmarshalListOfMaps(out.Items, &cc); err != nil || len(cc) != 1 || cc[0].Insights[campaign.Last7d].Spend != "350" {
+		t.Error("expected insights to be persisted per window ", cc, err)
+	}
+}
+
+func TestHandlePutSnapshotsAndSeries(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, snapshot.Schema, arbo.Schema, sovrn.Schema)
+
+	var windows interface{}
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		var req map[string]interface{}
+		_ = json.Unmarshal(payload, &req)
+		windows = req["windows"]
+		b, _ := json.Marshal([]campaign.Entity{
+			{AccountID: "1", ID: "a", Stated: campaign.Active, Spend: "50", Clicks: "10"},
+			{AccountID: "1", ID: "b", Stated: campaign.Active, Spend: "150", Clicks: "30"},
+		})
+		return b
+	}})
+
+	for _, profit := range []float64{-10, 25} {
+		for _, id := range []string{"a", "b"} {
+			item, _ := attributevalue.MarshalMap(&arbo.Entity{ID: id, Revenue: nums.N(100 + profit), Profit: nums.N(profit)})
+			_ = db.Put(test.CTX, &dynamodb.PutItemInput{TableName: ptr.String(arbo.Table), Item: item})
+		}
+		par := map[string]string{"accountID": "1", "windows": "last_7d"}
+		if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, par)); res.StatusCode != http.StatusOK {
+			t.Fatal(res.StatusCode, res.Body)
+		}
+	}
+
+	if ww, _ := windows.([]interface{}); len(ww) != 1 || ww[0] != "last_7d" {
+		t.Error("expected the windows parameter to reach fb ", windows)
+	}
+
+	var ee []snapshot.Entity
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"series": "", "campaignID": "b"}))
+	if _ = json.Unmarshal([]byte(res.Body), &ee); len(ee) != 1 || ee[0].Profit != 25 || ee[0].Spend != 150 || ee[0].Date != snapshot.Date(time.Now()) {
+		t.Error("expected a single snapshot of the last refresh ", res.Body)
+	}
+
+	res, _ = handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"series": "", "accountID": "1"}))
+	if _ = json.Unmarshal([]byte(res.Body), &ee); len(ee) != 1 || ee[0].Profit != 50 || ee[0].Spend != 200 || ee[0].Clicks != 40 || ee[0].ROI != 25 {
+		t.Error("expected daily account totals ", res.Body)
+	}
+
+	for _, par := range []map[string]string{{"series": ""}, {"series": "", "accountID": "1", "from": "yesterday"}} {
+		if res, _ = handle(test.CTX, sam.NewRequest(http.MethodGet, par)); res.StatusCode != http.StatusBadRequest {
+			t.Error("expected a bad request for ", par)
+		}
+	}
+}
+
+func TestHandlePutThrottled(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, snapshot.Schema, arbo.Schema, sovrn.Schema)
+
+	sam.Use(test.Lambdas{fb.Handler: func([]byte) []byte {
+		return test.Error(fmt.Errorf("%w: User request limit reached (code 17)", graph.ErrThrottled))
+	}})
+
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, map[string]string{"accountID": "1"}))
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Error("expected too many requests, got ", res.StatusCode, res.Body)
+	}
+
+	sam.Use(test.Lambdas{fb.Handler: func([]byte) []byte {
+		return test.Error(fmt.Errorf("graph: Invalid parameter"))
+	}})
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodPut, map[string]string{"accountID": "1"})); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected a bad request, got ", res.StatusCode, res.Body)
+	}
+}
+
+func TestHandlePatchBatch(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, campaign.ChangeSchema)
+	var rr []types.WriteRequest
+	for _, id := range []string{"a", "b", "c"} {
+		c := campaign.Entity{AccountID: "1", ID: id, Stated: campaign.Active}
+		rr = append(rr, c.WriteRequest())
+	}
+	_ = db.BatchWrite(test.CTX, campaign.Table, rr)
+
+	var invocations int
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		invocations++
+		var req struct {
+			Node      string
+			Campaigns []fb.Status `json:"campaigns"`
+		}
+		_ = json.Unmarshal(payload, &req)
+		if req.Node != "campaigns_status" {
+			return test.Error(fmt.Errorf("unexpected node %s", req.Node))
+		}
+		for i := range req.Campaigns {
+			if req.Campaigns[i].ID == "b" {
+				req.Campaigns[i].Error = "graph: permission denied"
+			}
+		}
+		b, _ := json.Marshal(req.Campaigns)
+		return b
+	}})
+
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "status": "PAUSED", "ruleID": "r1"}))
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	}
+
+	var ss []fb.Status
+	if _ = json.Unmarshal([]byte(res.Body), &ss); len(ss) != 3 || invocations != 1 {
+		t.Error("expected a single batch of every campaign of the account ", res.Body, invocations)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		var change campaign.Change
+		err := db.Get(test.CTX, campaign.ChangeTable, "CampaignID", id, &change)
+		if recorded := err == nil && change.RuleID == "r1"; recorded == (id == "b") {
+			t.Error("expected only confirmed changes to be recorded, campaign ", id)
+		}
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "status": "PAUSED", "IDs": "a, c"})); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	} else if _ = json.Unmarshal([]byte(res.Body), &ss); len(ss) != 2 || ss[1].ID != "c" || invocations != 2 {
+		t.Error("expected a batch of the given campaigns ", res.Body)
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "status": "BOGUS", "IDs": "a"})); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected an invalid status to be rejected, got ", res.StatusCode)
+	}
+}
+
+func TestHandlePatchStatusConfirmed(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, campaign.ChangeSchema)
+	c := campaign.Entity{AccountID: "1", ID: "2", Stated: campaign.Active}
+	_ = db.BatchWrite(test.CTX, campaign.Table, []types.WriteRequest{c.WriteRequest()})
+
+	stated := func() campaign.Status {
+		out, _ := db.Query(test.CTX, queryInput("1"))
+		var cc []campaign.Entity
+		_ = attributevalue.UnmarshalListOfMaps(out.Items, &cc)
+		return cc[0].Stated
+	}
+
+	par := map[string]string{"accountID": "1", "ID": "2", "status": "PAUSED"}
+	for _, payload := range [][]byte{
+		test.Error(errors.New("graph: Error validating access token (status 400, code 190, subcode 463, fbtrace_id A)")),
+		[]byte(`{"success":false}`),
+	} {
+		sam.Use(test.Lambdas{fb.Handler: func([]byte) []byte { return payload }})
+		if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, par)); res.StatusCode != http.StatusBadRequest {
+			t.Error("expected the failure to be reported, got ", res.StatusCode, res.Body)
+		} else if s := stated(); s != campaign.Active {
+			t.Error("expected an unconfirmed status not to be persisted, got ", s)
+		}
+	}
+
+	sam.Use(test.Lambdas{fb.Handler: func([]byte) []byte { return []byte(`{"success":true}`) }})
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, par)); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	} else if s := stated(); s != "PAUSED" {
+		t.Error("expected the confirmed status to be persisted, got ", s)
+	}
+}
+
+func TestHandlePatchBatchUnconfirmed(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, campaign.ChangeSchema)
+	c := campaign.Entity{AccountID: "1", ID: "a", Stated: campaign.Active}
+	_ = db.BatchWrite(test.CTX, campaign.Table, []types.WriteRequest{c.WriteRequest()})
+
+	sam.Use(test.Lambdas{fb.Handler: func([]byte) []byte { return test.Error(errors.New("graph: unknown error")) }})
+
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "IDs": "a", "status": "PAUSED"}))
+	var ss []fb.Status
+	if _ = json.Unmarshal([]byte(res.Body), &ss); res.StatusCode != http.StatusOK || len(ss) != 1 || ss[0].Error == "" {
+		t.Fatal("expected every campaign to carry the failure ", res.StatusCode, res.Body)
+	}
+
+	out, _ := db.Query(test.CTX, queryInput("1"))
+	var cc []campaign.Entity
+	if _ = attributevalue.UnmarshalListOfMaps(out.Items, &cc); len(cc) != 1 || cc[0].Stated != campaign.Active {
+		t.Error("expected an unconfirmed status not to be persisted ", cc)
+	}
+}
+
+func TestHandlePatchUnrecordedChange(t *testing.T) {
+
+	// without the schema of the change table, recording a change fails
+	db = repo.NewMemory(campaign.Schema)
+	c := campaign.Entity{AccountID: "1", ID: "2", Stated: campaign.Active}
+	_ = db.BatchWrite(test.CTX, campaign.Table, []types.WriteRequest{c.WriteRequest()})
+
+	sam.Use(test.Lambdas{fb.Handler: func([]byte) []byte { return []byte(`{"success":true}`) }})
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPatch, map[string]string{"accountID": "1", "ID": "2", "status": "PAUSED"})); res.StatusCode != http.StatusOK {
+		t.Error("expected a change which happened to be reported as such ", res.StatusCode, res.Body)
+	}
+}
+
+func TestHandleBreakdowns(t *testing.T) {
+
+	db = repo.NewMemory(campaign.Schema, breakdown.Schema)
+
+	var asked interface{}
+	sam.Use(test.Lambdas{fb.Handler: func(payload []byte) []byte {
+		var req map[string]interface{}
+		_ = json.Unmarshal(payload, &req)
+		asked = req["breakdowns"]
+		now := time.Now()
+		dd := []breakdown.Dimension{breakdown.Age}
+		b, _ := json.Marshal([]breakdown.Entity{
+			breakdown.New(map[string]string{"campaign_id": "c1", "account_id": "1", "age": "18-24", "spend": "5"}, dd, snapshot.Date(now), now),
+			breakdown.New(map[string]string{"campaign_id": "c1", "account_id": "1", "age": "45-54", "spend": "40"}, dd, snapshot.Date(now), now),
+			breakdown.New(map[string]string{"campaign_id": "c2", "account_id": "1", "age": "18-24", "spend": "1"}, dd, snapshot.Date(now), now),
+		})
+		return b
+	}})
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, map[string]string{"accountID": "1", "breakdowns": "age,region"})); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected breakdowns facebook cannot combine to be rejected, got ", res.StatusCode)
+	}
+
+	if res, _ := handle(test.CTX, sam.NewRequest(http.MethodPut, map[string]string{"accountID": "1", "breakdowns": "age"})); res.StatusCode != http.StatusOK {
+		t.Fatal(res.StatusCode, res.Body)
+	} else if aa, _ := asked.([]interface{}); len(aa) != 1 || aa[0] != "age" {
+		t.Error("expected the breakdowns to reach fb ", asked)
+	}
+
+	var ee []breakdown.Entity
+	res, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"campaignID": "c1", "breakdowns": "age"}))
+	if _ = json.Unmarshal([]byte(res.Body), &ee); len(ee) != 2 || ee[0].Segment != "age=45-54" {
+		t.Error("expected the segments of the campaign by spend ", res.Body)
+	}
+
+	res, _ = handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"campaignID": "c1", "breakdowns": "gender"}))
+	if _ = json.Unmarshal([]byte(res.Body), &ee); res.StatusCode != http.StatusOK || len(ee) != 0 {
+		t.Error("expected no segments of another breakdown ", res.Body)
+	}
+
+	if res, _ = handle(test.CTX, sam.NewRequest(http.MethodGet, map[string]string{"breakdowns": ""})); res.StatusCode != http.StatusBadRequest {
+		t.Error("expected a bad request without a campaignID")
+	}
+}
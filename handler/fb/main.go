@@ -9,32 +9,30 @@ import (
 	"fmt"
 	"github.com/aws/aws-lambda-go/lambda"
 	log "github.com/sirupsen/logrus"
-	"io/ioutil"
-	"net/http"
+	"net/url"
 	"os"
+	"plumbus/pkg/graph"
 	"plumbus/pkg/model/account"
+	"plumbus/pkg/model/ad"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/model/breakdown"
 	"plumbus/pkg/model/campaign"
 	"plumbus/pkg/model/fb"
+	"plumbus/pkg/model/snapshot"
 	"plumbus/pkg/util/logs"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 )
 
-const (
-	api                = "https://graph.facebook.com/v12.0"
-	formContentType    = "application/x-www-form-urlencoded"
-	accountFieldsParam = "fields=account_id,name,account_status,created_time"
-	descFieldsParam    = "fields=account_id,id,name,status,daily_budget,budget_remaining,created_time,updated_time"
-	sightFieldsParam   = "fields=account_id,campaign_id,clicks,impressions,spend,cpc,cpp,cpm,ctr"
-	levelParam         = "level=campaign"
-	dateParam          = "date_preset=today"
-)
+// client calls the Graph API; tests point it at a local server.
+var client *graph.Client
 
 var mutex = sync.Mutex{}
 
 func init() {
 	logs.Init()
+	client = graph.New()
 }
 
 func handle(ctx context.Context, req map[string]interface{}) (interface{}, error) {
@@ -43,22 +41,46 @@ func handle(ctx context.Context, req map[string]interface{}) (interface{}, error
 
 	switch req["node"] {
 	case "accounts":
-		return accounts()
+		return accounts(ctx)
 	case "campaign":
-		return postCampaignStatus(req)
+		return postCampaignStatus(ctx, req)
+	case "campaigns_status":
+		return postCampaignsStatus(ctx, req)
+	case "budget":
+		return postCampaignBudget(ctx, req)
 	case "campaigns":
-		return getCampaigns(req)
+		return getCampaigns(ctx, req)
+	case "insights":
+		return getInsights(ctx, req)
+	case "breakdowns":
+		return getBreakdowns(ctx, req)
+	case "adsets":
+		return getAdSets(ctx, req)
+	case "ads":
+		return getAds(ctx, req)
+	case "token":
+		return token(ctx)
+	case "usage":
+		return client.Utilization(), nil
 	default:
 		return nil, errors.New("bad request")
 	}
 }
 
-func getCampaigns(req map[string]interface{}) (out []campaign.Entity, err error) {
+// getCampaigns returns the campaigns of an account with insights for today and, given a "windows" list,
+// insights for each of those lookback windows.
+func getCampaigns(ctx context.Context, req map[string]interface{}) (out []campaign.Entity, err error) {
 
 	ID := req["ID"].(string)
 
 	var insights []campaign.Entity
-	if insights, err = getCampaignsSight(ID); err != nil {
+	if insights, err = client.Insights(ctx, ID, graph.Date{}); err != nil {
+		log.WithError(err).Error()
+		return
+	}
+
+	var windows map[campaign.Window]map[string]campaign.Insight
+	if windows, err = getWindows(ctx, ID, req["windows"]); err != nil {
 		log.WithError(err).Error()
 		return
 	}
@@ -69,7 +91,7 @@ func getCampaigns(req map[string]interface{}) (out []campaign.Entity, err error)
 	}
 
 	var desc []campaign.Entity
-	if desc, err = getCampaignDesc(ID); err != nil {
+	if desc, err = client.Campaigns(ctx, ID); err != nil {
 		log.WithError(err).Error()
 		return
 	}
@@ -92,6 +114,16 @@ func getCampaigns(req map[string]interface{}) (out []campaign.Entity, err error)
 				d.CPP = v.CPP
 				d.CPM = v.CPM
 				d.CTR = v.CTR
+				d.Day = v.Day
+			}
+
+			for w, ii := range windows {
+				if in, ok := ii[d.ID]; ok {
+					if d.Insights == nil {
+						d.Insights = map[campaign.Window]campaign.Insight{}
+					}
+					d.Insights[w] = in
+				}
 			}
 
 			d.SetUTM()
@@ -107,150 +139,253 @@ func getCampaigns(req map[string]interface{}) (out []campaign.Entity, err error)
 	return
 }
 
-func getCampaignDesc(ID string) (cc []campaign.Entity, err error) {
+// getWindows returns the campaign insights of an account for each of the given windows, by window and campaign ID.
+func getWindows(ctx context.Context, ID string, v interface{}) (out map[campaign.Window]map[string]campaign.Insight, err error) {
 
-	url := fmt.Sprintf("%s/act_%s/campaigns?%s&%s", api, ID, tokenParam(), descFieldsParam)
+	ww, _ := v.([]interface{})
+	out = map[campaign.Window]map[string]campaign.Insight{}
 
-	var all []interface{}
-	if all, err = get(url); err != nil {
-		log.WithError(err).Error()
-		return
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, x := range ww {
+
+		w := campaign.Window(fmt.Sprint(x))
+		if err = w.Validate(); err != nil {
+			return
+		}
+
+		wg.Add(1)
+		go func(w campaign.Window) {
+			defer wg.Done()
+			cc, e := client.Insights(ctx, ID, graph.Date{Preset: w.Preset()})
+			mu.Lock()
+			defer mu.Unlock()
+			if e != nil {
+				err = e
+				return
+			}
+			ii := map[string]campaign.Insight{}
+			for _, c := range cc {
+				ii[c.CampaignID], _ = c.Insight(campaign.Today)
+			}
+			out[w] = ii
+		}(w)
 	}
 
-	var data []byte
-	if data, err = json.Marshal(&all); err != nil {
-		log.WithError(err).Error()
-		return
+	wg.Wait()
+	return
+}
+
+// getInsights returns the campaign insights of an account for a "date_preset", e.g. last_7d,
+// or a "since" and "until" time range of YYYY-MM-DD dates, defaulting to today.
+func getInsights(ctx context.Context, req map[string]interface{}) (out []campaign.Entity, err error) {
+
+	ID, _ := req["ID"].(string)
+	if ID == "" {
+		return nil, errors.New("insights request missing ID")
 	}
 
-	if err = json.Unmarshal(data, &cc); err != nil {
+	var d graph.Date
+	d.Preset, _ = req["date_preset"].(string)
+	d.Since, _ = req["since"].(string)
+	d.Until, _ = req["until"].(string)
+
+	if out, err = client.Insights(ctx, ID, d); err != nil {
 		log.WithError(err).Error()
 		return
 	}
 
-	log.Trace("got ", len(cc), " campaign descriptions for AccountID ", ID)
+	log.Trace("got ", len(out), " campaign insights for AccountID ", ID)
 	return
 }
 
-func getCampaignsSight(ID string) (out []campaign.Entity, err error) {
+// getBreakdowns returns today's insights of every campaign of an account split by a "breakdowns" list,
+// one entity per campaign and segment.
+func getBreakdowns(ctx context.Context, req map[string]interface{}) (out []breakdown.Entity, err error) {
 
-	url := fmt.Sprintf("%s/act_%s/insights?%s&%s&%s&%s", api, ID, tokenParam(), sightFieldsParam, levelParam, dateParam)
+	ID := fmt.Sprint(req["ID"])
 
-	var all []interface{}
-	if all, err = get(url); err != nil {
-		log.WithError(err).Error()
+	var dd []breakdown.Dimension
+	ss, _ := req["breakdowns"].([]interface{})
+	for _, s := range ss {
+		dd = append(dd, breakdown.Dimension(fmt.Sprint(s)))
+	}
+	if err = breakdown.Validate(dd); err != nil {
 		return
 	}
 
-	var data []byte
-	if data, err = json.Marshal(&all); err != nil {
-		log.WithError(err).Error()
-		return
+	names := make([]string, len(dd))
+	for i, d := range dd {
+		names[i] = string(d)
 	}
 
-	if err = json.Unmarshal(data, &out); err != nil {
+	var rows []map[string]string
+	if rows, err = client.Breakdowns(ctx, ID, names, graph.Date{}); err != nil {
 		log.WithError(err).Error()
 		return
 	}
 
-	log.Trace("got ", len(out), " campaign insights for AccountID ", ID)
+	now := time.Now()
+	out = []breakdown.Entity{}
+	for _, row := range rows {
+		date := row["date_start"]
+		if date == "" {
+			date = snapshot.Date(now)
+		}
+		out = append(out, breakdown.New(row, dd, date, now))
+	}
 
+	log.Trace("got ", len(out), " campaign segments by ", names, " for AccountID ", ID)
 	return
 }
 
-func postCampaignStatus(req map[string]interface{}) (v interface{}, err error) {
+// getAdSets returns the ad sets of an account with their insights for today.
+func getAdSets(ctx context.Context, req map[string]interface{}) (out []adset.Entity, err error) {
 
-	url := fmt.Sprintf("%s/%s?%s&%s", api, req["ID"], tokenParam(), req["status"].(campaign.Status).Param())
+	ID := fmt.Sprint(req["ID"])
 
-	if _, err = http.Post(url, formContentType, nil); err != nil {
+	var insights []adset.Entity
+	if insights, err = client.AdSetInsights(ctx, ID, graph.Date{}); err != nil {
 		log.WithError(err).Error()
+		return
 	}
 
+	if out, err = client.AdSets(ctx, ID); err != nil {
+		log.WithError(err).Error()
+		return
+	}
+
+	byID := map[string]adset.Entity{}
+	for _, in := range insights {
+		byID[in.AdSetID] = in
+	}
+	for i := range out {
+		if in, ok := byID[out[i].ID]; ok {
+			out[i].Merge(in)
+		}
+	}
+
+	log.Trace("got ", len(out), " ad sets for AccountID ", ID)
 	return
 }
 
-func accounts() (out []account.Entity, err error) {
+// getAds returns the ads of an account with their insights for today.
+func getAds(ctx context.Context, req map[string]interface{}) (out []ad.Entity, err error) {
 
-	url := fmt.Sprintf("%s/%s/adaccounts?%s&%s", api, user(), tokenParam(), accountFieldsParam)
+	ID := fmt.Sprint(req["ID"])
 
-	var all []interface{}
-	if all, err = get(url); err != nil {
+	var insights []ad.Entity
+	if insights, err = client.AdInsights(ctx, ID, graph.Date{}); err != nil {
 		log.WithError(err).Error()
 		return
 	}
 
-	var data []byte
-	if data, err = json.Marshal(&all); err != nil {
+	if out, err = client.Ads(ctx, ID); err != nil {
 		log.WithError(err).Error()
 		return
 	}
 
-	if err = json.Unmarshal(data, &out); err != nil {
+	byID := map[string]ad.Entity{}
+	for _, in := range insights {
+		byID[in.AdID] = in
+	}
+	for i := range out {
+		if in, ok := byID[out[i].ID]; ok {
+			out[i].Merge(in)
+		}
+	}
+
+	log.Trace("got ", len(out), " ads for AccountID ", ID)
+	return
+}
+
+func postCampaignStatus(ctx context.Context, req map[string]interface{}) (v interface{}, err error) {
+
+	if v, err = client.Update(ctx, fmt.Sprint(req["ID"]), url.Values{"status": {fmt.Sprint(req["status"])}}); err != nil {
 		log.WithError(err).Error()
 	}
 
 	return
 }
 
-func get(url string, attempts ...int) (data []interface{}, err error) {
+// postCampaignsStatus changes the status of many campaigns, given as a "campaigns" list of fb.Status,
+// through batch requests and returns the outcome of each.
+func postCampaignsStatus(ctx context.Context, req map[string]interface{}) (out []fb.Status, err error) {
 
-	var res *http.Response
-	if res, err = http.Get(url); err != nil {
+	data, _ := json.Marshal(req["campaigns"])
+	if err = json.Unmarshal(data, &out); err != nil || len(out) == 0 {
+		return nil, errors.New("campaigns_status requires a list of campaigns")
+	}
 
-		var attempt int
-		if attempts != nil && len(attempts) > 0 {
-			attempt = attempts[0]
-		}
+	ids := make([]string, len(out))
+	fields := make([]url.Values, len(out))
+	for i, s := range out {
+		ids[i] = s.ID
+		fields[i] = url.Values{"status": {s.Status.String()}}
+	}
 
-		if attempt > 9 {
-			log.WithError(err).Error()
-			return
+	// a batch failing as a whole fails its calls and those of the batches after it, while the calls of earlier
+	// batches already took effect; every call is reported so that the caller can persist the confirmed ones.
+	var errs []error
+	if errs, err = client.UpdateAll(ctx, ids, fields); err != nil {
+		log.WithError(err).Error("while changing the status of campaigns in batches")
+		if len(errs) != len(ids) {
+			return nil, err
 		}
+		err = nil
+	}
 
-		if !strings.Contains(err.Error(), "too many open files") &&
-			!strings.Contains(err.Error(), "no such host") {
-			log.WithError(err).Trace()
-		} else if strings.Contains(err.Error(), "connection refused") {
-			log.WithError(err).Warn()
+	for i, e := range errs {
+		if e != nil {
+			log.WithError(e).WithFields(log.Fields{"ID": ids[i]}).Warn("while changing the status of a campaign")
+			out[i].Error = e.Error()
 		}
+	}
 
-		time.Sleep(time.Second * time.Duration(attempt))
+	return
+}
 
-		return get(url, attempt+1)
-	}
+// postCampaignBudget sets the daily budget of a campaign, given in the minor units of the account currency.
+func postCampaignBudget(ctx context.Context, req map[string]interface{}) (v interface{}, err error) {
 
-	var body []byte
-	if body, err = ioutil.ReadAll(res.Body); err != nil {
-		log.WithError(err).Error()
-		return
+	budget := fmt.Sprint(req["daily_budget"])
+	if n, _ := strconv.ParseInt(budget, 10, 64); n <= 0 {
+		return nil, errors.New("invalid daily_budget " + budget)
 	}
 
-	var p fb.Payload
-	if err = json.Unmarshal(body, &p); err != nil {
+	if v, err = client.Update(ctx, fmt.Sprint(req["ID"]), url.Values{"daily_budget": {budget}}); err != nil {
 		log.WithError(err).Error()
-		return
 	}
 
-	if data = append(data, p.Data...); p.Page.Next == "" {
-		return
+	return
+}
+
+// token reports the health of the token, warning in the log when it needs attention within tkn_warn_days days, 7 by default.
+func token(ctx context.Context) (s fb.TokenStatus, err error) {
+
+	days, _ := strconv.Atoi(os.Getenv("tkn_warn_days"))
+	if days <= 0 {
+		days = 7
 	}
 
-	var next []interface{}
-	if next, err = get(p.Page.Next); err != nil {
+	var t graph.Token
+	if t, err = client.DebugToken(ctx); err != nil {
 		log.WithError(err).Error()
 		return
 	}
 
-	data = append(data, next...)
-	return
-}
+	if s = fb.Check(t, time.Now(), time.Duration(days)*24*time.Hour); s.Warning != "" {
+		log.WithFields(log.Fields{"status": s}).Warn("the facebook token needs attention: ", s.Warning)
+	}
 
-func tokenParam() string {
-	return "access_token=" + os.Getenv("tkn")
+	return
 }
 
-func user() string {
-	return os.Getenv("usr")
+func accounts(ctx context.Context) (out []account.Entity, err error) {
+	if out, err = client.Accounts(ctx, fb.User()); err != nil {
+		log.WithError(err).Error()
+	}
+	return
 }
 
 func main() {
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"plumbus/pkg/graph"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
 	"plumbus/pkg/util/pretty"
 	"plumbus/test"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandleAccounts(t *testing.T) {
@@ -36,3 +45,167 @@ func TestHandleCampaignStatusUpdate(t *testing.T) {
 	//	t.Error(err)
 	//}
 }
+
+func TestHandleCampaignsMergesInsights(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/campaigns"):
+			fmt.Fprint(w, `{"data":[{"account_id":"1","id":"a","name":"utm_a","status":"ACTIVE","daily_budget":"5000"}]}`)
+		case r.URL.Query().Get("date_preset") == "today":
+			fmt.Fprint(w, `{"data":[{"campaign_id":"a","spend":"10"}]}`)
+		case r.URL.Query().Get("date_preset") == "last_7d":
+			fmt.Fprint(w, `{"data":[{"campaign_id":"a","spend":"70"}]}`)
+		default:
+			http.Error(w, `{"error":{"message":"unexpected","code":1}}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	client = graph.New(graph.WithBaseURL(srv.URL), graph.WithToken("secret"))
+	defer func() { client = graph.New() }()
+
+	res, err := handle(test.CTX, map[string]interface{}{"node": "campaigns", "ID": "1", "windows": []interface{}{"last_7d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc := res.([]campaign.Entity)
+	if len(cc) != 1 || cc[0].Spend != "10" || cc[0].Insights[campaign.Last7d].Spend != "70" || cc[0].DailyBudget != "5000" {
+		t.Error("unexpected campaigns ", cc)
+	}
+
+	if _, err = handle(test.CTX, map[string]interface{}{"node": "campaigns", "ID": "1", "windows": []interface{}{"last_30d"}}); err == nil {
+		t.Error("expected the graph error to surface")
+	}
+}
+
+func TestHandleCampaignsStatus(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"code":200,"body":"{\"success\":true}"},{"code":400,"body":"{\"error\":{\"message\":\"Invalid status\",\"code\":100}}"}]`)
+	}))
+	defer srv.Close()
+
+	client = graph.New(graph.WithBaseURL(srv.URL), graph.WithToken("secret"))
+	defer func() { client = graph.New() }()
+
+	res, err := handle(test.CTX, map[string]interface{}{"node": "campaigns_status", "campaigns": []interface{}{
+		map[string]interface{}{"ID": "a", "status": "PAUSED"},
+		map[string]interface{}{"ID": "b", "status": "PAUSED"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := res.([]fb.Status)
+	if len(ss) != 2 || ss[0].Error != "" || !strings.Contains(ss[1].Error, "Invalid status") {
+		t.Error("unexpected outcome ", ss)
+	}
+
+	if _, err = handle(test.CTX, map[string]interface{}{"node": "campaigns_status"}); err == nil {
+		t.Error("expected an empty list of campaigns to be rejected")
+	}
+}
+
+func TestHandleCampaignsStatusReportsFailedBatch(t *testing.T) {
+
+	var batches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if batches++; batches > 1 {
+			http.Error(w, `{"error":{"message":"Service temporarily unavailable","code":2}}`, http.StatusBadRequest)
+			return
+		}
+		var rr []string
+		for i := 0; i < graph.BatchSize; i++ {
+			rr = append(rr, `{"code":200,"body":"{\"success\":true}"}`)
+		}
+		fmt.Fprint(w, "["+strings.Join(rr, ",")+"]")
+	}))
+	defer srv.Close()
+
+	client = graph.New(graph.WithBaseURL(srv.URL), graph.WithToken("secret"), graph.WithBackoff(0, time.Millisecond, time.Millisecond))
+	defer func() { client = graph.New() }()
+
+	var cc []interface{}
+	for i := 0; i < graph.BatchSize+2; i++ {
+		cc = append(cc, map[string]interface{}{"ID": fmt.Sprint(i), "status": "PAUSED"})
+	}
+
+	res, err := handle(test.CTX, map[string]interface{}{"node": "campaigns_status", "campaigns": cc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := res.([]fb.Status)
+	if len(ss) != len(cc) || ss[0].Error != "" || ss[graph.BatchSize-1].Error != "" {
+		t.Fatal("expected the calls of the first batch to be confirmed ", ss)
+	}
+	for _, s := range ss[graph.BatchSize:] {
+		if !strings.Contains(s.Error, "Service temporarily unavailable") {
+			t.Error("expected the calls of the failed batch to carry its error ", s)
+		}
+	}
+}
+
+func TestHandleAdSetsMergesInsights(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/adsets"):
+			fmt.Fprint(w, `{"data":[{"account_id":"1","id":"s1","campaign_id":"c1","name":"set","status":"ACTIVE","daily_budget":"2000"}]}`)
+		case r.URL.Query().Get("level") == "adset":
+			fmt.Fprint(w, `{"data":[{"adset_id":"s1","spend":"12","ctr":"0.5"}]}`)
+		default:
+			http.Error(w, `{"error":{"message":"unexpected","code":1}}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	client = graph.New(graph.WithBaseURL(srv.URL), graph.WithToken("secret"))
+	defer func() { client = graph.New() }()
+
+	res, err := handle(test.CTX, map[string]interface{}{"node": "adsets", "ID": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ee := res.([]adset.Entity)
+	if len(ee) != 1 || ee[0].Spend != "12" || ee[0].CTR != "0.5" || ee[0].CampaignID != "c1" || ee[0].DailyBudget != "2000" {
+		t.Error("unexpected ad sets ", ee)
+	}
+}
+
+func TestHandleTokenWarnsBeforeExpiry(t *testing.T) {
+
+	expires := time.Now().Add(72 * time.Hour).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":{"type":"USER","is_valid":true,"expires_at":%d,"scopes":["ads_management"]}}`, expires)
+	}))
+	defer srv.Close()
+
+	client = graph.New(graph.WithBaseURL(srv.URL), graph.WithToken("secret"))
+	defer func() { client = graph.New() }()
+
+	res, err := handle(test.CTX, map[string]interface{}{"node": "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := res.(fb.TokenStatus)
+	if !s.Valid || s.Expires == nil || s.Expires.Unix() != expires || !strings.Contains(s.Warning, "expires in 72h") {
+		t.Error("expected a warning three days before expiry ", s)
+	}
+
+	if s = fb.Check(graph.Token{Valid: true}, time.Now(), time.Hour); s.Warning != "" || s.Expires != nil {
+		t.Error("expected a token that never expires not to warn ", s)
+	}
+}
+
+func TestHandleInsightsRequiresID(t *testing.T) {
+	for _, req := range []map[string]interface{}{{"node": "insights"}, {"node": "insights", "ID": 123}} {
+		if _, err := handle(test.CTX, req); err == nil {
+			t.Error("expected an insights request without a string ID to fail ", req)
+		}
+	}
+}
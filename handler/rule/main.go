@@ -10,22 +10,35 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	faas "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/smithy-go/ptr"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"plumbus/pkg/api"
+	"plumbus/pkg/model/ad"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/model/audit"
+	"plumbus/pkg/model/breakdown"
 	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
 	"plumbus/pkg/model/rule"
+	"plumbus/pkg/model/snapshot"
+	"plumbus/pkg/notify"
 	"plumbus/pkg/repo"
 	"plumbus/pkg/sam"
 	"plumbus/pkg/util/logs"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
 func init() {
 	logs.Init()
+	db = repo.NewDynamo()
 }
 
 func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -38,7 +51,10 @@ func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.API
 		return api.K()
 
 	case http.MethodGet:
-		return get(ctx)
+		if _, ok := req.QueryStringParameters["audit"]; ok {
+			return history(ctx, req.QueryStringParameters)
+		}
+		return get(ctx, req.QueryStringParameters["limit"], req.QueryStringParameters["cursor"])
 
 	case http.MethodPut:
 		return put(ctx, req.Body)
@@ -47,10 +63,11 @@ func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.API
 		return del(ctx, req.QueryStringParameters["id"])
 
 	case http.MethodPost:
+		_, dry := req.QueryStringParameters["dry"]
 		if _, ok := req.QueryStringParameters["all"]; ok {
-			return postAll(ctx)
+			return postAll(ctx, dry)
 		} else {
-			return postOne(ctx, req.Body)
+			return postOne(ctx, req.Body, dry)
 		}
 
 	default:
@@ -58,14 +75,28 @@ func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.API
 	}
 }
 
-func get(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+// get returns every rule, or a single page of rules along with the cursor of the next page when a limit is given.
+func get(ctx context.Context, limit, cursor string) (events.APIGatewayV2HTTPResponse, error) {
+
+	size, err := api.Limit(limit)
+	if err != nil {
+		return api.Err(err)
+	}
 
 	var out []rule.Entity
-	if err := repo.Scan(ctx, &dynamodb.ScanInput{TableName: rule.TableName()}, &out); err != nil {
+	if size == 0 {
+		if err = db.Scan(ctx, &dynamodb.ScanInput{TableName: rule.TableName()}, &out); err != nil {
+			return api.Err(err)
+		}
+		return api.JSON(out)
+	}
+
+	var next string
+	if next, err = repo.ScanPage(ctx, db, &dynamodb.ScanInput{TableName: rule.TableName()}, size, cursor, &out); err != nil {
 		return api.Err(err)
 	}
 
-	return api.JSON(out)
+	return api.Page(out, next)
 }
 
 func put(ctx context.Context, body string) (events.APIGatewayV2HTTPResponse, error) {
@@ -73,7 +104,7 @@ func put(ctx context.Context, body string) (events.APIGatewayV2HTTPResponse, err
 	var e rule.Entity
 	if err := json.Unmarshal([]byte(body), &e); err != nil {
 		return api.Err(err)
-	} else if err = e.Effect.Validate(); err != nil {
+	} else if err = e.Validate(); err != nil {
 		return api.Err(err)
 	}
 
@@ -85,7 +116,7 @@ func put(ctx context.Context, body string) (events.APIGatewayV2HTTPResponse, err
 
 	if item, err := attributevalue.MarshalMap(&e); err != nil {
 		return api.Err(err)
-	} else if err = repo.Put(ctx, &dynamodb.PutItemInput{Item: item, TableName: rule.TableName()}); err != nil {
+	} else if err = db.Put(ctx, &dynamodb.PutItemInput{Item: item, TableName: rule.TableName()}); err != nil {
 		return api.Err(err)
 	} else {
 		return api.JSON(e)
@@ -103,46 +134,81 @@ func del(ctx context.Context, id string) (events.APIGatewayV2HTTPResponse, error
 		},
 	}
 
-	if err := repo.Delete(ctx, in); err != nil {
+	if err := db.Delete(ctx, in); err != nil {
 		return api.Err(err)
 	}
 
 	return api.K()
 }
 
-func postAll(ctx context.Context) (events.APIGatewayV2HTTPResponse, error) {
+// postAll evaluates every due rule, leaving each campaign met by several rules to the one of highest precedence,
+// and unless this is a dry run, applies the resulting changes. Results are reported in order of precedence.
+func postAll(ctx context.Context, dry bool) (events.APIGatewayV2HTTPResponse, error) {
 
 	log.Trace("performing rule analysis requested by system")
 
 	var ee []rule.Entity
-	if err := repo.Scan(ctx, &dynamodb.ScanInput{TableName: rule.TableName()}, &ee); err != nil {
+	if err := db.Scan(ctx, &dynamodb.ScanInput{TableName: rule.TableName()}, &ee); err != nil {
 		return api.Err(err)
 	}
 
 	log.Trace("found ", len(ee), " rules to analyze and potentially act upon")
 
+	rule.Order(ee)
+	now := time.Now()
+
+	rr := make([]rule.Result, len(ee))
+	mm := make([]map[string]rule.Metrics, len(ee))
+
 	var wg sync.WaitGroup
-	for _, e := range ee {
+	for i, e := range ee {
+
+		if due, why := e.Due(now); !due {
+			log.Trace("skipping rule ", e.ID, ": ", why)
+			rr[i] = rule.Result{RuleID: e.ID, RuleName: e.Named, DryRun: dry, Changes: []rule.Change{}, Skipped: why}
+			continue
+		}
+
 		wg.Add(1)
-		go func(e rule.Entity) {
+		go func(i int, e rule.Entity) {
 			defer wg.Done()
-			if err := post(ctx, e); err != nil {
+			var err error
+			if rr[i], mm[i], err = plan(ctx, e, dry, now); err != nil {
 				log.WithError(err).
 					WithFields(log.Fields{"rule": e}).
 					Error("while getting campaigns and/or evaluating campaigns against the given rule")
-				return
+				rr[i].Error = err.Error()
+				rr[i].Changes = []rule.Change{}
 			}
-		}(e)
+		}(i, e)
 	}
 
 	wg.Wait()
 
-	log.Trace("completed rule analysis from user request")
+	conflicts := rule.Resolve(rr)
+	for _, c := range conflicts {
+		log.WithFields(log.Fields{"conflict": c}).Warn("campaign met by more than one rule")
+	}
 
-	return api.K()
+	var notifications []rule.Notification
+	if !dry {
+		for i := range rr {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				effect(ctx, ee[i], &rr[i], mm[i], now)
+			}(i)
+		}
+		wg.Wait()
+		notifications = notifyAll(ctx, ee, rr)
+	}
+
+	log.Trace("completed rule analysis from system request")
+
+	return api.JSON(rule.Report{Results: rr, Conflicts: conflicts, Notifications: notifications})
 }
 
-func postOne(ctx context.Context, body string) (events.APIGatewayV2HTTPResponse, error) {
+func postOne(ctx context.Context, body string, dry bool) (events.APIGatewayV2HTTPResponse, error) {
 
 	log.Trace("performing rule analysis requested by user")
 
@@ -150,84 +216,471 @@ func postOne(ctx context.Context, body string) (events.APIGatewayV2HTTPResponse,
 	if err := json.Unmarshal([]byte(body), &e); err != nil {
 		log.WithError(err).Error("unable to unmarshal request body into a rule entity")
 		return api.Err(err)
+	} else if err = e.Validate(); err != nil {
+		return api.Err(err)
 	}
 
 	log.WithFields(log.Fields{"rule.Entity": e}).Trace("successfully interpreted request body into a rule entity")
 
-	if err := post(ctx, e); err != nil {
+	now := time.Now()
+	res, mm, err := plan(ctx, e, dry, now)
+	if err != nil {
 		log.WithError(err).
 			WithFields(log.Fields{"rule": e}).
 			Error("while getting campaigns and/or evaluating campaigns against the given rule")
 		return api.Err(err)
 	}
 
+	if !dry {
+		effect(ctx, e, &res, mm, now)
+		notifyAll(ctx, []rule.Entity{e}, []rule.Result{res})
+	}
+
 	log.Trace("successfully completed rule analysis from user request")
-	return api.K()
+	return api.JSON(res)
 }
 
-func post(ctx context.Context, r rule.Entity) error {
+// plan evaluates a rule against the campaigns in its scope without side effects, withholding changes to campaigns
+// changed within the rule cooldown, and returns the metrics of each evaluated campaign by campaign ID.
+func plan(ctx context.Context, r rule.Entity, dry bool, now time.Time) (res rule.Result, mm map[string]rule.Metrics, err error) {
+
+	res = rule.Result{RuleID: r.ID, RuleName: r.Named, Level: r.Level, DryRun: dry, Changes: []rule.Change{}}
+	mm = map[string]rule.Metrics{}
 
 	var all []campaign.Entity
+	if all, err = campaigns(ctx, r); err != nil {
+		return
+	}
+
+	for _, c := range all {
+
+		today := snapshot.Today(c, now)
+
+		if len(r.Segment) > 0 {
+			var s breakdown.Entity
+			if s, err = segment(ctx, c.ID, r.Segment, today); err != nil {
+				return
+			} else if s.CampaignID == "" {
+				log.Trace("campaign ", c.ID, " has no segment ", r.Segment.Key(), " refreshed today")
+				res.Unmet = append(res.Unmet, rule.Change{
+					AccountID:    c.AccountID,
+					CampaignID:   c.ID,
+					CampaignName: c.Named,
+					From:         c.Stated,
+					To:           c.Stated,
+					Reason:       "no segment " + r.Segment.Key() + " refreshed " + today,
+				})
+				continue
+			}
+			c = s.Campaign(c)
+		}
+
+		var ok bool
+		var change rule.Change
+		past := func() ([]rule.Metrics, error) { return daily(ctx, c.ID, r.Days(), today) }
+		if change, ok, err = eval(r, c, past); err != nil {
+			return
+		}
+
+		mm[c.ID] = rule.Values(c)
+		if !ok {
+			res.Unmet = append(res.Unmet, change)
+			continue
+		}
+
+		if r.Cooldown > 0 {
+			var last campaign.Change
+			if err = db.Get(ctx, campaign.ChangeTable, "CampaignID", c.ID, &last); err != nil {
+				return
+			} else if !last.Changed.IsZero() && r.Cooling(last.Changed, now) {
+				log.Trace("campaign ", c.ID, " changed at ", last.Changed, " is cooling down from rule ", r.ID)
+				change.Reason = "changed " + last.Changed.UTC().Format(time.RFC3339) + ", within the rule cooldown"
+				res.Cooling = append(res.Cooling, change)
+				continue
+			}
+		}
+
+		res.Changes = append(res.Changes, change)
+	}
+
+	return
+}
+
+// effect applies the planned changes of a rule, recording the outcome of each in the result, and records every
+// outcome of the evaluation in the audit log: applied and notified changes, changes withheld by cooldown or
+// overruled, unmet campaigns, and a skipped or failed rule.
+func effect(ctx context.Context, r rule.Entity, res *rule.Result, mm map[string]rule.Metrics, now time.Time) {
+
+	// status changes to more than one campaign of an account are submitted together as a batch.
+	var batches [][]*rule.Change
+	batch := map[[2]string]int{}
+	for i := range res.Changes {
+		change := &res.Changes[i]
+		if change.Noop() {
+			continue
+		} else if change.BudgetTo > 0 {
+			if err := apply(ctx, r, *change); err != nil {
+				change.Error = err.Error()
+			}
+			continue
+		}
+		k := [2]string{change.AccountID, change.To.String()}
+		if j, ok := batch[k]; ok {
+			batches[j] = append(batches[j], change)
+		} else {
+			batch[k] = len(batches)
+			batches = append(batches, []*rule.Change{change})
+		}
+	}
+
+	for _, cc := range batches {
+		if len(cc) == 1 {
+			if err := apply(ctx, r, *cc[0]); err != nil {
+				cc[0].Error = err.Error()
+			}
+		} else {
+			applyAll(ctx, r, cc)
+		}
+	}
+
+	var ee []audit.Entity
+	for _, change := range res.Changes {
+		o := audit.Applied
+		if change.Noop() {
+			o = audit.Notified
+		}
+		ee = append(ee, audit.New(r, o, change, mm[change.CampaignID], now))
+	}
+
+	outcomes := []struct {
+		o  audit.Outcome
+		cc []rule.Change
+	}{{audit.Cooling, res.Cooling}, {audit.Overruled, res.Overruled}, {audit.Unmet, res.Unmet}}
+	for _, x := range outcomes {
+		for _, change := range x.cc {
+			ee = append(ee, audit.New(r, x.o, change, mm[change.CampaignID], now))
+		}
+	}
+
+	if res.Skipped != "" {
+		ee = append(ee, audit.Rule(r, audit.Skipped, res.Skipped, "", now))
+	} else if res.Error != "" {
+		ee = append(ee, audit.Rule(r, audit.Failed, "", res.Error, now))
+	}
+
+	var rr []types.WriteRequest
+	for _, e := range ee {
+		if w, err := e.WriteRequest(); err != nil {
+			log.WithError(err).Error("while marshalling an audit entity")
+		} else {
+			rr = append(rr, w)
+		}
+	}
+
+	if len(rr) > 0 {
+		if err := db.BatchWrite(ctx, audit.Table, rr); err != nil {
+			log.WithError(err).Error("while writing the audit log of rule ", r.ID)
+		}
+	}
+}
+
+// notifyAll sends each channel of the given rules a single digest of the changes of every rule notifying it,
+// given rules and their results in the same order.
+func notifyAll(ctx context.Context, ee []rule.Entity, rr []rule.Result) (out []rule.Notification) {
+
+	var cc []notify.Channel
+	digests := map[notify.Channel][]rule.Result{}
+	for i, e := range ee {
+		if len(rr[i].Changes) == 0 {
+			continue
+		}
+		for _, c := range e.Channels {
+			if _, ok := digests[c]; !ok {
+				cc = append(cc, c)
+			}
+			digests[c] = append(digests[c], rr[i])
+		}
+	}
+
+	for _, c := range cc {
+		n := rule.Notification{Channel: c}
+		for _, r := range digests[c] {
+			n.Rules = append(n.Rules, r.RuleID)
+			n.Campaigns += len(r.Changes)
+		}
+		if err := notify.Send(ctx, c, rule.Digest(digests[c])); err != nil {
+			log.WithError(err).WithFields(log.Fields{"channel": c}).Error("while sending a rule digest")
+			n.Error = err.Error()
+		}
+		out = append(out, n)
+	}
+
+	return
+}
+
+// history returns the audit log of rule evaluations for a ruleID, campaignID or accountID,
+// newest first, optionally bounded by RFC 3339 from and to times and paged by limit and cursor.
+func history(ctx context.Context, params map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+
+	size, err := api.Limit(params["limit"])
+	if err != nil {
+		return api.Err(err)
+	}
+
+	to := time.Now()
+	var from time.Time
+	if s := params["from"]; s != "" {
+		if from, err = time.Parse(time.RFC3339, s); err != nil {
+			return api.Err(err)
+		}
+	}
+	if s := params["to"]; s != "" {
+		if to, err = time.Parse(time.RFC3339, s); err != nil {
+			return api.Err(err)
+		}
+	}
+
+	lo, hi := audit.Range(from, to)
+	in := &dynamodb.QueryInput{
+		TableName:              ptr.String(audit.Table),
+		KeyConditionExpression: ptr.String("#k = :v1 AND Evaluated BETWEEN :v2 AND :v3"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v2": &types.AttributeValueMemberS{Value: lo},
+			":v3": &types.AttributeValueMemberS{Value: hi},
+		},
+		ScanIndexForward: ptr.Bool(false),
+	}
+
+	if id := params["ruleID"]; id != "" {
+		in.ExpressionAttributeNames = map[string]string{"#k": "RuleID"}
+		in.ExpressionAttributeValues[":v1"] = &types.AttributeValueMemberS{Value: id}
+	} else if id = params["campaignID"]; id != "" {
+		in.IndexName = ptr.String(audit.CampaignIndex)
+		in.ExpressionAttributeNames = map[string]string{"#k": "CampaignID"}
+		in.ExpressionAttributeValues[":v1"] = &types.AttributeValueMemberS{Value: id}
+	} else if id = params["accountID"]; id != "" {
+		in.IndexName = ptr.String(audit.AccountIndex)
+		in.ExpressionAttributeNames = map[string]string{"#k": "AccountID"}
+		in.ExpressionAttributeValues[":v1"] = &types.AttributeValueMemberS{Value: id}
+	} else {
+		return api.Err(errors.New("audit requires a ruleID, campaignID or accountID"))
+	}
+
+	var ee []audit.Entity
+	if size == 0 {
+		var out *dynamodb.QueryOutput
+		if out, err = db.Query(ctx, in); err != nil {
+			return api.Err(err)
+		} else if err = attributevalue.UnmarshalListOfMaps(out.Items, &ee); err != nil {
+			return api.Err(err)
+		}
+		return api.JSON(ee)
+	}
+
+	var next string
+	if next, err = repo.QueryPage(ctx, db, in, size, params["cursor"], &ee); err != nil {
+		return api.Err(err)
+	}
+
+	return api.Page(ee, next)
+}
+
+// campaigns returns the campaign entities within the scope of the given rule from the campaign handler.
+func campaigns(ctx context.Context, r rule.Entity) (all []campaign.Entity, err error) {
+
+	level := r.Of()
+
 	for id, ids := range r.Nodes {
 
 		params := map[string]string{"accountID": id}
-		if len(ids) > 0 {
+		if len(ids) > 0 && level == rule.CampaignLevel {
 			params["campaignIDS"] = strings.Join(ids, ",")
+		} else if len(ids) > 0 {
+			params["IDs"] = strings.Join(ids, ",")
 		}
 
-		var err error
 		var out *faas.InvokeOutput
-		if out, err = sam.NewReqRes(ctx, campaign.Handler, sam.NewRequestBytes(http.MethodGet, params)); err != nil {
-			return err
+		if out, err = sam.NewReqRes(ctx, handlerOf(level), sam.NewRequestBytes(http.MethodGet, params)); err != nil {
+			return
 		}
 
 		var res events.APIGatewayV2HTTPResponse
-		if _ = json.Unmarshal(out.Payload, &res); res.StatusCode != http.StatusOK {
-			return errors.New(res.Body)
+		if _ = json.Unmarshal(out.Payload, &res); res.StatusCode == http.StatusNotFound {
+			continue
+		} else if res.StatusCode != http.StatusOK {
+			err = errors.New(res.Body)
+			return
 		}
 
 		var cc []campaign.Entity
-		if err = json.Unmarshal([]byte(res.Body), &cc); err != nil {
-			return err
+		if cc, err = decode(level, []byte(res.Body)); err != nil {
+			return
 		}
 
 		all = append(all, cc...)
 	}
 
-	for _, c := range all {
-		eval(ctx, r, c)
+	return
+}
+
+// handlerOf returns the handler of the nodes of a rule level.
+func handlerOf(l rule.Level) string {
+	switch l {
+	case rule.AdSetLevel:
+		return adset.Handler
+	case rule.AdLevel:
+		return ad.Handler
+	default:
+		return campaign.Handler
 	}
-	return nil
 }
 
-func eval(ctx context.Context, r rule.Entity, c campaign.Entity) {
+// decode reads the nodes of a rule level, each in the shape of a campaign so that rules evaluate them alike.
+func decode(l rule.Level, body []byte) (cc []campaign.Entity, err error) {
+	switch l {
+	case rule.AdSetLevel:
+		var ee []adset.Entity
+		if err = json.Unmarshal(body, &ee); err == nil {
+			for _, e := range ee {
+				cc = append(cc, e.Campaign())
+			}
+		}
+	case rule.AdLevel:
+		var ee []ad.Entity
+		if err = json.Unmarshal(body, &ee); err == nil {
+			for _, e := range ee {
+				cc = append(cc, e.Campaign())
+			}
+		}
+	default:
+		err = json.Unmarshal(body, &cc)
+	}
+	return
+}
 
-	if r.Effect == c.Stated {
-		log.Trace("rule effect == campaign status")
+// segment returns the insights of a campaign within the given segment as refreshed today by the campaign handler,
+// or the zero entity when the segment was not refreshed today, the day the campaign reports insights for.
+func segment(ctx context.Context, campaignID string, s breakdown.Segment, today string) (e breakdown.Entity, err error) {
+
+	out, err := db.Query(ctx, &dynamodb.QueryInput{
+		TableName:              ptr.String(breakdown.Table),
+		KeyConditionExpression: ptr.String("CampaignID = :v1 AND Segment = :v2"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: campaignID},
+			":v2": &types.AttributeValueMemberS{Value: s.Key()},
+		},
+	})
+	if err != nil || len(out.Items) == 0 {
 		return
 	}
 
-	for _, condition := range r.Conditions {
+	if err = attributevalue.UnmarshalMap(out.Items[0], &e); err == nil && e.Date != today {
+		e = breakdown.Entity{}
+	}
 
-		if condition.LHS == rule.Spend && condition.Met(c.Spent()) {
-			log.Trace("spend met: ", c.ID)
-			continue
-		}
+	return
+}
 
-		if condition.LHS == rule.Profit && condition.Met(c.Profit) {
-			log.Trace("profit met: ", c.ID)
-			continue
+// daily returns the metrics of a campaign for each of the given number of days before today, most recent first,
+// from the snapshots retained by the campaign handler; days without a snapshot are nil. Today is the day the
+// campaign reports insights for, so that days are counted in the time zone of its ad account.
+func daily(ctx context.Context, campaignID string, n int, today string) ([]rule.Metrics, error) {
+
+	day, err := time.Parse(snapshot.Layout, today)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := day.AddDate(0, 0, -n).Format(snapshot.Layout), day.AddDate(0, 0, -1).Format(snapshot.Layout)
+	out, err := db.Query(ctx, &dynamodb.QueryInput{
+		TableName:                ptr.String(snapshot.Table),
+		KeyConditionExpression:   ptr.String("CampaignID = :v1 AND #d BETWEEN :v2 AND :v3"),
+		ExpressionAttributeNames: map[string]string{"#d": "Date"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: campaignID},
+			":v2": &types.AttributeValueMemberS{Value: from},
+			":v3": &types.AttributeValueMemberS{Value: to},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []snapshot.Entity
+	if err = attributevalue.UnmarshalListOfMaps(out.Items, &ss); err != nil {
+		return nil, err
+	}
+
+	days := make([]rule.Metrics, n)
+	for _, s := range ss {
+		for i := range days {
+			if day.AddDate(0, 0, -i-1).Format(snapshot.Layout) == s.Date {
+				days[i] = rule.Metrics{
+					rule.Spend:       s.Spend,
+					rule.Revenue:     s.Revenue,
+					rule.Profit:      s.Profit,
+					rule.ROI:         s.ROI,
+					rule.Clicks:      s.Clicks,
+					rule.Impressions: s.Impressions,
+				}
+			}
 		}
+	}
 
-		if condition.LHS == rule.ROI && condition.Met(c.ROI) {
-			log.Trace("roi met: ", c.ID)
-			continue
+	return days, nil
+}
+
+// eval reports whether the given campaign meets the rule and, if so, the change the rule effects; otherwise the
+// change leaves the campaign as is, with the Reason it was not met.
+// It has no side effects; past returns the daily metrics of the campaign before today, most recent first,
+// and is only called for rules with trends once their other conditions are met.
+func eval(r rule.Entity, c campaign.Entity, past func() ([]rule.Metrics, error)) (change rule.Change, ok bool, err error) {
+
+	change = rule.Change{
+		AccountID:    c.AccountID,
+		CampaignID:   c.ID,
+		CampaignName: c.Named,
+		From:         c.Stated,
+		To:           c.Stated,
+	}
+
+	var from, to int64
+	if r.Budget != nil {
+		if from, _ = strconv.ParseInt(c.DailyBudget, 10, 64); from <= 0 {
+			log.Trace("campaign has no daily budget to change: ", c.ID)
+			change.Reason = "no daily budget to change"
+			return
+		} else if to = r.Budget.Apply(from); to == from {
+			log.Trace("rule budget == campaign budget")
+			change.Reason = "daily budget already " + strconv.FormatInt(from, 10)
+			return
 		}
+	} else if r.Effect == c.Stated {
+		log.Trace("rule effect == campaign status")
+		change.Reason = "status already " + c.Stated.String()
+		return
+	}
 
+	m := rule.Values(c)
+	if ok, err = r.Met(m); err != nil || !ok {
 		log.Trace("nothing met: ", c.ID)
+		change.Reason = "conditions not met"
 		return
 	}
 
+	var bases rule.Metrics
+	if len(r.Trends) > 0 {
+		var days []rule.Metrics
+		if days, err = past(); err != nil {
+			ok = false
+			return
+		} else if ok, bases = r.Trending(m, days); !ok {
+			log.Trace("trends not met: ", c.ID)
+			change.Reason = "trends not met"
+			return
+		}
+	}
+
 	log.WithFields(log.Fields{
 		"AccountID":    c.AccountID,
 		"CampaignID":   c.ID,
@@ -239,22 +692,112 @@ func eval(ctx context.Context, r rule.Entity, c campaign.Entity) {
 		"RuleID":       r.ID,
 		"RuleName":     r.Named,
 		"Rule Effect":  r.Effect,
-	}).Trace("Campaign met rule conditions; Will attempt to effect change through the campaign handler")
+		"Rule Budget":  r.Budget,
+	}).Trace("Campaign met rule conditions")
 
-	data := sam.NewRequestBytes(http.MethodPatch, map[string]string{
-		"status":    r.Effect.String(),
-		"accountID": c.AccountID,
-		"ID":        c.ID,
-	})
+	change.To = r.Effect
+	change.BudgetFrom = from
+	change.BudgetTo = to
+	change.Triggers = r.Triggers(m)
 
-	if out, err := sam.NewReqRes(ctx, campaign.Handler, data); err != nil {
-		log.WithError(err).
-			WithFields(log.Fields{"code": out.StatusCode, "payload": string(out.Payload)}).
-			Error("while sending an update status event to fb handler")
-		return
+	for k, v := range bases {
+		change.Triggers[k] = v
+	}
+
+	if r.Budget != nil || r.NotifyOnly() {
+		change.To = c.Stated
+	}
+
+	return
+}
+
+// apply effects a change through the handler of the rule level, which updates both Facebook and the db.
+func apply(ctx context.Context, r rule.Entity, change rule.Change) error {
+
+	params := map[string]string{
+		"status":    change.To.String(),
+		"accountID": change.AccountID,
+		"ID":        change.CampaignID,
+		"ruleID":    r.ID,
+	}
+	if change.BudgetTo > 0 {
+		delete(params, "status")
+		params["budget"] = strconv.FormatInt(change.BudgetTo, 10)
+	}
+
+	data := sam.NewRequestBytes(http.MethodPatch, params)
+
+	out, err := sam.NewReqRes(ctx, handlerOf(r.Of()), data)
+	if err != nil {
+		log.WithError(err).Error("while sending an update status event to campaign handler")
+		return err
+	}
+
+	var res events.APIGatewayV2HTTPResponse
+	if _ = json.Unmarshal(out.Payload, &res); res.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{"code": res.StatusCode, "payload": string(out.Payload)}).
+			Error("while sending an update status event to campaign handler")
+		return errors.New(res.Body)
 	}
 
 	log.Trace("Successfully updated campaign status in Facebook and in the Plumbus database ... grab a beer.")
+	return nil
+}
+
+// applyAll sends the campaign handler a single update of the status of many campaigns of an account,
+// all changing to the same status, and records the outcome of each in its change.
+func applyAll(ctx context.Context, r rule.Entity, cc []*rule.Change) {
+
+	ids := make([]string, len(cc))
+	for i, c := range cc {
+		ids[i] = c.CampaignID
+	}
+
+	params := map[string]string{
+		"status":    cc[0].To.String(),
+		"accountID": cc[0].AccountID,
+		"IDs":       strings.Join(ids, ","),
+		"ruleID":    r.ID,
+	}
+
+	fail := func(err error) {
+		for _, c := range cc {
+			c.Error = err.Error()
+		}
+	}
+
+	out, err := sam.NewReqRes(ctx, handlerOf(r.Of()), sam.NewRequestBytes(http.MethodPatch, params))
+	if err != nil {
+		log.WithError(err).Error("while sending a batch update status event to campaign handler")
+		fail(err)
+		return
+	}
+
+	var res events.APIGatewayV2HTTPResponse
+	if _ = json.Unmarshal(out.Payload, &res); res.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{"code": res.StatusCode, "payload": string(out.Payload)}).
+			Error("while sending a batch update status event to campaign handler")
+		fail(errors.New(res.Body))
+		return
+	}
+
+	var ss []fb.Status
+	if err = json.Unmarshal([]byte(res.Body), &ss); err != nil {
+		fail(err)
+		return
+	}
+
+	outcome := map[string]string{}
+	for _, s := range ss {
+		outcome[s.ID] = s.Error
+	}
+	for _, c := range cc {
+		if e, ok := outcome[c.CampaignID]; !ok {
+			c.Error = "campaign missing from the batch update"
+		} else {
+			c.Error = e
+		}
+	}
 }
 
 func main() {
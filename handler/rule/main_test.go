@@ -4,13 +4,30 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"net/http"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/model/audit"
+	"plumbus/pkg/model/breakdown"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
 	"plumbus/pkg/model/rule"
+	"plumbus/pkg/model/snapshot"
+	"plumbus/pkg/notify"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/sam"
+	"plumbus/test"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandle(t *testing.T) {
 
+	db = repo.NewMemory(rule.Schema)
+
 	var success bool
 	if success = testPut(); !success {
 		t.Log("put failed")
@@ -44,7 +61,7 @@ func testPut() bool {
 				RHS: 100,
 			},
 		},
-		Effect: rule.Active,
+		Effect: campaign.Active,
 		Active: true,
 	})
 
@@ -96,3 +113,519 @@ func testDel(id string) string {
 
 	return out.Body
 }
+
+func TestHandlePutInvalidExpression(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema)
+
+	b, _ := json.Marshal(&rule.Entity{
+		Named:      "test rule",
+		Expression: "(ROI < -20 AND SPEND > 50",
+		Effect:     campaign.Active,
+	})
+
+	req := events.APIGatewayV2HTTPRequest{
+		Body: string(b),
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method: http.MethodPut,
+			},
+		},
+	}
+
+	if out, _ := handle(context.TODO(), req); out.StatusCode != http.StatusBadRequest {
+		t.Error("expected a validation error, got ", out.StatusCode, out.Body)
+	}
+}
+
+// stubCampaigns stands in for the campaign handler, returning a loser and a winner and counting patched campaigns.
+func stubCampaigns(patches *int) {
+	sam.Use(test.Lambdas{campaign.Handler: test.API(func(req events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+		if req.RequestContext.HTTP.Method == http.MethodPatch {
+			if csv, ok := req.QueryStringParameters["IDs"]; ok {
+				var ss []fb.Status
+				for _, ID := range strings.Split(csv, ",") {
+					ss = append(ss, fb.Status{ID: ID, Status: campaign.Status(req.QueryStringParameters["status"])})
+				}
+				*patches += len(ss)
+				b, _ := json.Marshal(ss)
+				return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Body: string(b)}
+			}
+			*patches++
+			return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK}
+		}
+		b, _ := json.Marshal([]campaign.Entity{
+			{AccountID: "1", ID: "loser", Stated: campaign.Active, Spend: "60", ROI: -25, Profit: -15},
+			{AccountID: "1", ID: "winner", Stated: campaign.Active, Spend: "60", ROI: 40, Profit: 24},
+		})
+		return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Body: string(b)}
+	})})
+}
+
+func TestHandlePostOneDryRun(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema)
+
+	var patches int
+	stubCampaigns(&patches)
+
+	b, _ := json.Marshal(&rule.Entity{
+		ID:         "r1",
+		Expression: "ROI < -20 AND SPEND > 50",
+		Effect:     campaign.Status("PAUSED"),
+		Nodes:      map[string][]string{"1": nil},
+	})
+
+	for _, dry := range []bool{true, false} {
+
+		req := sam.NewRequest(http.MethodPost, nil)
+		if req.Body = string(b); dry {
+			req.QueryStringParameters = map[string]string{"dry": ""}
+		}
+
+		out, _ := handle(test.CTX, req)
+		if out.StatusCode != http.StatusOK {
+			t.Fatal(out.StatusCode, out.Body)
+		}
+
+		var res rule.Result
+		if err := json.Unmarshal([]byte(out.Body), &res); err != nil {
+			t.Fatal(err)
+		} else if len(res.Changes) != 1 || res.Changes[0].CampaignID != "loser" || res.Changes[0].To != "PAUSED" {
+			t.Error("unexpected changes ", out.Body)
+		} else if res.Changes[0].Triggers[rule.ROI] != -25 || res.Changes[0].Triggers[rule.Spend] != 60 {
+			t.Error("unexpected triggers ", res.Changes[0].Triggers)
+		}
+	}
+
+	if patches != 1 {
+		t.Error("expected only the live run to patch, got ", patches)
+	}
+
+	for _, par := range []map[string]string{
+		{"audit": "", "ruleID": "r1"},
+		{"audit": "", "campaignID": "loser", "from": time.Now().Add(-time.Hour).Format(time.RFC3339)},
+		{"audit": "", "accountID": "1", "limit": "5"},
+	} {
+		out, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, par))
+		if out.StatusCode != http.StatusOK {
+			t.Fatal(out.StatusCode, out.Body)
+		}
+		var ee []audit.Entity
+		if _, paged := par["limit"]; paged {
+			var page struct {
+				Data []audit.Entity `json:"data"`
+			}
+			_ = json.Unmarshal([]byte(out.Body), &page)
+			ee = page.Data
+		} else {
+			_ = json.Unmarshal([]byte(out.Body), &ee)
+		}
+		var applied []audit.Entity
+		for _, e := range ee {
+			if e.Outcome == audit.Applied {
+				applied = append(applied, e)
+			} else if e.CampaignID != "winner" || e.Outcome != audit.Unmet || e.Reason != "conditions not met" || e.Success {
+				t.Error("expected only the winner to be audited unmet, got ", e)
+			}
+		}
+		if len(applied) != 1 || !applied[0].Success || applied[0].From != campaign.Active || applied[0].Metrics[rule.ROI] != -25 {
+			t.Error("expected a single audited change for ", par, " got ", out.Body)
+		}
+	}
+}
+
+func TestHandlePostAllSkipsInactiveAndUnscheduled(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema)
+
+	var patches int
+	stubCampaigns(&patches)
+
+	putRules(t,
+		rule.Entity{ID: "due", Active: true, Expression: "ROI < -20", Effect: "PAUSED", Nodes: map[string][]string{"1": nil}},
+		rule.Entity{ID: "inactive", Expression: "ROI < -20", Effect: "PAUSED", Nodes: map[string][]string{"1": nil}},
+		rule.Entity{ID: "unscheduled", Active: true, Expression: "ROI < -20", Effect: "PAUSED", Nodes: map[string][]string{"1": nil},
+			Schedule: &rule.Schedule{Start: "2999-01-01"}},
+	)
+
+	req := sam.NewRequest(http.MethodPost, map[string]string{"all": "", "dry": ""})
+	out, _ := handle(test.CTX, req)
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	}
+
+	var rep rule.Report
+	_ = json.Unmarshal([]byte(out.Body), &rep)
+	rr := rep.Results
+
+	want := map[string]string{"due": "", "inactive": "inactive", "unscheduled": "outside schedule"}
+	if len(rr) != len(want) {
+		t.Fatal("expected a result per rule, got ", out.Body)
+	}
+	for _, r := range rr {
+		if r.Skipped != want[r.RuleID] {
+			t.Error(r.RuleID, " expected skipped ", want[r.RuleID], " got ", r.Skipped)
+		} else if r.Skipped == "" && len(r.Changes) != 1 {
+			t.Error("expected the due rule to change the loser, got ", r.Changes)
+		}
+	}
+}
+
+// putRules seeds the rule table of the in-memory db.
+func putRules(t *testing.T, ee ...rule.Entity) {
+	for _, e := range ee {
+		item, _ := attributevalue.MarshalMap(&e)
+		if err := db.Put(test.CTX, &dynamodb.PutItemInput{TableName: rule.TableName(), Item: item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandlePostAllConflictsAndCooldowns(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema, campaign.ChangeSchema)
+
+	var patches int
+	stubCampaigns(&patches)
+
+	scope := map[string][]string{"1": nil}
+	putRules(t,
+		rule.Entity{ID: "a", Active: true, Expression: "ROI < 0", Effect: "PAUSED", Nodes: scope},
+		rule.Entity{ID: "b", Active: true, Expression: "SPEND > 50", Effect: "ARCHIVED", Nodes: scope, Priority: 1},
+		rule.Entity{ID: "c", Active: true, Expression: "ROI > 0", Effect: "PAUSED", Nodes: scope, Cooldown: 60},
+	)
+
+	// the winner was changed manually ten minutes ago, which rule c must honor.
+	item, _ := attributevalue.MarshalMap(&campaign.Change{CampaignID: "winner", AccountID: "1", Stated: campaign.Active, Changed: time.Now().Add(-10 * time.Minute)})
+	_ = db.Put(test.CTX, &dynamodb.PutItemInput{TableName: &campaign.ChangeTable, Item: item})
+
+	out, _ := handle(test.CTX, sam.NewRequest(http.MethodPost, map[string]string{"all": ""}))
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	}
+
+	var rep rule.Report
+	_ = json.Unmarshal([]byte(out.Body), &rep)
+
+	if len(rep.Results) != 3 || rep.Results[0].RuleID != "b" {
+		t.Fatal("expected results in order of precedence, got ", out.Body)
+	}
+
+	b, a, c := rep.Results[0], rep.Results[1], rep.Results[2]
+	if len(b.Changes) != 2 {
+		t.Error("expected the highest priority rule to change both campaigns, got ", b.Changes)
+	} else if len(a.Changes) != 0 {
+		t.Error("expected the lower priority rule to yield, got ", a.Changes)
+	} else if len(c.Changes) != 0 || len(c.Cooling) != 1 || c.Cooling[0].CampaignID != "winner" {
+		t.Error("expected the cooling rule to withhold its change, got ", c)
+	}
+
+	if len(rep.Conflicts) != 1 || rep.Conflicts[0].CampaignID != "loser" || rep.Conflicts[0].Winner != "b" || len(rep.Conflicts[0].Claims) != 2 {
+		t.Error("expected a single conflict over the loser, got ", rep.Conflicts)
+	}
+
+	if patches != 2 {
+		t.Error("expected one patch per campaign, got ", patches)
+	}
+
+	// every outcome is audited with its reason, including the changes withheld.
+	want := map[string]map[string]audit.Outcome{
+		"a": {"loser": audit.Overruled, "winner": audit.Unmet},
+		"c": {"loser": audit.Unmet, "winner": audit.Cooling},
+	}
+	for id, outcomes := range want {
+		ee := auditOf(t, map[string]string{"audit": "", "ruleID": id})
+		if len(ee) != len(outcomes) {
+			t.Error("expected an audit entry per campaign of rule ", id, " got ", ee)
+		}
+		for _, e := range ee {
+			if e.Outcome != outcomes[e.CampaignID] || e.Reason == "" || e.Success {
+				t.Error("unexpected audit of rule ", id, ": ", e)
+			}
+		}
+	}
+
+	ee := auditOf(t, map[string]string{"audit": "", "campaignID": "winner"})
+	var cooled []audit.Entity
+	for _, e := range ee {
+		if e.Outcome == audit.Cooling {
+			cooled = append(cooled, e)
+		}
+	}
+	if len(cooled) != 1 || cooled[0].RuleID != "c" || cooled[0].To != "PAUSED" || !strings.Contains(cooled[0].Reason, "cooldown") {
+		t.Error("expected the cooled change to be audited, got ", ee)
+	}
+}
+
+// auditOf returns the unpaged audit log for the given query parameters.
+func auditOf(t *testing.T, params map[string]string) (ee []audit.Entity) {
+	out, _ := handle(test.CTX, sam.NewRequest(http.MethodGet, params))
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	} else if err := json.Unmarshal([]byte(out.Body), &ee); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestHandlePostOneBudget(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema)
+
+	var sent map[string]string
+	sam.Use(test.Lambdas{campaign.Handler: test.API(func(req events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+		if req.RequestContext.HTTP.Method == http.MethodPatch {
+			sent = req.QueryStringParameters
+			return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK}
+		}
+		b, _ := json.Marshal([]campaign.Entity{
+			{AccountID: "1", ID: "winner", Stated: campaign.Active, Spend: "60", ROI: 40, DailyBudget: "5000"},
+			{AccountID: "1", ID: "lifetime", Stated: campaign.Active, Spend: "60", ROI: 40},
+		})
+		return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Body: string(b)}
+	})})
+
+	b, _ := json.Marshal(&rule.Entity{
+		ID:         "scale",
+		Expression: "ROI > 30",
+		Budget:     &rule.Budget{Mode: rule.Percent, Amount: 20, Ceiling: 5500},
+		Nodes:      map[string][]string{"1": nil},
+	})
+
+	req := sam.NewRequest(http.MethodPost, nil)
+	req.Body = string(b)
+
+	out, _ := handle(test.CTX, req)
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	}
+
+	var res rule.Result
+	_ = json.Unmarshal([]byte(out.Body), &res)
+	if len(res.Changes) != 1 || res.Changes[0].BudgetFrom != 5000 || res.Changes[0].BudgetTo != 5500 || res.Changes[0].To != campaign.Active {
+		t.Error("unexpected changes ", out.Body)
+	}
+
+	if sent["budget"] != "5500" || sent["ID"] != "winner" || sent["ruleID"] != "scale" || sent["status"] != "" {
+		t.Error("unexpected patch ", sent)
+	}
+}
+
+func TestHandlePostAllNotifies(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema, campaign.ChangeSchema)
+
+	var patches int
+	stubCampaigns(&patches)
+
+	stub := &notify.Stub{}
+	notify.Use(notify.Webhook, stub)
+	defer notify.Use(notify.Webhook, notify.NewWebhook())
+
+	slack := notify.Channel{Kind: notify.Webhook, Target: "https://hooks.slack.com/services/T/B/X"}
+	scope := map[string][]string{"1": nil}
+	putRules(t,
+		rule.Entity{ID: "pause", Active: true, Expression: "ROI < 0", Effect: "PAUSED", Nodes: scope, Channels: []notify.Channel{slack}},
+		rule.Entity{ID: "watch", Active: true, Expression: "SPEND > 50", Nodes: scope, Channels: []notify.Channel{slack}},
+	)
+
+	for _, dry := range []bool{true, false} {
+
+		par := map[string]string{"all": ""}
+		if dry {
+			par["dry"] = ""
+		}
+
+		out, _ := handle(test.CTX, sam.NewRequest(http.MethodPost, par))
+		if out.StatusCode != http.StatusOK {
+			t.Fatal(out.StatusCode, out.Body)
+		}
+
+		var rep rule.Report
+		_ = json.Unmarshal([]byte(out.Body), &rep)
+
+		if dry && (len(rep.Notifications) != 0 || len(stub.Sent) != 0) {
+			t.Error("expected a dry run not to notify ", out.Body)
+		} else if !dry && (len(rep.Notifications) != 1 || rep.Notifications[0].Campaigns != 3 || len(rep.Notifications[0].Rules) != 2) {
+			t.Error("expected a single digest of both rules ", out.Body)
+		}
+		if len(rep.Conflicts) != 0 {
+			t.Error("expected notifications not to conflict with changes ", rep.Conflicts)
+		}
+	}
+
+	if mm := stub.Sent[slack.Target]; len(mm) != 1 || !strings.Contains(mm[0].Text, "ACTIVE → PAUSED") || !strings.Contains(mm[0].Subject, "3 campaigns") {
+		t.Error("unexpected digests ", stub.Sent)
+	}
+
+	if patches != 1 {
+		t.Error("expected only the pause rule to patch, got ", patches)
+	}
+}
+
+func TestHandlePostOneTrend(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema, snapshot.Schema)
+
+	var patches int
+	stubCampaigns(&patches)
+
+	// the winner made 40% for the last three days before today, and the loser lacks history.
+	now := time.Now()
+	var rr []types.WriteRequest
+	for i := 1; i <= 3; i++ {
+		w, _ := snapshot.Entity{CampaignID: "winner", AccountID: "1", Date: snapshot.Date(now.AddDate(0, 0, -i)), ROI: 60}.WriteRequest()
+		rr = append(rr, w)
+	}
+	_ = db.BatchWrite(test.CTX, snapshot.Table, rr)
+
+	b, _ := json.Marshal(&rule.Entity{
+		ID:         "fading",
+		Expression: "ROI > 0",
+		Trends:     []rule.Trend{{LHS: rule.ROI, Aggregate: rule.Avg, Days: 3, Mode: rule.PercentMode, Op: rule.LT, RHS: -30}},
+		Effect:     "PAUSED",
+		Nodes:      map[string][]string{"1": nil},
+	})
+
+	req := sam.NewRequest(http.MethodPost, map[string]string{"dry": ""})
+	req.Body = string(b)
+
+	out, _ := handle(test.CTX, req)
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	}
+
+	var res rule.Result
+	_ = json.Unmarshal([]byte(out.Body), &res)
+	if len(res.Changes) != 1 || res.Changes[0].CampaignID != "winner" || res.Changes[0].Triggers["ROI[avg_3d]"] != 60 || res.Changes[0].Triggers[rule.ROI] != 40 {
+		t.Error("expected the fading winner to be paused ", out.Body)
+	}
+}
+
+func TestHandlePostOneTrendInAccountTimeZone(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema, snapshot.Schema)
+
+	// the account reports insights for a day other than the UTC day, which its snapshots are keyed by.
+	sam.Use(test.Lambdas{campaign.Handler: test.API(func(req events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+		b, _ := json.Marshal([]campaign.Entity{{AccountID: "1", ID: "winner", Day: "2021-03-10", Stated: campaign.Active, Spend: "60", ROI: 40, Profit: 24}})
+		return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Body: string(b)}
+	})})
+
+	var rr []types.WriteRequest
+	for _, d := range []string{"2021-03-07", "2021-03-08", "2021-03-09", "2021-03-10"} {
+		w, _ := snapshot.Entity{CampaignID: "winner", AccountID: "1", Date: d, ROI: 60}.WriteRequest()
+		rr = append(rr, w)
+	}
+	_ = db.BatchWrite(test.CTX, snapshot.Table, rr)
+
+	b, _ := json.Marshal(&rule.Entity{
+		ID:         "fading",
+		Expression: "ROI > 0",
+		Trends:     []rule.Trend{{LHS: rule.ROI, Aggregate: rule.Avg, Days: 3, Mode: rule.PercentMode, Op: rule.LT, RHS: -30}},
+		Effect:     "PAUSED",
+		Nodes:      map[string][]string{"1": nil},
+	})
+
+	req := sam.NewRequest(http.MethodPost, map[string]string{"dry": ""})
+	req.Body = string(b)
+
+	out, _ := handle(test.CTX, req)
+
+	var res rule.Result
+	_ = json.Unmarshal([]byte(out.Body), &res)
+	if len(res.Changes) != 1 || res.Changes[0].Triggers["ROI[avg_3d]"] != 60 {
+		t.Error("expected the trend over the days before the account's today ", out.Body)
+	}
+}
+
+func TestHandlePostOneAdSetLevel(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema, campaign.ChangeSchema)
+
+	var got, patched map[string]string
+	sam.Use(test.Lambdas{adset.Handler: test.API(func(req events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+		if req.RequestContext.HTTP.Method == http.MethodPatch {
+			patched = req.QueryStringParameters
+			return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK}
+		}
+		got = req.QueryStringParameters
+		b, _ := json.Marshal([]adset.Entity{
+			{AccountID: "1", ID: "s1", Stated: campaign.Active, Spend: "80", CTR: "0.4"},
+			{AccountID: "1", ID: "s2", Stated: campaign.Active, Spend: "80", CTR: "2.5"},
+		})
+		return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Body: string(b)}
+	})})
+
+	b, _ := json.Marshal(&rule.Entity{
+		ID:         "weak-adsets",
+		Level:      rule.AdSetLevel,
+		Expression: "SPEND > 50 AND CTR < 1",
+		Effect:     "PAUSED",
+		Nodes:      map[string][]string{"1": {"s1", "s2"}},
+	})
+
+	req := sam.NewRequest(http.MethodPost, nil)
+	req.Body = string(b)
+
+	out, _ := handle(test.CTX, req)
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	}
+
+	var res rule.Result
+	_ = json.Unmarshal([]byte(out.Body), &res)
+	if got["IDs"] != "s1,s2" {
+		t.Error("expected the ad set handler to be asked for the scoped ad sets ", got)
+	} else if res.Level != rule.AdSetLevel || len(res.Changes) != 1 || res.Changes[0].CampaignID != "s1" || res.Changes[0].Error != "" {
+		t.Error("expected the weak ad set to be paused ", out.Body)
+	} else if patched["ID"] != "s1" || patched["status"] != "PAUSED" {
+		t.Error("expected the ad set handler to apply the change ", patched)
+	}
+}
+
+func TestHandlePostOneSegment(t *testing.T) {
+
+	db = repo.NewMemory(rule.Schema, audit.Schema, campaign.ChangeSchema, breakdown.Schema)
+
+	var patches int
+	stubCampaigns(&patches)
+
+	now := time.Now()
+	dd := []breakdown.Dimension{breakdown.Age}
+	for _, row := range []map[string]string{
+		{"campaign_id": "loser", "age": "18-24", "spend": "45"},
+		{"campaign_id": "winner", "age": "18-24", "spend": "5"},
+	} {
+		w, _ := breakdown.New(row, dd, snapshot.Date(now), now).WriteRequest()
+		_ = db.BatchWrite(test.CTX, breakdown.Table, []types.WriteRequest{w})
+	}
+
+	b, _ := json.Marshal(&rule.Entity{
+		ID:         "young",
+		Expression: "SPEND > 40",
+		Segment:    breakdown.Segment{breakdown.Age: "18-24"},
+		Effect:     "PAUSED",
+		Nodes:      map[string][]string{"1": nil},
+	})
+
+	req := sam.NewRequest(http.MethodPost, map[string]string{"dry": ""})
+	req.Body = string(b)
+
+	out, _ := handle(test.CTX, req)
+	if out.StatusCode != http.StatusOK {
+		t.Fatal(out.StatusCode, out.Body)
+	}
+
+	var res rule.Result
+	if _ = json.Unmarshal([]byte(out.Body), &res); len(res.Changes) != 1 || res.Changes[0].CampaignID != "loser" {
+		t.Error("expected only the campaign spending in the segment to be met ", out.Body)
+	}
+
+	b, _ = json.Marshal(&rule.Entity{ID: "young", Expression: "ROI < 0", Segment: breakdown.Segment{breakdown.Age: "18-24"}, Effect: "PAUSED"})
+	req.Body = string(b)
+	if out, _ = handle(test.CTX, req); out.StatusCode != http.StatusBadRequest {
+		t.Error("expected a segment rule on revenue metrics to be rejected, got ", out.StatusCode)
+	}
+}
@@ -18,8 +18,12 @@ import (
 	"strings"
 )
 
+// db is the store this handler reads and writes; tests replace it with an in-memory store.
+var db repo.Store
+
 func init() {
 	logs.Init()
+	db = repo.NewDynamo()
 }
 
 func handle(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
@@ -112,7 +116,7 @@ func process(ctx context.Context, request events.APIGatewayV2HTTPRequest) (err e
 		}
 	}
 
-	if err = repo.BatchWrite(ctx, sovrn.Table, rr); err != nil {
+	if err = db.BatchWrite(ctx, sovrn.Table, rr); err != nil {
 		log.WithError(err).Error("sovrn value batch write items")
 		return
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
 )
 
 var headers = map[string]string{"Access-Control-Allow-Origin": "*"} // Required when CORS enabled in API Gateway.
@@ -19,6 +20,12 @@ func Err(err error) (events.APIGatewayV2HTTPResponse, error) {
 	return worker(http.StatusBadRequest, err.Error())
 }
 
+// Throttled tells the caller to retry later because Facebook is rate limiting us.
+func Throttled(err error) (events.APIGatewayV2HTTPResponse, error) {
+	log.WithError(err).Warn()
+	return worker(http.StatusTooManyRequests, err.Error())
+}
+
 func Nada() (events.APIGatewayV2HTTPResponse, error) {
 	return Err(errors.New("nothing handled"))
 }
@@ -32,6 +39,41 @@ func JSON(v interface{}) (events.APIGatewayV2HTTPResponse, error) {
 	}
 }
 
+// MultiStatus returns v as JSON when only part of the work succeeded, v telling which part failed.
+func MultiStatus(v interface{}) (events.APIGatewayV2HTTPResponse, error) {
+	if data, err := json.Marshal(&v); err != nil {
+		return Err(err)
+	} else {
+		return worker(http.StatusMultiStatus, string(data))
+	}
+}
+
+// BadGateway returns v as JSON when every call to an upstream service failed, v telling why.
+func BadGateway(v interface{}) (events.APIGatewayV2HTTPResponse, error) {
+	if data, err := json.Marshal(&v); err != nil {
+		return Err(err)
+	} else {
+		return worker(http.StatusBadGateway, string(data))
+	}
+}
+
+// Page returns a single page of results along with the cursor of the next page, which is empty on the last page.
+func Page(v interface{}, cursor string) (events.APIGatewayV2HTTPResponse, error) {
+	return JSON(map[string]interface{}{"data": v, "cursor": cursor})
+}
+
+// Limit parses an optional page size query parameter; an empty value means no limit.
+func Limit(s string) (int32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 32); err != nil || i < 1 {
+		return 0, errors.New("invalid limit: " + s)
+	} else {
+		return int32(i), nil
+	}
+}
+
 func OK(body string) (events.APIGatewayV2HTTPResponse, error) {
 	return worker(http.StatusOK, body)
 }
@@ -0,0 +1,95 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// BatchSize is the most requests the Graph API accepts in a single batch.
+const BatchSize = 50
+
+// Call is a single request of a batch, such as a POST of the status of a campaign.
+type Call struct {
+	Method string
+	Path   string
+	Params url.Values
+}
+
+// reply is the response to a single request of a batch; a null reply means the request was not completed.
+type reply struct {
+	Code int    `json:"code"`
+	Body string `json:"body"`
+}
+
+// Batch performs the given calls through the batch endpoint, BatchSize at a time, decoding the response to each call
+// with decode, and returns the error of each call in the order of the calls. The returned error is that of a batch
+// as a whole, in which case the calls of that batch and those after it report it too.
+func (c *Client) Batch(ctx context.Context, calls []Call, decode func(i int, body []byte) error) (errs []error, err error) {
+
+	errs = make([]error, len(calls))
+
+	for from := 0; from < len(calls); from += BatchSize {
+
+		to := from + BatchSize
+		if to > len(calls) {
+			to = len(calls)
+		}
+
+		if err = c.batch(ctx, calls[from:to], from, errs, decode); err != nil {
+			for i := from; i < len(calls); i++ {
+				errs[i] = err
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (c *Client) batch(ctx context.Context, calls []Call, offset int, errs []error, decode func(int, []byte) error) error {
+
+	type request struct {
+		Method      string `json:"method"`
+		RelativeURL string `json:"relative_url"`
+		Body        string `json:"body,omitempty"`
+	}
+
+	rr := make([]request, len(calls))
+	for i, call := range calls {
+		rr[i] = request{Method: call.Method, RelativeURL: c.version + "/" + call.Path}
+		if call.Method == http.MethodGet {
+			if len(call.Params) > 0 {
+				rr[i].RelativeURL += "?" + call.Params.Encode()
+			}
+		} else {
+			rr[i].Body = call.Params.Encode()
+		}
+	}
+
+	data, err := json.Marshal(rr)
+	if err != nil {
+		return err
+	}
+
+	var replies []*reply
+	if err = c.Post(ctx, "", url.Values{"batch": {string(data)}, "include_headers": {"false"}}, &replies); err != nil {
+		return err
+	}
+
+	for i := range calls {
+		switch {
+		case i >= len(replies) || replies[i] == nil:
+			errs[offset+i] = fmt.Errorf("graph: batch request %s %s was not completed", calls[i].Method, calls[i].Path)
+		default:
+			errs[offset+i] = unmarshal(replies[i].Code, []byte(replies[i].Body), nil)
+			if errs[offset+i] == nil && decode != nil {
+				errs[offset+i] = decode(offset+i, []byte(replies[i].Body))
+			}
+		}
+	}
+
+	return nil
+}
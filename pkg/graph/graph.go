@@ -0,0 +1,340 @@
+// Package graph is a client of the Facebook Graph API.
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"plumbus/pkg/secret"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://graph.facebook.com"
+	DefaultVersion = "v12.0"
+	DefaultTimeout = 30 * time.Second
+)
+
+// Client calls the Graph API on behalf of a single access token, which it sends in the Authorization header
+// rather than the URL, along with an appsecret_proof when the app secret is known.
+type Client struct {
+	base     string
+	version  string
+	attempts int
+	http     *http.Client
+
+	auth      sync.Mutex
+	secrets   secret.Provider
+	token     string
+	appSecret string
+	appID     string
+
+	// throttled calls are retried up to retries times, waiting an exponential delay capped at maxDelay.
+	retries         int
+	delay, maxDelay time.Duration
+
+	// calls to an account using more than threshold percent of a rate limit pause for up to maxPause.
+	threshold float64
+	maxPause  time.Duration
+
+	mu    sync.Mutex
+	usage map[string]Usage
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the scheme and host of the API, which lets tests point the client at a local server.
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.base = strings.TrimSuffix(u, "/") }
+}
+
+// WithVersion sets the API version, e.g. "v12.0".
+func WithVersion(v string) Option {
+	return func(c *Client) { c.version = v }
+}
+
+// WithTimeout bounds the duration of each request.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.http.Timeout = d }
+}
+
+// WithHTTPClient replaces the underlying http.Client.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithAttempts sets how many times a request is attempted when the connection, rather than the API, fails.
+func WithAttempts(n int) Option {
+	return func(c *Client) { c.attempts = n }
+}
+
+// WithBackoff sets how many times a throttled request is retried and the bounds of the exponential delay between them.
+func WithBackoff(retries int, delay, max time.Duration) Option {
+	return func(c *Client) { c.retries, c.delay, c.maxDelay = retries, delay, max }
+}
+
+// WithThrottle sets the utilization percentage of a rate limit above which calls pause proactively,
+// and the longest such pause.
+func WithThrottle(threshold float64, max time.Duration) Option {
+	return func(c *Client) { c.threshold, c.maxPause = threshold, max }
+}
+
+// New returns a Client of the current API version configured by the given options.
+func New(oo ...Option) *Client {
+	c := &Client{
+		base:     DefaultBaseURL,
+		version:  DefaultVersion,
+		secrets:  secret.FromEnv(),
+		attempts: 5,
+		http:     &http.Client{Timeout: DefaultTimeout},
+
+		retries:   5,
+		delay:     2 * time.Second,
+		maxDelay:  time.Minute,
+		threshold: 90,
+		maxPause:  30 * time.Second,
+
+		usage: map[string]Usage{},
+	}
+	for _, o := range oo {
+		o(c)
+	}
+	return c
+}
+
+// Error is an error response of the Graph API.
+type Error struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Subcode int    `json:"error_subcode"`
+	TraceID string `json:"fbtrace_id"`
+
+	// Status is the HTTP status code of the response.
+	Status int `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Throttled() {
+		return fmt.Sprintf("%s: %s (status %d, code %d, subcode %d, fbtrace_id %s)", ErrThrottled, e.Message, e.Status, e.Code, e.Subcode, e.TraceID)
+	}
+	return fmt.Sprintf("graph: %s (status %d, code %d, subcode %d, fbtrace_id %s)", e.Message, e.Status, e.Code, e.Subcode, e.TraceID)
+}
+
+// URL returns the absolute URL of a path, e.g. "act_123/campaigns", with the given parameters but without the token.
+func (c *Client) URL(path string, params url.Values) string {
+	u := c.base + "/" + c.version + "/" + strings.TrimPrefix(path, "/")
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+// Get decodes the response to a GET of the given path into v.
+func (c *Client) Get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	return c.do(ctx, http.MethodGet, c.URL(path, params), v)
+}
+
+// Post decodes the response to a POST of the given path into v; Graph API parameters of a POST are in the query.
+func (c *Client) Post(ctx context.Context, path string, params url.Values, v interface{}) error {
+	return c.do(ctx, http.MethodPost, c.URL(path, params), v)
+}
+
+// page is a single page of an edge.
+type page struct {
+	Data   []json.RawMessage `json:"data"`
+	Paging struct {
+		Next string `json:"next"`
+	} `json:"paging"`
+}
+
+// Pages calls fn with the items of each page of an edge, following cursors until the last page or fn returns false.
+func (c *Client) Pages(ctx context.Context, path string, params url.Values, fn func(data []json.RawMessage) bool) error {
+	next := c.URL(path, params)
+	for next != "" {
+		var p page
+		if err := c.do(ctx, http.MethodGet, next, &p); err != nil {
+			return err
+		}
+		if !fn(p.Data) {
+			return nil
+		}
+		next = p.Paging.Next
+	}
+	return nil
+}
+
+// All decodes every item of every page of an edge into v, which must point to a slice.
+func (c *Client) All(ctx context.Context, path string, params url.Values, v interface{}) error {
+	var all []json.RawMessage
+	if err := c.Pages(ctx, path, params, func(data []json.RawMessage) bool {
+		all = append(all, data...)
+		return true
+	}); err != nil {
+		return err
+	}
+	if all == nil {
+		all = []json.RawMessage{}
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// do performs a request against an absolute URL, pausing first when the account nears its rate limit,
+// retrying connection failures with a linear backoff and throttling errors with an exponential backoff.
+func (c *Client) do(ctx context.Context, method, u string, v interface{}) error {
+	token, proof, err := c.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	return c.doAs(ctx, method, u, token, proof, v)
+}
+
+// doAs performs a request like do, authorized by the given token and appsecret_proof.
+func (c *Client) doAs(ctx context.Context, method, u, token, proof string, v interface{}) (err error) {
+
+	account := accountOf(u)
+
+	for attempt, retry := 1, 0; ; {
+
+		if err = sleep(ctx, c.pause(account, time.Now())); err != nil {
+			return
+		}
+
+		var wait time.Duration
+		var res *http.Response
+		if res, err = c.send(ctx, method, u, token, proof); err == nil {
+			c.record(account, res.Header, time.Now())
+			if err = decode(res, v); !errors.Is(err, ErrThrottled) || retry >= c.retries {
+				return
+			}
+			wait = c.backoff(retry)
+			retry++
+		} else if attempt >= c.attempts || ctx.Err() != nil {
+			return
+		} else {
+			wait = time.Second * time.Duration(attempt)
+			attempt++
+		}
+
+		if err = sleep(ctx, wait); err != nil {
+			return
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u, token, proof string) (*http.Response, error) {
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		// the error quotes the url, which may hold a token, so report the error without it.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return nil, fmt.Errorf("graph: %s: %w", method, ue.Err)
+		}
+		return nil, err
+	}
+
+	// the cursors of paged responses repeat the token in the next URL, which the header replaces.
+	q := parsed.Query()
+	q.Del("access_token")
+	if proof != "" {
+		q.Set("appsecret_proof", proof)
+	}
+	parsed.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "OAuth "+token)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		// the url of a transport error includes the token, so report the error without it.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return nil, fmt.Errorf("graph: %s %s: %w", method, parsed.Path, ue.Err)
+		}
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// decode reads a response into v, or into an *Error when the API reports one.
+func decode(res *http.Response, v interface{}) error {
+
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 64<<20))
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(res.StatusCode, body, v)
+}
+
+// unmarshal reads the body of a response with the given status into v, or into an *Error when the API reports one.
+func unmarshal(status int, body []byte, v interface{}) (err error) {
+
+	var e struct {
+		Error *Error `json:"error"`
+	}
+	if _ = json.Unmarshal(body, &e); e.Error != nil {
+		e.Error.Status = status
+		return e.Error
+	}
+
+	if status/100 != 2 {
+		return &Error{Message: excerpt(body), Status: status}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("graph: unable to decode %q: %w", excerpt(body), err)
+	}
+
+	return nil
+}
+
+func excerpt(body []byte) string {
+	if s := strings.TrimSpace(string(body)); len(s) > 256 {
+		return s[:256] + "..."
+	} else {
+		return s
+	}
+}
@@ -0,0 +1,298 @@
+package graph
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"plumbus/pkg/secret"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllFollowsCursors(t *testing.T) {
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.0/act_1/campaigns" || r.Header.Get("Authorization") != "OAuth secret" || r.URL.Query().Get("access_token") != "" {
+			http.Error(w, `{"error":{"message":"bad request","code":100}}`, http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprintf(w, `{"data":[{"id":"a","name":"A","status":"ACTIVE"}],"paging":{"next":"%s/v1.0/act_1/campaigns?after=x&access_token=secret"}}`, srv.URL)
+		case "x":
+			fmt.Fprint(w, `{"data":[{"id":"b","name":"B","status":"PAUSED"}],"paging":{}}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(WithBaseURL(srv.URL), WithVersion("v1.0"), WithToken("secret"))
+	cc, err := c.Campaigns(context.TODO(), "1")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(cc) != 2 || cc[0].ID != "a" || cc[1].Stated != "PAUSED" {
+		t.Error("unexpected campaigns ", cc)
+	}
+}
+
+func TestErrorDecoding(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v12.0/1":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"Invalid parameter","type":"OAuthException","code":100,"error_subcode":1487242,"fbtrace_id":"AbC"}}`)
+		default:
+			http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(WithBaseURL(srv.URL), WithToken("secret"))
+
+	var ge *Error
+	if _, err := c.Update(context.TODO(), "1", url.Values{"status": {"PAUSED"}}); !errors.As(err, &ge) {
+		t.Fatal("expected a graph error, got ", err)
+	} else if ge.Code != 100 || ge.Subcode != 1487242 || ge.TraceID != "AbC" || ge.Status != http.StatusBadRequest {
+		t.Error("unexpected error ", ge)
+	}
+
+	if _, err := c.Accounts(context.TODO(), "me"); !errors.As(err, &ge) || ge.Status != http.StatusBadGateway || ge.Message != "upstream unavailable" {
+		t.Error("expected a graph error of the status, got ", err)
+	}
+}
+
+func TestInsightsDate(t *testing.T) {
+
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		fmt.Fprint(w, `{"data":[{"campaign_id":"a","spend":"1.50"}]}`)
+	}))
+	defer srv.Close()
+
+	c := New(WithBaseURL(srv.URL))
+	for _, tt := range []struct {
+		d            Date
+		param, value string
+	}{
+		{Date{}, "date_preset", "today"},
+		{Date{Preset: "maximum"}, "date_preset", "maximum"},
+		{Date{Since: "2021-11-01", Until: "2021-11-07"}, "time_range", `{"since":"2021-11-01","until":"2021-11-07"}`},
+	} {
+		if cc, err := c.Insights(context.TODO(), "1", tt.d); err != nil || len(cc) != 1 || cc[0].Spend != "1.50" {
+			t.Error(cc, err)
+		} else if got.Get(tt.param) != tt.value || got.Get("level") != "campaign" {
+			t.Error("unexpected query ", got)
+		}
+	}
+}
+
+func TestThrottledRetriesAndUsage(t *testing.T) {
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Ad-Account-Usage", `{"acc_id_util_pct":42.5,"reset_time_duration":0}`)
+		w.Header().Set("X-Business-Use-Case-Usage", `{"99":[{"type":"ads_management","call_count":12,"total_cputime":61,"total_time":7,"estimated_time_to_regain_access":0}]}`)
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"User request limit reached","type":"OAuthException","code":17,"fbtrace_id":"T"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[],"paging":{}}`)
+	}))
+	defer srv.Close()
+
+	c := New(WithBaseURL(srv.URL), WithToken("secret"), WithBackoff(2, time.Millisecond, 5*time.Millisecond))
+	if _, err := c.Campaigns(context.TODO(), "1"); err != nil {
+		t.Fatal(err)
+	} else if calls != 3 {
+		t.Error("expected two retries, got calls ", calls)
+	}
+
+	if u, ok := c.Usage("1"); !ok || u.Percent != 61 || u.Updated.IsZero() {
+		t.Error("expected the account usage to take the highest business use case ", u)
+	} else if u, ok = c.Usage("99"); !ok || u.Percent != 61 {
+		t.Error("expected the business usage ", u)
+	} else if len(c.Utilization()) != 2 {
+		t.Error("unexpected utilization ", c.Utilization())
+	}
+
+	calls = 0
+	c = New(WithBaseURL(srv.URL), WithToken("secret"), WithBackoff(1, time.Millisecond, time.Millisecond))
+	var ge *Error
+	if _, err := c.Campaigns(context.TODO(), "1"); !errors.Is(err, ErrThrottled) || !errors.As(err, &ge) || ge.Code != 17 {
+		t.Error("expected a throttled error once retries are exhausted, got ", err)
+	} else if calls != 2 {
+		t.Error("expected a single retry, got calls ", calls)
+	}
+}
+
+func TestPause(t *testing.T) {
+
+	now := time.Now()
+	c := New(WithThrottle(80, 10*time.Second))
+	c.usage["1"] = Usage{Percent: 50, Updated: now}
+	c.usage["2"] = Usage{Percent: 90, Updated: now}
+	c.usage["3"] = Usage{Percent: 100, Regain: 5 * time.Minute, Updated: now}
+
+	for account, want := range map[string]time.Duration{"1": 0, "2": 5 * time.Second, "3": 10 * time.Second, "4": 0} {
+		if got := c.pause(account, now); got != want {
+			t.Errorf("account %s: expected a pause of %v, got %v", account, want, got)
+		}
+	}
+
+	c.usage[app] = Usage{Percent: 100, Updated: now}
+	if got := c.pause("1", now); got != 10*time.Second {
+		t.Error("expected the app usage to pause every account, got ", got)
+	}
+}
+
+func TestUpdateAllBatches(t *testing.T) {
+
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var calls []struct {
+			Method      string `json:"method"`
+			RelativeURL string `json:"relative_url"`
+			Body        string `json:"body"`
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/v12.0/" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		} else if err := json.Unmarshal([]byte(r.URL.Query().Get("batch")), &calls); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, len(calls))
+		var replies []interface{}
+		for _, c := range calls {
+			switch {
+			case c.RelativeURL == "v12.0/7":
+				replies = append(replies, map[string]interface{}{"code": 400, "body": `{"error":{"message":"Permissions error","code":200}}`})
+			case c.RelativeURL == "v12.0/8":
+				replies = append(replies, nil)
+			case c.Body == "status=PAUSED":
+				replies = append(replies, map[string]interface{}{"code": 200, "body": `{"success":true}`})
+			default:
+				replies = append(replies, map[string]interface{}{"code": 200, "body": `{"success":false}`})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(replies)
+	}))
+	defer srv.Close()
+
+	var ids []string
+	var fields []url.Values
+	for i := 0; i < 120; i++ {
+		ids = append(ids, fmt.Sprint(i))
+		fields = append(fields, url.Values{"status": {"PAUSED"}})
+	}
+	fields[9] = url.Values{"status": {"BOGUS"}}
+
+	errs, err := New(WithBaseURL(srv.URL), WithToken("secret")).UpdateAll(context.TODO(), ids, fields)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(batches) != 3 || batches[0] != BatchSize || batches[2] != 20 {
+		t.Error("expected batches of at most 50, got ", batches)
+	}
+
+	var ge *Error
+	for i, e := range errs {
+		switch i {
+		case 7:
+			if !errors.As(e, &ge) || ge.Code != 200 || ge.Status != 400 {
+				t.Error("expected the graph error of 7, got ", e)
+			}
+		case 8, 9:
+			if e == nil {
+				t.Error("expected an error for ", i)
+			}
+		default:
+			if e != nil {
+				t.Error(i, e)
+			}
+		}
+	}
+}
+
+func TestUpdateUnconfirmed(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":false}`)
+	}))
+	defer srv.Close()
+
+	if _, err := New(WithBaseURL(srv.URL), WithToken("secret")).Update(context.TODO(), "1", url.Values{"status": {"PAUSED"}}); err == nil {
+		t.Error("expected an unconfirmed update to fail")
+	}
+}
+
+func TestCredentialsFromSecrets(t *testing.T) {
+
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write([]byte("EAAB"))
+	proof := hex.EncodeToString(mac.Sum(nil))
+
+	var auth, sent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, sent = r.Header.Get("Authorization"), r.URL.Query().Get("appsecret_proof")
+		fmt.Fprint(w, `{"data":{"app_id":"1","type":"USER","is_valid":true,"expires_at":1700000000,"data_access_expires_at":0,"scopes":["ads_management"]}}`)
+	}))
+	defer srv.Close()
+
+	c := New(WithBaseURL(srv.URL), WithSecrets(secret.Stub{TokenSecret: "EAAB", AppSecret: "shh"}))
+	tkn, err := c.DebugToken(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "OAuth EAAB" || sent != proof {
+		t.Error("expected the token in the header and an appsecret_proof, got ", auth, sent)
+	} else if !tkn.Valid || tkn.Expires().Unix() != 1700000000 || !tkn.DataAccessExpires().IsZero() {
+		t.Error("unexpected token ", tkn)
+	}
+
+	c = New(WithBaseURL(srv.URL), WithToken("EAAB"), WithSecrets(secret.Stub{AppSecret: "shh"}))
+	if _, err = c.DebugToken(context.TODO()); err != nil || auth != "OAuth EAAB" || sent != proof {
+		t.Error("expected an appsecret_proof for a token set directly, got ", auth, sent, err)
+	}
+
+	c = New(WithBaseURL(srv.URL), WithSecrets(secret.Stub{TokenSecret: "EAAB"}))
+	if _, err = c.DebugToken(context.TODO()); err != nil || sent != "" {
+		t.Error("expected no appsecret_proof without an app secret, got ", sent, err)
+	}
+}
+
+func TestDebugTokenAsApp(t *testing.T) {
+
+	var auth, input string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, input = r.Header.Get("Authorization"), r.URL.Query().Get("input_token")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "upstream failure")
+	}))
+
+	c := New(WithBaseURL(srv.URL), WithAttempts(1), WithSecrets(secret.Stub{TokenSecret: "EAAB", AppSecret: "shh", AppID: "42"}))
+	_, err := c.DebugToken(context.TODO())
+	if auth != "OAuth 42|shh" || input != "EAAB" {
+		t.Error("expected the app access token in the header and the token to debug as input, got ", auth, input)
+	}
+	if err == nil || strings.Contains(err.Error(), "EAAB") {
+		t.Error("expected an error without the token, got ", err)
+	}
+
+	srv.Close()
+	if _, err = c.DebugToken(context.TODO()); err == nil || strings.Contains(err.Error(), "EAAB") {
+		t.Error("expected a transport error without the token, got ", err)
+	}
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"plumbus/pkg/model/account"
+	"plumbus/pkg/model/ad"
+	"plumbus/pkg/model/adset"
+	"plumbus/pkg/model/campaign"
+	"strings"
+)
+
+const (
+	AccountFields  = "account_id,name,account_status,created_time"
+	CampaignFields = "account_id,id,name,status,daily_budget,budget_remaining,created_time,updated_time"
+	InsightFields  = "account_id,campaign_id,date_start,clicks,impressions,spend,cpc,cpp,cpm,ctr"
+
+	AdSetFields        = "account_id,id,campaign_id,name,status,daily_budget,budget_remaining,created_time,updated_time"
+	AdSetInsightFields = "account_id,adset_id,clicks,impressions,spend,cpc,cpp,cpm,ctr"
+	AdFields           = "account_id,id,campaign_id,adset_id,name,status,created_time,updated_time"
+	AdInsightFields    = "account_id,ad_id,clicks,impressions,spend,cpc,cpp,cpm,ctr"
+)
+
+// Date selects the period of insights: a Preset such as "last_7d", or a Since and Until range of YYYY-MM-DD dates.
+// The zero Date is today.
+type Date struct {
+	Preset string
+	Since  string
+	Until  string
+}
+
+func (d Date) params(p url.Values) {
+	if d.Since != "" {
+		data, _ := json.Marshal(map[string]string{"since": d.Since, "until": d.Until})
+		p.Set("time_range", string(data))
+	} else if d.Preset != "" {
+		p.Set("date_preset", d.Preset)
+	} else {
+		p.Set("date_preset", "today")
+	}
+}
+
+// Accounts returns every ad account of a user.
+func (c *Client) Accounts(ctx context.Context, user string) (out []account.Entity, err error) {
+	err = c.All(ctx, user+"/adaccounts", url.Values{"fields": {AccountFields}}, &out)
+	return
+}
+
+// Campaigns returns the descriptions of every campaign of an ad account.
+func (c *Client) Campaigns(ctx context.Context, accountID string) (out []campaign.Entity, err error) {
+	err = c.All(ctx, "act_"+accountID+"/campaigns", url.Values{"fields": {CampaignFields}}, &out)
+	return
+}
+
+// Insights returns the campaign level insights of an ad account over the given period; the result only
+// holds the insight fields and CampaignID of each campaign.
+func (c *Client) Insights(ctx context.Context, accountID string, d Date) (out []campaign.Entity, err error) {
+	err = c.insights(ctx, accountID, "campaign", InsightFields, d, &out)
+	return
+}
+
+// AdSets returns the descriptions of every ad set of an ad account.
+func (c *Client) AdSets(ctx context.Context, accountID string) (out []adset.Entity, err error) {
+	err = c.All(ctx, "act_"+accountID+"/adsets", url.Values{"fields": {AdSetFields}}, &out)
+	return
+}
+
+// AdSetInsights returns the ad set level insights of an ad account over the given period; the result only
+// holds the insight fields and AdSetID of each ad set.
+func (c *Client) AdSetInsights(ctx context.Context, accountID string, d Date) (out []adset.Entity, err error) {
+	err = c.insights(ctx, accountID, "adset", AdSetInsightFields, d, &out)
+	return
+}
+
+// Ads returns the descriptions of every ad of an ad account.
+func (c *Client) Ads(ctx context.Context, accountID string) (out []ad.Entity, err error) {
+	err = c.All(ctx, "act_"+accountID+"/ads", url.Values{"fields": {AdFields}}, &out)
+	return
+}
+
+// AdInsights returns the ad level insights of an ad account over the given period; the result only
+// holds the insight fields and AdID of each ad.
+func (c *Client) AdInsights(ctx context.Context, accountID string, d Date) (out []ad.Entity, err error) {
+	err = c.insights(ctx, accountID, "ad", AdInsightFields, d, &out)
+	return
+}
+
+// Breakdowns returns the campaign level insights of an ad account over the given period split by the given
+// breakdowns, e.g. "age" and "gender"; each row holds the insight fields, the campaign_id and the value of each
+// breakdown by its name.
+func (c *Client) Breakdowns(ctx context.Context, accountID string, breakdowns []string, d Date) (out []map[string]string, err error) {
+	p := url.Values{"fields": {InsightFields}, "level": {"campaign"}, "breakdowns": {strings.Join(breakdowns, ",")}}
+	d.params(p)
+	err = c.All(ctx, "act_"+accountID+"/insights", p, &out)
+	return
+}
+
+func (c *Client) insights(ctx context.Context, accountID, level, fields string, d Date, v interface{}) error {
+	p := url.Values{"fields": {fields}, "level": {level}}
+	d.params(p)
+	return c.All(ctx, "act_"+accountID+"/insights", p, v)
+}
+
+// Result is the response to an update of a node.
+type Result struct {
+	Success bool `json:"success"`
+}
+
+// Update posts the given fields of a node, such as the status or daily_budget of a campaign,
+// returning an error unless Facebook confirmed the update.
+func (c *Client) Update(ctx context.Context, id string, fields url.Values) (out Result, err error) {
+	if err = c.Post(ctx, id, fields, &out); err == nil && !out.Success {
+		err = fmt.Errorf("graph: update of %s not confirmed", id)
+	}
+	return
+}
+
+// UpdateAll posts the given fields of many nodes through batch requests and returns the error of each update,
+// in the order of the IDs, which is also set when Facebook did not report success.
+func (c *Client) UpdateAll(ctx context.Context, ids []string, fields []url.Values) ([]error, error) {
+
+	calls := make([]Call, len(ids))
+	for i, id := range ids {
+		calls[i] = Call{Method: http.MethodPost, Path: id, Params: fields[i]}
+	}
+
+	return c.Batch(ctx, calls, func(i int, body []byte) error {
+		var r Result
+		if err := json.Unmarshal(body, &r); err != nil {
+			return fmt.Errorf("graph: unable to decode %q: %w", excerpt(body), err)
+		} else if !r.Success {
+			return fmt.Errorf("graph: update of %s not confirmed: %s", ids[i], excerpt(body))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"plumbus/pkg/secret"
+	"time"
+)
+
+const (
+	// TokenSecret is the name of the secret holding the long-lived access token.
+	TokenSecret = "tkn"
+
+	// AppSecret is the name of the optional secret of the app, which signs every request with an appsecret_proof.
+	AppSecret = "app_secret"
+
+	// AppID is the name of the optional secret holding the ID of the app, which along with the app secret makes up
+	// the app access token that debugs the access token.
+	AppID = "app_id"
+)
+
+// WithToken sets the access token, which is otherwise resolved from the secret named TokenSecret.
+func WithToken(t string) Option {
+	return func(c *Client) { c.token = t }
+}
+
+// WithAppSecret sets the app secret, which is otherwise resolved from the secret named AppSecret, if any.
+func WithAppSecret(s string) Option {
+	return func(c *Client) { c.appSecret = s }
+}
+
+// WithAppID sets the ID of the app, which is otherwise resolved from the secret named AppID, if any.
+func WithAppID(id string) Option {
+	return func(c *Client) { c.appID = id }
+}
+
+// WithSecrets sets the provider of the token and app secret, secret.FromEnv by default.
+func WithSecrets(p secret.Provider) Option {
+	return func(c *Client) { c.secrets = p }
+}
+
+// credentials returns the access token and its appsecret_proof, which is empty without an app secret,
+// resolving both from the secret provider on first use; without a token, requests are sent unauthenticated
+// and Facebook reports the missing token.
+func (c *Client) credentials(ctx context.Context) (token, proof string, err error) {
+
+	c.auth.Lock()
+	defer c.auth.Unlock()
+
+	if c.token == "" && c.secrets != nil {
+		if c.token, err = c.secrets.Get(ctx, TokenSecret); errors.Is(err, secret.ErrNotFound) {
+			return "", "", nil
+		} else if err != nil {
+			return "", "", fmt.Errorf("graph: no access token: %w", err)
+		}
+	}
+
+	// the app secret is resolved even for a token set by WithToken, so that its calls are proven too.
+	if c.appSecret == "" && c.secrets != nil {
+		if c.appSecret, err = c.secrets.Get(ctx, AppSecret); errors.Is(err, secret.ErrNotFound) {
+			err = nil
+		} else if err != nil {
+			return "", "", fmt.Errorf("graph: no app secret: %w", err)
+		}
+	}
+
+	if c.token != "" && c.appSecret != "" {
+		mac := hmac.New(sha256.New, []byte(c.appSecret))
+		mac.Write([]byte(c.token))
+		proof = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	return c.token, proof, nil
+}
+
+// appToken returns the app access token, the app ID and secret joined by a pipe, or empty unless both are known.
+func (c *Client) appToken(ctx context.Context) (token string, err error) {
+
+	if _, _, err = c.credentials(ctx); err != nil {
+		return
+	}
+
+	c.auth.Lock()
+	defer c.auth.Unlock()
+
+	if c.appID == "" && c.secrets != nil {
+		if c.appID, err = c.secrets.Get(ctx, AppID); errors.Is(err, secret.ErrNotFound) {
+			err = nil
+		} else if err != nil {
+			return "", fmt.Errorf("graph: no app id: %w", err)
+		}
+	}
+
+	if c.appID != "" && c.appSecret != "" {
+		token = c.appID + "|" + c.appSecret
+	}
+
+	return
+}
+
+// Token is what Facebook reports about an access token.
+type Token struct {
+	AppID       string   `json:"app_id"`
+	Application string   `json:"application"`
+	Type        string   `json:"type"`
+	Valid       bool     `json:"is_valid"`
+	Scopes      []string `json:"scopes"`
+
+	// ExpiresAt and DataAccessExpiresAt are unix times, zero when the token or its data access never expire.
+	ExpiresAt           int64 `json:"expires_at"`
+	DataAccessExpiresAt int64 `json:"data_access_expires_at"`
+}
+
+// Expires returns when the token expires, the zero time if it never does.
+func (t Token) Expires() time.Time {
+	return unix(t.ExpiresAt)
+}
+
+// DataAccessExpires returns when the token loses access to user data, the zero time if it never does.
+func (t Token) DataAccessExpires() time.Time {
+	return unix(t.DataAccessExpiresAt)
+}
+
+func unix(s int64) time.Time {
+	if s <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(s, 0).UTC()
+}
+
+// DebugToken returns what Facebook reports about the access token of this client, authorized by the app access
+// token when the app ID and secret are known, or else by the access token itself.
+func (c *Client) DebugToken(ctx context.Context) (t Token, err error) {
+
+	var token, app string
+	if token, _, err = c.credentials(ctx); err != nil {
+		return
+	} else if app, err = c.appToken(ctx); err != nil {
+		return
+	}
+
+	// Graph only takes the token to debug as the input_token parameter of the URL, so unlike the access token
+	// it cannot move to a header; errors of the client only ever report the path of a URL.
+	u := c.URL("debug_token", url.Values{"input_token": {token}})
+
+	var out struct {
+		Data Token `json:"data"`
+	}
+	if app != "" {
+		err = c.doAs(ctx, http.MethodGet, u, app, "", &out)
+	} else {
+		err = c.do(ctx, http.MethodGet, u, &out)
+	}
+	return out.Data, err
+}
@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrThrottled is matched by errors.Is for every error with which Facebook throttles a caller.
+var ErrThrottled = errors.New("graph: throttled")
+
+// throttleCodes are the error codes of the Graph API rate limits, see
+// https://developers.facebook.com/docs/graph-api/overview/rate-limiting
+var throttleCodes = map[int]bool{4: true, 17: true, 32: true, 613: true, 80000: true, 80003: true, 80004: true, 80014: true}
+
+// Throttled reports whether the error is a rate limit rather than a problem with the request.
+func (e *Error) Throttled() bool {
+	return throttleCodes[e.Code] || e.Status == http.StatusTooManyRequests
+}
+
+// Is lets errors.Is match throttling errors to ErrThrottled.
+func (e *Error) Is(target error) bool {
+	return target == ErrThrottled && e.Throttled()
+}
+
+// Usage is the utilization of a rate limit as last reported by Facebook.
+type Usage struct {
+
+	// Percent is the highest utilization, from 0 to 100, of any reported rate limit.
+	Percent float64 `json:"percent"`
+
+	// Regain is how long Facebook reports it will throttle calls, if it does.
+	Regain time.Duration `json:"regain"`
+
+	// Updated is when the usage was reported.
+	Updated time.Time `json:"updated"`
+}
+
+// app is the usage key of the app wide rate limit.
+const app = "app"
+
+// Usage returns the last reported utilization of an ad account, without its "act_" prefix, or of the app as "app".
+func (c *Client) Usage(account string) (Usage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.usage[account]
+	return u, ok
+}
+
+// Utilization returns the last reported utilization of every account and business called by this client.
+func (c *Client) Utilization() map[string]Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]Usage, len(c.usage))
+	for k, v := range c.usage {
+		out[k] = v
+	}
+	return out
+}
+
+// record parses the usage headers of a response to a call against the given account, if any.
+func (c *Client) record(account string, h http.Header, now time.Time) {
+
+	uu := map[string]Usage{}
+
+	// {"acc_id_util_pct":9.67,"reset_time_duration":0}
+	if s := h.Get("X-Ad-Account-Usage"); s != "" && account != "" {
+		var v struct {
+			Pct   float64 `json:"acc_id_util_pct"`
+			Reset int     `json:"reset_time_duration"`
+		}
+		if json.Unmarshal([]byte(s), &v) == nil {
+			uu[account] = Usage{Percent: v.Pct, Regain: time.Duration(v.Reset) * time.Second}
+		}
+	}
+
+	// {"<business id>":[{"type":"ads_insights","call_count":28,"total_cputime":25,"total_time":25,"estimated_time_to_regain_access":0}]}
+	if s := h.Get("X-Business-Use-Case-Usage"); s != "" {
+		var v map[string][]struct {
+			Calls  float64 `json:"call_count"`
+			CPU    float64 `json:"total_cputime"`
+			Time   float64 `json:"total_time"`
+			Regain int     `json:"estimated_time_to_regain_access"`
+		}
+		if json.Unmarshal([]byte(s), &v) == nil {
+			for id, ll := range v {
+				var u Usage
+				for _, l := range ll {
+					u.Percent = math.Max(u.Percent, math.Max(l.Calls, math.Max(l.CPU, l.Time)))
+					if r := time.Duration(l.Regain) * time.Minute; r > u.Regain {
+						u.Regain = r
+					}
+				}
+				uu[id] = u
+				if account != "" {
+					uu[account] = peak(uu[account], u)
+				}
+			}
+		}
+	}
+
+	// {"call_count":28,"total_cputime":25,"total_time":25}
+	if s := h.Get("X-App-Usage"); s != "" {
+		var v struct {
+			Calls float64 `json:"call_count"`
+			CPU   float64 `json:"total_cputime"`
+			Time  float64 `json:"total_time"`
+		}
+		if json.Unmarshal([]byte(s), &v) == nil {
+			uu[app] = Usage{Percent: math.Max(v.Calls, math.Max(v.CPU, v.Time))}
+		}
+	}
+
+	if len(uu) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, u := range uu {
+		u.Updated = now
+		c.usage[k] = u
+	}
+}
+
+// peak returns the higher percent and regain time of two usages.
+func peak(a, b Usage) Usage {
+	if b.Regain > a.Regain {
+		a.Regain = b.Regain
+	}
+	if b.Percent > a.Percent {
+		a.Percent = b.Percent
+	}
+	return a
+}
+
+// pause returns how long to wait before calling an account given its last usage and that of the app:
+// nothing below the threshold, then a share of the maximum pause growing with utilization,
+// or the time Facebook reports it will take to regain access.
+func (c *Client) pause(account string, now time.Time) time.Duration {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var d time.Duration
+	for _, k := range []string{account, app} {
+		u, ok := c.usage[k]
+		if !ok || k == "" {
+			continue
+		}
+		if u.Regain > 0 {
+			if left := u.Updated.Add(u.Regain).Sub(now); left > d {
+				d = left
+			}
+		} else if u.Percent >= c.threshold && c.threshold < 100 {
+			share := math.Min(1, (u.Percent-c.threshold)/(100-c.threshold))
+			if p := time.Duration(share * float64(c.maxPause)); p > d {
+				d = p
+			}
+		}
+	}
+
+	if d > c.maxPause {
+		d = c.maxPause
+	}
+
+	return d
+}
+
+// backoff returns the delay before the given retry of a throttled call, growing exponentially with full jitter.
+func (c *Client) backoff(retry int) time.Duration {
+	d := c.delay << uint(retry)
+	if d <= 0 || d > c.maxDelay {
+		d = c.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// accountOf returns the ad account ID of a request path such as /v12.0/act_123/insights, if any.
+func accountOf(path string) string {
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, "act_") {
+			return strings.TrimPrefix(s, "act_")
+		}
+	}
+	return ""
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/smithy-go/ptr"
 	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/repo"
 	"plumbus/pkg/util/compare"
 	"plumbus/pkg/util/pretty"
 	"strconv"
@@ -17,6 +18,9 @@ const (
 	Handler = "plumbus_accountHandler"
 )
 
+// Schema describes the key attributes of the account table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "ID"}
+
 // ByName implements sort.Interface based on the Name field.
 type ByName []Entity
 
@@ -0,0 +1,121 @@
+// Package ad models the ads of an ad set, the level at which creatives run.
+package ad
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/pretty"
+)
+
+const (
+	Table   = "plumbus_fb_ad"
+	Handler = "plumbus_adHandler"
+)
+
+// Schema describes the key attributes of the ad table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "AccountID", SortKey: "ID"}
+
+type Entity struct {
+
+	/*
+		Keys
+	*/
+
+	// AccountID is the partition key; It represents the Account which owns this ad.
+	AccountID string `json:"account_id"`
+
+	// ID is the sort key and unique identifier for this entity.
+	ID string `json:"id"`
+
+	// AdID identifies the ad of insights, which do not carry an ID.
+	AdID string `json:"ad_id,omitempty"`
+
+	// CampaignID is the campaign this ad belongs to.
+	CampaignID string `json:"campaign_id"`
+
+	// AdSetID is the ad set this ad belongs to.
+	AdSetID string `json:"adset_id"`
+
+	// Named is the ad name.
+	Named string `json:"name"`
+
+	/*
+		FB Description
+	*/
+
+	// Stated is the status of the ad, see campaign.Entity.
+	Stated campaign.Status `json:"status"`
+
+	/*
+		FB Insights
+	*/
+
+	Clicks      string `json:"clicks"`
+	Impressions string `json:"impressions"`
+	Spend       string `json:"spend"`
+	CPC         string `json:"cpc"`
+	CPP         string `json:"cpp"`
+	CPM         string `json:"cpm"`
+	CTR         string `json:"ctr"`
+
+	/*
+		times
+	*/
+
+	Created   string `json:"created_time"`
+	Updated   string `json:"updated_time"`
+	Refreshed string `json:"refreshed"`
+
+	Formatted campaign.Formatted `json:"formatted" dynamodbav:"-"`
+}
+
+// Merge sets the insights of the ad from the insights of the same ad.
+func (e *Entity) Merge(in Entity) {
+	e.Clicks, e.Impressions, e.Spend = in.Clicks, in.Impressions, in.Spend
+	e.CPC, e.CPP, e.CPM, e.CTR = in.CPC, in.CPP, in.CPM, in.CTR
+}
+
+// SetRefreshed sets when the ad was last refreshed from the fb handler.
+func (e *Entity) SetRefreshed(t string) {
+	e.Refreshed = t
+}
+
+func (e *Entity) SetFormat() {
+	e.Formatted = campaign.Formatted{
+		Clicks:      pretty.Int(e.Clicks),
+		Impressions: pretty.Int(e.Impressions),
+		Spend:       pretty.USD(e.Spend),
+		CPC:         pretty.USD(e.CPC),
+		CPP:         pretty.USD(e.CPP),
+		CPM:         pretty.USD(e.CPM),
+		CTR:         pretty.Percent(e.CTR, 2),
+	}
+}
+
+// Campaign returns the ad in the shape of a campaign so that rules evaluate it like one;
+// revenue is only tracked per campaign, so the revenue, profit and ROI of an ad are zero.
+func (e Entity) Campaign() campaign.Entity {
+	return campaign.Entity{
+		AccountID:   e.AccountID,
+		ID:          e.ID,
+		Named:       e.Named,
+		Stated:      e.Stated,
+		Clicks:      e.Clicks,
+		Impressions: e.Impressions,
+		Spend:       e.Spend,
+		CPC:         e.CPC,
+		CPP:         e.CPP,
+		CPM:         e.CPM,
+		CTR:         e.CTR,
+	}
+}
+
+func (e Entity) WriteRequest() (out types.WriteRequest, err error) {
+	var item map[string]types.AttributeValue
+	if item, err = attributevalue.MarshalMap(&e); err == nil {
+		out.PutRequest = &types.PutRequest{Item: item}
+	}
+	return
+}
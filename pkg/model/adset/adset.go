@@ -0,0 +1,128 @@
+// Package adset models the ad sets of a campaign, which carry their own budget, status and insights.
+package adset
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/pretty"
+)
+
+const (
+	Table   = "plumbus_fb_adset"
+	Handler = "plumbus_adsetHandler"
+)
+
+// Schema describes the key attributes of the ad set table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "AccountID", SortKey: "ID"}
+
+type Entity struct {
+
+	/*
+		Keys
+	*/
+
+	// AccountID is the partition key; It represents the Account which owns this ad set.
+	AccountID string `json:"account_id"`
+
+	// ID is the sort key and unique identifier for this entity.
+	ID string `json:"id"`
+
+	// AdSetID identifies the ad set of insights, which do not carry an ID.
+	AdSetID string `json:"adset_id,omitempty"`
+
+	// CampaignID is the campaign this ad set belongs to.
+	CampaignID string `json:"campaign_id"`
+
+	// Named is the ad set name.
+	Named string `json:"name"`
+
+	/*
+		FB Description
+	*/
+
+	// Stated is the status of the ad set, see campaign.Entity.
+	Stated campaign.Status `json:"status"`
+
+	// DailyBudget is the daily budget of the ad set, empty when the campaign owns the budget.
+	DailyBudget string `json:"daily_budget,omitempty"`
+
+	// BudgetRemaining is the budget of the ad set left to spend.
+	BudgetRemaining string `json:"budget_remaining"`
+
+	/*
+		FB Insights
+	*/
+
+	Clicks      string `json:"clicks"`
+	Impressions string `json:"impressions"`
+	Spend       string `json:"spend"`
+	CPC         string `json:"cpc"`
+	CPP         string `json:"cpp"`
+	CPM         string `json:"cpm"`
+	CTR         string `json:"ctr"`
+
+	/*
+		times
+	*/
+
+	Created   string `json:"created_time"`
+	Updated   string `json:"updated_time"`
+	Refreshed string `json:"refreshed"`
+
+	Formatted campaign.Formatted `json:"formatted" dynamodbav:"-"`
+}
+
+// Merge sets the insights of the ad set from the insights of the same ad set.
+func (e *Entity) Merge(in Entity) {
+	e.Clicks, e.Impressions, e.Spend = in.Clicks, in.Impressions, in.Spend
+	e.CPC, e.CPP, e.CPM, e.CTR = in.CPC, in.CPP, in.CPM, in.CTR
+}
+
+// SetRefreshed sets when the ad set was last refreshed from the fb handler.
+func (e *Entity) SetRefreshed(t string) {
+	e.Refreshed = t
+}
+
+func (e *Entity) SetFormat() {
+	e.Formatted = campaign.Formatted{
+		DailyBudget:     pretty.USD(e.DailyBudget, true),
+		BudgetRemaining: pretty.USD(e.BudgetRemaining, true),
+		Clicks:          pretty.Int(e.Clicks),
+		Impressions:     pretty.Int(e.Impressions),
+		Spend:           pretty.USD(e.Spend),
+		CPC:             pretty.USD(e.CPC),
+		CPP:             pretty.USD(e.CPP),
+		CPM:             pretty.USD(e.CPM),
+		CTR:             pretty.Percent(e.CTR, 2),
+	}
+}
+
+// Campaign returns the ad set in the shape of a campaign so that rules evaluate it like one;
+// revenue is only tracked per campaign, so the revenue, profit and ROI of an ad set are zero.
+func (e Entity) Campaign() campaign.Entity {
+	return campaign.Entity{
+		AccountID:       e.AccountID,
+		ID:              e.ID,
+		Named:           e.Named,
+		Stated:          e.Stated,
+		DailyBudget:     e.DailyBudget,
+		BudgetRemaining: e.BudgetRemaining,
+		Clicks:          e.Clicks,
+		Impressions:     e.Impressions,
+		Spend:           e.Spend,
+		CPC:             e.CPC,
+		CPP:             e.CPP,
+		CPM:             e.CPM,
+		CTR:             e.CTR,
+	}
+}
+
+func (e Entity) WriteRequest() (out types.WriteRequest, err error) {
+	var item map[string]types.AttributeValue
+	if item, err = attributevalue.MarshalMap(&e); err == nil {
+		out.PutRequest = &types.PutRequest{Item: item}
+	}
+	return
+}
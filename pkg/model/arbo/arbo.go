@@ -3,6 +3,7 @@ package arbo
 import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/repo"
 	"plumbus/pkg/util/nums"
 )
 
@@ -10,12 +11,23 @@ const (
 	imgHost = "https://dwyeew221rxbg.cloudfront.net/facebook_fu/"
 	Table   = "plumbus_arbo"
 	Handler = "plumbus_arboHandler"
+
+	// DailyTable keeps the campaigns of every fetched day, while Table only holds today's.
+	DailyTable = "plumbus_arbo_daily"
 )
 
+// Schema describes the key attributes of the arbo table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "ID"}
+
+// DailySchema describes the key attributes of the daily table.
+var DailySchema = repo.Schema{Table: DailyTable, PartitionKey: "ID", SortKey: "Date"}
+
 type Payload struct {
 	Data []Entity `json:"data"`
 }
 
+// Entity is the performance of a campaign as tracked by Arbotron; its metrics are numbers even when
+// Arbotron sends them as strings, and are missing when it sends null or an empty string.
 type Entity struct {
 	Id           string      `json:"id"`
 	ID           string      `json:"cid"`
@@ -28,73 +40,72 @@ type Entity struct {
 	TargetUrl    string      `json:"target_url"`
 	Img          string      `json:"img"`
 	Named        string      `json:"name"`
-	Bid          string      `json:"bid"`
-	Budget       string      `json:"budget"`
+	Bid          nums.Number `json:"bid"`
+	Budget       nums.Number `json:"budget"`
 	Buyer        string      `json:"buyer"`
-	Spend        interface{} `json:"spend"`
-	Clicks       interface{} `json:"clicks"`
-	Ctr          interface{} `json:"ctr"`
-	Ecpc         interface{} `json:"ecpc"`
-	Simpressions interface{} `json:"simpressions"`
-	Revenue      interface{} `json:"revenue"`
-	Profit       interface{} `json:"profit"`
-	Cpm          interface{} `json:"cpm"`
-	Rimpressions interface{} `json:"rimpressions"`
-	Rps          interface{} `json:"rps"`
-	Hrps         interface{} `json:"hrps"`
-	Roi          interface{} `json:"roi"`
-	Stime        string      `json:"stime"`
+	Spend        nums.Number `json:"spend"`
+	Clicks       nums.Number `json:"clicks"`
+	Ctr          nums.Number `json:"ctr" dynamodbav:"CTR"`
+	Ecpc         nums.Number `json:"ecpc" dynamodbav:"eCPC"`
+	Simpressions nums.Number `json:"simpressions" dynamodbav:"sImpressions"`
+	Revenue      nums.Number `json:"revenue"`
+	Profit       nums.Number `json:"profit"`
+	Cpm          nums.Number `json:"cpm" dynamodbav:"CPM"`
+	Rimpressions nums.Number `json:"rimpressions" dynamodbav:"rImpressions"`
+	Rps          nums.Number `json:"rps" dynamodbav:"RPS"`
+	Hrps         nums.Number `json:"hrps" dynamodbav:"hRPS"`
+	Roi          nums.Number `json:"roi" dynamodbav:"ROI"`
+	Stime        string      `json:"stime" dynamodbav:"sTime"`
+
+	// Date is the UTC day the figures were fetched for as YYYY-MM-DD; Arbotron leaves it out.
+	Date string `json:"date,omitempty"`
 }
 
 func (e *Entity) item() map[string]types.AttributeValue {
-	return map[string]types.AttributeValue{
+	item := map[string]types.AttributeValue{
 		"ID":           &types.AttributeValueMemberS{Value: e.ID},
 		"UTM":          &types.AttributeValueMemberS{Value: e.UTM},
 		"Named":        &types.AttributeValueMemberS{Value: e.Named},
 		"Img":          &types.AttributeValueMemberS{Value: fmt.Sprintf("%s%s", imgHost, e.Img)},
-		"Bid":          attributeValue(e.Bid),
-		"Budget":       attributeValue(e.Budget),
-		"Spend":        attributeValue(e.Spend),
-		"Clicks":       attributeValue(e.Clicks),
-		"CTR":          attributeValue(e.Ctr),
-		"eCPC":         attributeValue(e.Ecpc),
-		"sImpressions": attributeValue(e.Simpressions),
-		"Revenue":      attributeValue(e.Revenue),
-		"Profit":       attributeValue(e.Profit),
-		"CPM":          attributeValue(e.Cpm),
-		"rImpressions": attributeValue(e.Rimpressions),
-		"RPS":          attributeValue(e.Rps),
-		"hRPS":         attributeValue(e.Hrps),
-		"ROI":          attributeValue(e.roi()),
+		"Bid":          e.Bid.AttributeValue(),
+		"Budget":       e.Budget.AttributeValue(),
+		"Spend":        e.Spend.AttributeValue(),
+		"Clicks":       e.Clicks.AttributeValue(),
+		"CTR":          e.Ctr.AttributeValue(),
+		"eCPC":         e.Ecpc.AttributeValue(),
+		"sImpressions": e.Simpressions.AttributeValue(),
+		"Revenue":      e.Revenue.AttributeValue(),
+		"Profit":       e.Profit.AttributeValue(),
+		"CPM":          e.Cpm.AttributeValue(),
+		"rImpressions": e.Rimpressions.AttributeValue(),
+		"RPS":          e.Rps.AttributeValue(),
+		"hRPS":         e.Hrps.AttributeValue(),
+		"ROI":          e.roi().AttributeValue(),
 		"sTime":        &types.AttributeValueMemberS{Value: e.Stime},
 	}
-}
-
-func attributeValue(v interface{}) types.AttributeValue {
-	if v == nil {
-		return &types.AttributeValueMemberNULL{Value: true}
-	} else {
-		return &types.AttributeValueMemberS{Value: fmt.Sprintf("%v", v)}
+	if e.Date != "" {
+		item["Date"] = &types.AttributeValueMemberS{Value: e.Date}
 	}
+	return item
 }
 
-func (e *Entity) roi() interface{} {
-	if e.Roi != nil && e.Roi != "" {
+func (e *Entity) roi() nums.Number {
+	if e.Roi.Valid {
 		return e.Roi
-	} else if e.Spend == nil || e.Revenue == nil {
-		return nil
+	} else if !e.Spend.Valid || !e.Revenue.Valid {
+		return nums.Number{}
 	} else {
-		spend := nums.Float64(e.Spend)
-		revenue := nums.Float64(e.Revenue)
+		spend := e.Spend.Float
+		revenue := e.Revenue.Float
 		profit := revenue - spend
 		if profit == 0 || (spend == 0 && revenue == 0) {
-			return 0
+			return nums.N(0)
 		} else if spend == 0 {
-			return 100
+			return nums.N(100)
 		} else if revenue == 0 {
-			return -100
+			return nums.N(-100)
 		} else {
-			return profit / spend * 100
+			return nums.N(profit / spend * 100)
 		}
 	}
 }
@@ -1,13 +1,96 @@
 package arbo
 
-type Client int
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/repo"
+	"sort"
+	"time"
+)
 
 const (
-	amg   Client = 155
-	cbsi  Client = 132
-	inuvo Client = 173
+	// ClientTable lists the Arbotron clients whose campaigns are fetched.
+	ClientTable = "plumbus_arbo_client"
+
+	// Layout is the format of the dates of a fetch, which are UTC days.
+	Layout = "2006-01-02"
+
+	// MaxDays bounds the days of a single fetch so that a backfill fits in one invocation.
+	MaxDays = 31
 )
 
-func Clients() []Client {
-	return []Client{amg, cbsi, inuvo}
+// ClientSchema describes the key attributes of the client table.
+var ClientSchema = repo.Schema{Table: ClientTable, PartitionKey: "ID"}
+
+// Client is an Arbotron client, whose campaigns are fetched while it is enabled.
+type Client struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Updated string `json:"updated,omitempty"`
+}
+
+func (c Client) Validate() error {
+	if c.ID <= 0 {
+		return errors.New("client id must be a positive Arbotron client id")
+	} else if c.Name == "" {
+		return errors.New("client name is required")
+	}
+	return nil
+}
+
+func (c Client) String() string {
+	return fmt.Sprintf("%s (%d)", c.Name, c.ID)
+}
+
+func (c Client) Item() (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMap(c)
+}
+
+// Enabled returns the enabled clients in id order.
+func Enabled(cc []Client) (out []Client) {
+	for _, c := range cc {
+		if c.Enabled {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return
+}
+
+// Days parses optional from and to dates into the days between them, defaulting to today, at most MaxDays.
+func Days(from, to string, now time.Time) ([]time.Time, error) {
+
+	today := now.UTC().Format(Layout)
+	if to == "" {
+		to = today
+	}
+	if from == "" {
+		from = to
+	}
+
+	start, err := time.Parse(Layout, from)
+	if err != nil {
+		return nil, errors.New("invalid from date " + from + ", must be YYYY-MM-DD")
+	}
+	end, err := time.Parse(Layout, to)
+	if err != nil {
+		return nil, errors.New("invalid to date " + to + ", must be YYYY-MM-DD")
+	}
+
+	if start.After(end) {
+		return nil, errors.New("from date " + from + " is after to date " + to)
+	} else if to > today {
+		return nil, errors.New("to date " + to + " is in the future")
+	} else if n := int(end.Sub(start).Hours()/24) + 1; n > MaxDays {
+		return nil, fmt.Errorf("%d days requested, at most %d may be fetched at once", n, MaxDays)
+	}
+
+	var dd []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		dd = append(dd, d)
+	}
+	return dd, nil
 }
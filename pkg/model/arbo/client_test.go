@@ -0,0 +1,25 @@
+package arbo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDays(t *testing.T) {
+
+	now := time.Date(2022, 3, 10, 23, 0, 0, 0, time.UTC)
+
+	if dd, err := Days("", "", now); err != nil || len(dd) != 1 || dd[0].Format(Layout) != "2022-03-10" {
+		t.Error("expected today ", dd, err)
+	}
+
+	if dd, err := Days("2022-02-27", "2022-03-02", now); err != nil || len(dd) != 4 || dd[3].Format(Layout) != "2022-03-02" {
+		t.Error("expected the days across the end of the month ", dd, err)
+	}
+
+	for _, r := range [][2]string{{"2022-03-02", "2022-03-01"}, {"2022-01-01", "2022-03-01"}, {"", "2022-03-11"}, {"03/01/2022", ""}} {
+		if _, err := Days(r[0], r[1], now); err == nil {
+			t.Error("expected ", r, " to be rejected")
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package arbo
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// excerptSize bounds the part of an unexpected body quoted in errors.
+const excerptSize = 200
+
+// Decode reads the campaigns of a response, undoing its Content-Encoding, and returns them along with the
+// size of the decoded body. Failed statuses, unknown encodings and bodies which are not a JSON payload,
+// such as an HTML error page, are errors quoting the start of the body.
+func Decode(res *http.Response) (Payload, int64, error) {
+
+	var pay Payload
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return pay, 0, fmt.Errorf("arbo: reading response: %w", err)
+	}
+
+	body, err := decode(res.Header.Get("Content-Encoding"), raw)
+	if err != nil {
+		return pay, int64(len(raw)), err
+	}
+
+	n := int64(len(body))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return pay, n, fmt.Errorf("arbo: %s: %s", res.Status, excerpt(body))
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return pay, n, fmt.Errorf("arbo: empty response (%s)", contentType(res))
+	} else if trimmed[0] == '<' {
+		return pay, n, fmt.Errorf("arbo: expected JSON, got an HTML page (%s): %s", contentType(res), excerpt(body))
+	} else if trimmed[0] != '{' {
+		return pay, n, fmt.Errorf("arbo: expected a JSON object (%s): %s", contentType(res), excerpt(body))
+	}
+
+	if err = json.Unmarshal(trimmed, &pay); err != nil {
+		return pay, n, fmt.Errorf("arbo: decoding payload: %w: %s", err, excerpt(body))
+	}
+
+	return pay, n, nil
+}
+
+// decode undoes the encodings of a Content-Encoding header, which are listed in the order they were applied.
+// A body without one that still starts like gzip is gunzipped, as Arbotron used to always gzip its responses.
+func decode(encoding string, body []byte) ([]byte, error) {
+
+	var ee []string
+	for _, e := range strings.Split(encoding, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+			ee = append(ee, e)
+		}
+	}
+
+	if len(ee) == 0 && len(body) > 1 && body[0] == 0x1f && body[1] == 0x8b {
+		ee = []string{"gzip"}
+	}
+
+	var err error
+	for i := len(ee) - 1; i >= 0; i-- {
+		var r io.Reader
+		switch ee[i] {
+		case "gzip", "x-gzip":
+			if r, err = gzip.NewReader(bytes.NewReader(body)); err != nil {
+				return nil, fmt.Errorf("arbo: decoding gzip: %w", err)
+			}
+		case "deflate":
+			// deflate is meant to be zlib wrapped, yet some servers send raw deflate
+			if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+				r = flate.NewReader(bytes.NewReader(body))
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			return nil, fmt.Errorf("arbo: unsupported content encoding %q", ee[i])
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("arbo: decoding %s: %w", ee[i], err)
+		}
+	}
+
+	return body, nil
+}
+
+func contentType(res *http.Response) string {
+	if ct := res.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "no content type"
+}
+
+// excerpt returns the start of a body on a single line.
+func excerpt(body []byte) string {
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) <= excerptSize {
+		return s
+	}
+	s = s[:excerptSize]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "…"
+}
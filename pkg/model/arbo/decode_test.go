@@ -0,0 +1,102 @@
+package arbo
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"plumbus/pkg/util/nums"
+	"strings"
+	"testing"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func response(status int, encoding, contentType string, body []byte) *http.Response {
+	res := &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+	if encoding != "" {
+		res.Header.Set("Content-Encoding", encoding)
+	}
+	if contentType != "" {
+		res.Header.Set("Content-Type", contentType)
+	}
+	return res
+}
+
+func TestDecodeEncodings(t *testing.T) {
+
+	plain := fixture(t, "campaigns.json")
+
+	var z, f bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	_, _ = zw.Write(plain)
+	_ = zw.Close()
+	fw, _ := flate.NewWriter(&f, flate.DefaultCompression)
+	_, _ = fw.Write(plain)
+	_ = fw.Close()
+
+	for _, tc := range []struct {
+		name, encoding string
+		body           []byte
+	}{
+		{"identity", "", plain},
+		{"explicit identity", "identity", plain},
+		{"gzip", "gzip", fixture(t, "campaigns.json.gz")},
+		{"undeclared gzip", "", fixture(t, "campaigns.json.gz")},
+		{"brotli", "br", fixture(t, "campaigns.json.br")},
+		{"zlib deflate", "deflate", z.Bytes()},
+		{"raw deflate", "deflate", f.Bytes()},
+	} {
+		pay, n, err := Decode(response(http.StatusOK, tc.encoding, "text/html; charset=UTF-8", tc.body))
+		if err != nil {
+			t.Error(tc.name, ": ", err)
+		} else if len(pay.Data) != 2 || pay.Data[0].Named != "Summer Deals - US - Broad" || pay.Data[1].Spend != nums.N(0) || pay.Data[0].Spend != nums.N(123.45) {
+			t.Error(tc.name, ": unexpected payload ", pay)
+		} else if n != int64(len(plain)) {
+			t.Error(tc.name, ": expected the decoded size, got ", n)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+
+	for _, tc := range []struct {
+		name string
+		res  *http.Response
+		want string
+	}{
+		{"failed status", response(http.StatusBadGateway, "", "text/html", fixture(t, "error.html")), "Bad Gateway: <!DOCTYPE html> <html> <head><title>502"},
+		{"login page", response(http.StatusOK, "", "text/html; charset=UTF-8", fixture(t, "login.html")), "expected JSON, got an HTML page (text/html; charset=UTF-8): <!DOCTYPE html>"},
+		{"plain text", response(http.StatusOK, "", "text/plain", []byte("Invalid client")), "expected a JSON object (text/plain): Invalid client"},
+		{"empty", response(http.StatusOK, "", "", nil), "empty response (no content type)"},
+		{"truncated json", response(http.StatusOK, "", "application/json", []byte(`{"data":[{"cid":"1"`)), "decoding payload"},
+		{"bad gzip", response(http.StatusOK, "gzip", "", []byte("{}")), "decoding gzip"},
+		{"unknown encoding", response(http.StatusOK, "compress", "", []byte("{}")), `unsupported content encoding "compress"`},
+	} {
+		if _, _, err := Decode(tc.res); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("é", excerptSize)
+	if s := excerpt([]byte(long)); !strings.HasSuffix(s, "…") || len(s) > excerptSize+len("…") {
+		t.Error("expected a truncated excerpt ", s)
+	}
+}
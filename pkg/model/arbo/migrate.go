@@ -0,0 +1,38 @@
+package arbo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/util/nums"
+)
+
+// Numeric lists the attributes of an arbo item holding numbers.
+var Numeric = []string{
+	"Bid", "Budget", "Spend", "Clicks", "CTR", "eCPC", "sImpressions",
+	"Revenue", "Profit", "CPM", "rImpressions", "RPS", "hRPS", "ROI",
+}
+
+// Migrate converts the numeric attributes an item stored as strings, before numbers were stored as such,
+// into N attributes, or NULL when empty or not a number. It returns the converted item, whether anything
+// changed and the attributes which held no number.
+func Migrate(item map[string]types.AttributeValue) (out map[string]types.AttributeValue, changed bool, invalid []string) {
+
+	out = make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		out[k] = v
+	}
+
+	for _, k := range Numeric {
+		s, ok := item[k].(*types.AttributeValueMemberS)
+		if !ok {
+			continue
+		}
+		n, err := nums.ParseNumber(s.Value)
+		if err != nil {
+			invalid = append(invalid, k)
+		}
+		out[k] = n.AttributeValue()
+		changed = true
+	}
+
+	return
+}
@@ -0,0 +1,22 @@
+package arbo
+
+// Report is the outcome of fetching the campaigns of a client on a single day.
+type Report struct {
+	ClientID int    `json:"client_id"`
+	Name     string `json:"name"`
+	Date     string `json:"date"`
+	Entities int    `json:"entities"`
+	Bytes    int64  `json:"bytes"`
+
+	// Duration is how long the fetch took in milliseconds, including reading the response.
+	Duration int64  `json:"duration_ms"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Summary is the outcome of a run fetching the campaigns of many clients, of which only those fetched
+// without error are written.
+type Summary struct {
+	Written int      `json:"written"`
+	Failed  int      `json:"failed"`
+	Reports []Report `json:"reports"`
+}
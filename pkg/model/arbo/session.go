@@ -0,0 +1,341 @@
+package arbo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/secret"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Base is the Arbotron site every request is sent to unless overridden with WithBase.
+	Base = "https://arbotron.com"
+
+	// SessionTable keeps the cookies of the last login so that each invocation does not sign in again.
+	SessionTable = "plumbus_arbo_session"
+
+	// UserSecret and PassSecret name the secrets holding the Arbotron login.
+	UserSecret = "arbo_user"
+	PassSecret = "arbo_pass"
+
+	sessionID = "arbotron"
+	loginPath = "/login.php"
+	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:96.0) Gecko/20100101 Firefox/96.0"
+)
+
+// SessionSchema describes the key attributes of the session table.
+var SessionSchema = repo.Schema{Table: SessionTable, PartitionKey: "ID"}
+
+// ErrLogin is returned when Arbotron still serves its login page after signing in.
+var ErrLogin = errors.New("arbo: login failed")
+
+// Cookie is a session cookie as persisted between invocations.
+type Cookie struct {
+	Name  string
+	Value string
+}
+
+type stored struct {
+	ID      string
+	Cookies []Cookie
+	Updated string
+}
+
+// Session sends requests to Arbotron with the cookies of a signed in user, signing in with the credentials
+// of the UserSecret and PassSecret secrets whenever there is no session yet or Arbotron reports it expired.
+type Session struct {
+	Base string
+
+	db      repo.Store
+	secrets secret.Provider
+	http    *http.Client
+
+	mu     sync.Mutex
+	jar    *cookiejar.Jar
+	loaded bool
+
+	// logins counts the sign ins of this session, so that concurrent requests finding it expired sign in once.
+	logins int
+}
+
+// SessionOption configures a Session.
+type SessionOption func(*Session)
+
+// WithBase points the session at another site, such as a test server.
+func WithBase(u string) SessionOption {
+	return func(s *Session) { s.Base = strings.TrimSuffix(u, "/") }
+}
+
+// WithHTTP sets the client requests are sent with; its cookie jar is replaced by the session's.
+func WithHTTP(c *http.Client) SessionOption {
+	return func(s *Session) {
+		cc := *c
+		s.http = &cc
+	}
+}
+
+// NewSession returns a session persisting its cookies to the SessionTable of db.
+func NewSession(db repo.Store, p secret.Provider, oo ...SessionOption) *Session {
+	s := &Session{Base: Base, db: db, secrets: p, http: &http.Client{}}
+	for _, o := range oo {
+		o(s)
+	}
+	s.jar, _ = cookiejar.New(nil)
+	s.http.Jar = s.jar
+	return s
+}
+
+// Do sends a GET request with the session cookies, signing in first when there is no session and again
+// once when the response shows the session expired. It is safe for concurrent use: each request gets its
+// own jar holding the session cookies, so that cookies set along its redirects, such as the client it
+// switched to, do not leak into other requests. Session cookies Arbotron renews along the way are kept.
+func (s *Session) Do(req *http.Request) (*http.Response, error) {
+
+	ctx := req.Context()
+
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	c, logins := s.client()
+
+	res, err := c.Do(req.Clone(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if expired, err := loggedOut(res); err != nil {
+		return res, err
+	} else if !expired {
+		s.keep(ctx, c.Jar, logins)
+		return res, nil
+	}
+
+	_ = res.Body.Close()
+	log.Info("arbo session expired, signing in again")
+
+	if err = s.login(ctx, logins); err != nil {
+		return nil, err
+	}
+
+	c, logins = s.client()
+	if res, err = c.Do(req.Clone(ctx)); err != nil {
+		return nil, err
+	}
+
+	if expired, err := loggedOut(res); err != nil {
+		return nil, err
+	} else if expired {
+		_ = res.Body.Close()
+		return nil, ErrLogin
+	}
+
+	s.keep(ctx, c.Jar, logins)
+	return res, nil
+}
+
+// keep copies the session cookies Arbotron renewed in the jar of a request back into the session and persists
+// them, unless the session signed in again since the given count of logins. Only cookies the session already
+// holds are copied, which leaves those set for a single client in the jar of its request. A failure to persist
+// them is only logged, as the response was served.
+func (s *Session) keep(ctx context.Context, jar http.CookieJar, logins int) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logins != logins {
+		return
+	}
+
+	held := map[string]string{}
+	for _, c := range s.jar.Cookies(s.url()) {
+		held[c.Name] = c.Value
+	}
+
+	var renewed []*http.Cookie
+	for _, c := range jar.Cookies(s.url()) {
+		if v, ok := held[c.Name]; ok && v != c.Value {
+			renewed = append(renewed, &http.Cookie{Name: c.Name, Value: c.Value})
+		}
+	}
+
+	if len(renewed) == 0 {
+		return
+	}
+
+	s.jar.SetCookies(s.url(), renewed)
+	if err := s.save(ctx); err != nil {
+		log.WithError(err).Error("while saving the renewed arbo session")
+	}
+}
+
+// client returns a client with a jar of its own holding the session cookies, along with the count of logins
+// the cookies come from.
+func (s *Session) client() (*http.Client, int) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(s.url(), s.jar.Cookies(s.url()))
+
+	c := *s.http
+	c.Jar = jar
+	return &c, s.logins
+}
+
+// load restores the persisted cookies once, signing in when there are none.
+func (s *Session) load(ctx context.Context) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loaded {
+		return nil
+	}
+
+	var st stored
+	if err := s.db.Get(ctx, SessionTable, "ID", sessionID, &st); err != nil {
+		return fmt.Errorf("arbo: loading session: %w", err)
+	}
+
+	if len(st.Cookies) == 0 {
+		if err := s.signIn(ctx); err != nil {
+			return err
+		}
+	} else {
+		var cc []*http.Cookie
+		for _, c := range st.Cookies {
+			cc = append(cc, &http.Cookie{Name: c.Name, Value: c.Value})
+		}
+		s.jar.SetCookies(s.url(), cc)
+		log.Trace("restored arbo session of ", st.Updated)
+	}
+
+	s.loaded = true
+	return nil
+}
+
+// login signs in again unless another request already did since the given count of logins.
+func (s *Session) login(ctx context.Context, logins int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.logins != logins {
+		return nil
+	}
+	return s.signIn(ctx)
+}
+
+// signIn posts the login form and persists the cookies Arbotron sets; s.mu must be held.
+func (s *Session) signIn(ctx context.Context) error {
+
+	var err error
+
+	var user, pass string
+	if user, err = s.secrets.Get(ctx, UserSecret); err != nil {
+		return fmt.Errorf("arbo: no user: %w", err)
+	}
+	if pass, err = s.secrets.Get(ctx, PassSecret); err != nil {
+		return fmt.Errorf("arbo: no password: %w", err)
+	}
+
+	form := url.Values{"email": {user}, "password": {pass}}
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.Base+loginPath, strings.NewReader(form.Encode())); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	var res *http.Response
+	if res, err = s.http.Do(req); err != nil {
+		return fmt.Errorf("arbo: login: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode >= http.StatusBadRequest && res.StatusCode != http.StatusUnauthorized && res.StatusCode != http.StatusForbidden {
+		return fmt.Errorf("arbo: login: %s", res.Status)
+	}
+
+	if expired, err := loggedOut(res); err != nil {
+		return err
+	} else if expired || len(s.jar.Cookies(s.url())) == 0 {
+		return ErrLogin
+	}
+
+	s.logins++
+	return s.save(ctx)
+}
+
+// save persists the cookies of the jar for the next invocation.
+func (s *Session) save(ctx context.Context) error {
+
+	st := stored{ID: sessionID, Updated: time.Now().UTC().Format(time.RFC3339)}
+	for _, c := range s.jar.Cookies(s.url()) {
+		st.Cookies = append(st.Cookies, Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	item, err := attributevalue.MarshalMap(st)
+	if err != nil {
+		return err
+	}
+
+	if err = s.db.Put(ctx, &dynamodb.PutItemInput{TableName: aws.String(SessionTable), Item: item}); err != nil {
+		return fmt.Errorf("arbo: saving session: %w", err)
+	}
+
+	log.Trace("saved arbo session with ", len(st.Cookies), " cookies")
+	return nil
+}
+
+func (s *Session) url() *url.URL {
+	u, _ := url.Parse(s.Base)
+	return u
+}
+
+// loggedOut tells whether a response is Arbotron asking to sign in: a refusal, a redirect that ended on
+// the login page or an HTML page holding a password field, whatever its Content-Encoding. The body of an HTML
+// page is read and restored as received.
+func loggedOut(res *http.Response) (bool, error) {
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return true, nil
+	}
+
+	if res.Request != nil && res.Request.URL.Path == loginPath && res.Request.Method == http.MethodGet {
+		return true, nil
+	}
+
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		return false, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	// a body which cannot be decoded is no login page; Decode reports it when reading the payload.
+	page, err := decode(res.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		return false, nil
+	}
+
+	return bytes.Contains(bytes.ToLower(page), []byte(`type="password"`)), nil
+}
@@ -0,0 +1,204 @@
+package arbo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/secret"
+	"plumbus/test"
+	"sync"
+	"testing"
+	"time"
+)
+
+var amg = Client{ID: 155, Name: "amg", Enabled: true}
+
+func fetchData(t *testing.T, s *Session) string {
+	t.Helper()
+	res, err := s.Do(NewRequest(test.CTX, s.Base, amg, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	return string(data)
+}
+
+func TestSessionLogsInAndPersists(t *testing.T) {
+
+	site := &test.Arbotron{User: "u", Pass: "p", Data: map[string]string{"155": `{"data":[{"cid":"1"}]}`}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	db := repo.NewMemory(SessionSchema)
+	creds := secret.Stub{UserSecret: "u", PassSecret: "p"}
+
+	if data := fetchData(t, NewSession(db, creds, WithBase(srv.URL))); data != `{"data":[{"cid":"1"}]}` {
+		t.Error("unexpected data ", data)
+	}
+
+	var st stored
+	if err := db.Get(test.CTX, SessionTable, "ID", sessionID, &st); err != nil || len(st.Cookies) == 0 {
+		t.Fatal("expected the session to be persisted ", st, err)
+	}
+
+	// a new invocation reuses the persisted cookies without signing in again
+	fetchData(t, NewSession(db, creds, WithBase(srv.URL)))
+	if site.Logins() != 1 {
+		t.Error("expected a single login, got ", site.Logins())
+	}
+}
+
+func TestSessionReauthenticatesWhenExpired(t *testing.T) {
+
+	site := &test.Arbotron{User: "u", Pass: "p", Data: map[string]string{"155": `{"data":[]}`}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	s := NewSession(repo.NewMemory(SessionSchema), secret.Stub{UserSecret: "u", PassSecret: "p"}, WithBase(srv.URL))
+	fetchData(t, s)
+
+	site.Expire()
+	if data := fetchData(t, s); data != `{"data":[]}` {
+		t.Error("unexpected data ", data)
+	}
+	if site.Logins() != 2 {
+		t.Error("expected to sign in again, got logins ", site.Logins())
+	}
+}
+
+func TestSessionSignsInOnceWhenConcurrentRequestsExpire(t *testing.T) {
+
+	site := &test.Arbotron{User: "u", Pass: "p", Data: map[string]string{"155": `{"data":[]}`}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	s := NewSession(repo.NewMemory(SessionSchema), secret.Stub{UserSecret: "u", PassSecret: "p"}, WithBase(srv.URL))
+	fetchData(t, s)
+	site.Expire()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res, err := s.Do(NewRequest(test.CTX, s.Base, amg, time.Now())); err != nil {
+				t.Error(err)
+			} else {
+				_ = res.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if site.Logins() != 2 {
+		t.Error("expected a single login after expiry, got logins ", site.Logins())
+	}
+}
+
+func TestSessionKeepsRenewedCookies(t *testing.T) {
+
+	site := &test.Arbotron{User: "u", Pass: "p", Rotate: true, Data: map[string]string{"155": `{"data":[]}`}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	db := repo.NewMemory(SessionSchema)
+	creds := secret.Stub{UserSecret: "u", PassSecret: "p"}
+
+	s := NewSession(db, creds, WithBase(srv.URL))
+	for i := 0; i < 3; i++ {
+		fetchData(t, s)
+	}
+	if site.Logins() != 1 {
+		t.Error("expected the renewed session to be used, got logins ", site.Logins())
+	}
+
+	var st stored
+	if err := db.Get(test.CTX, SessionTable, "ID", sessionID, &st); err != nil {
+		t.Fatal(err)
+	} else if len(st.Cookies) != 1 || st.Cookies[0] != (Cookie{Name: "PHPSESSID", Value: "s1.3"}) {
+		t.Error("expected only the renewed session cookie to be persisted, got ", st.Cookies)
+	}
+
+	// a new invocation resumes the renewed session
+	fetchData(t, NewSession(db, creds, WithBase(srv.URL)))
+	if site.Logins() != 1 {
+		t.Error("expected the persisted renewed session to be used, got logins ", site.Logins())
+	}
+}
+
+func TestSessionLoginFails(t *testing.T) {
+
+	srv := httptest.NewServer(&test.Arbotron{User: "u", Pass: "p"})
+	defer srv.Close()
+
+	s := NewSession(repo.NewMemory(SessionSchema), secret.Stub{UserSecret: "u", PassSecret: "wrong"}, WithBase(srv.URL))
+	if _, err := s.Do(NewRequest(test.CTX, s.Base, amg, time.Now())); !errors.Is(err, ErrLogin) {
+		t.Error("expected a login error, got ", err)
+	}
+
+	s = NewSession(repo.NewMemory(SessionSchema), secret.Stub{}, WithBase(srv.URL))
+	if _, err := s.Do(NewRequest(test.CTX, s.Base, amg, time.Now())); !errors.Is(err, secret.ErrNotFound) {
+		t.Error("expected missing credentials, got ", err)
+	}
+}
+
+func TestLoggedOutDetectsLoginPage(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<form><input TYPE="password" name="password"></form>`))
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/nac_handler.php")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if expired, err := loggedOut(res); err != nil || !expired {
+		t.Error("expected the login page to be detected ", err)
+	}
+	if body, _ := io.ReadAll(res.Body); len(body) == 0 {
+		t.Error("expected the body to be restored")
+	}
+}
+
+func TestLoggedOutDecodesLoginPage(t *testing.T) {
+
+	var br bytes.Buffer
+	bw := brotli.NewWriter(&br)
+	_, _ = bw.Write(fixture(t, "login.html"))
+	_ = bw.Close()
+
+	for _, tc := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"br", br.Bytes()},
+		{"", fixture(t, "login.html")},
+	} {
+		res := response(http.StatusOK, tc.encoding, "text/html; charset=UTF-8", tc.body)
+		if expired, err := loggedOut(res); err != nil || !expired {
+			t.Errorf("expected the %q login page to be detected: %v", tc.encoding, err)
+		}
+		if body, _ := io.ReadAll(res.Body); !bytes.Equal(body, tc.body) {
+			t.Errorf("expected the %q body to be restored as received", tc.encoding)
+		}
+	}
+
+	// a body which cannot be decoded is left for Decode to report
+	if expired, err := loggedOut(response(http.StatusOK, "gzip", "text/html", []byte("<html>"))); err != nil || expired {
+		t.Error("expected an undecodable page not to be a login page ", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package audit models the history of rule evaluations: the changes rules effected, and every change or
+// campaign they withheld, with the reason why.
+package audit
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/rule"
+	"plumbus/pkg/repo"
+	"time"
+)
+
+const (
+	Table         = "plumbus_rule_audit"
+	CampaignIndex = "CampaignID-Evaluated-index"
+	AccountIndex  = "AccountID-Evaluated-index"
+
+	// layout is a fixed width UTC timestamp so that evaluation times sort lexically.
+	layout = "2006-01-02T15:04:05.000000000Z"
+)
+
+// Outcome is what came of a rule evaluating a campaign.
+type Outcome string
+
+const (
+	// Applied changes were effected in Facebook and the db, unless they have an Error.
+	Applied Outcome = "applied"
+
+	// Notified changes only notify; the campaign was left as is.
+	Notified Outcome = "notified"
+
+	// Cooling changes were withheld because the campaign changed within the rule cooldown.
+	Cooling Outcome = "cooling"
+
+	// Overruled changes were left to a rule of higher precedence.
+	Overruled Outcome = "overruled"
+
+	// Unmet campaigns did not meet the rule.
+	Unmet Outcome = "unmet"
+
+	// Skipped and Failed rules evaluated no campaign; their entries carry no campaign.
+	Skipped Outcome = "skipped"
+	Failed  Outcome = "failed"
+)
+
+// Schema describes the key attributes of the audit table and its indexes.
+var Schema = repo.Schema{
+	Table:        Table,
+	PartitionKey: "RuleID",
+	SortKey:      "Evaluated",
+	Indexes: []repo.Index{
+		{Name: CampaignIndex, PartitionKey: "CampaignID", SortKey: "Evaluated"},
+		{Name: AccountIndex, PartitionKey: "AccountID", SortKey: "Evaluated"},
+	},
+}
+
+// Entity records a single evaluation of a campaign by a rule, or a rule which evaluated no campaign at all.
+type Entity struct {
+
+	// RuleID is the partition key; It represents the rule which met the campaign.
+	RuleID string `json:"rule_id"`
+
+	// Evaluated is the sort key; the evaluation time followed by the campaign ID, which is unique per rule.
+	// It ends at the separator for entries without a campaign.
+	Evaluated string `json:"evaluated"`
+
+	// RuleName is the name of the rule at the time of evaluation.
+	RuleName string `json:"rule_name"`
+
+	// CampaignID is the campaign the rule evaluated; it is omitted for skipped and failed rules, which leaves them
+	// out of the campaign index.
+	CampaignID string `json:"campaign_id,omitempty" dynamodbav:",omitempty"`
+
+	// CampaignName is the name of the campaign at the time of evaluation.
+	CampaignName string `json:"campaign_name"`
+
+	// AccountID is the account which owns the campaign.
+	AccountID string `json:"account_id,omitempty" dynamodbav:",omitempty"`
+
+	// Metrics is a snapshot of every campaign metric at the time of evaluation.
+	Metrics rule.Metrics `json:"metrics"`
+
+	// From is the campaign status before the rule took effect.
+	From campaign.Status `json:"from"`
+
+	// To is the campaign status the rule attempted to effect.
+	To campaign.Status `json:"to"`
+
+	// BudgetFrom and BudgetTo are the daily budgets before and after a budget effect.
+	BudgetFrom int64 `json:"budget_from,omitempty"`
+	BudgetTo   int64 `json:"budget_to,omitempty"`
+
+	// Outcome is what came of the evaluation.
+	Outcome Outcome `json:"outcome"`
+
+	// Reason is why the change was withheld, the campaign was unmet, or the rule was skipped.
+	Reason string `json:"reason,omitempty"`
+
+	// Success is true when the change was applied in Facebook and the db.
+	Success bool `json:"success"`
+
+	// Error is why the change or the rule failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	// Timestamp is when the rule was evaluated.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// New returns an audit entity for the outcome of a rule evaluating a campaign at the given time.
+func New(r rule.Entity, o Outcome, c rule.Change, m rule.Metrics, t time.Time) Entity {
+	return Entity{
+		RuleID:       r.ID,
+		Evaluated:    stamp(t) + "#" + c.CampaignID,
+		RuleName:     r.Named,
+		CampaignID:   c.CampaignID,
+		CampaignName: c.CampaignName,
+		AccountID:    c.AccountID,
+		Metrics:      m,
+		From:         c.From,
+		To:           c.To,
+		BudgetFrom:   c.BudgetFrom,
+		BudgetTo:     c.BudgetTo,
+		Outcome:      o,
+		Reason:       c.Reason,
+		Success:      o == Applied && c.Error == "",
+		Error:        c.Error,
+		Timestamp:    t.UTC(),
+	}
+}
+
+// Rule returns an audit entity for a rule which evaluated no campaign at the given time, because it was skipped
+// or failed.
+func Rule(r rule.Entity, o Outcome, reason, err string, t time.Time) Entity {
+	return Entity{
+		RuleID:    r.ID,
+		Evaluated: stamp(t) + "#",
+		RuleName:  r.Named,
+		Outcome:   o,
+		Reason:    reason,
+		Error:     err,
+		Timestamp: t.UTC(),
+	}
+}
+
+// Range returns the inclusive bounds of the Evaluated sort key between two times.
+func Range(from, to time.Time) (string, string) {
+	return stamp(from), stamp(to) + "#~"
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format(layout)
+}
+
+func (e Entity) WriteRequest() (out types.WriteRequest, err error) {
+	var item map[string]types.AttributeValue
+	if item, err = attributevalue.MarshalMap(&e); err == nil {
+		out.PutRequest = &types.PutRequest{Item: item}
+	}
+	return
+}
@@ -0,0 +1,204 @@
+// Package breakdown models campaign insights split by Facebook breakdowns, such as age and gender or placement.
+package breakdown
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/repo"
+	"sort"
+	"strings"
+	"time"
+)
+
+const Table = "plumbus_fb_campaign_breakdown"
+
+// Schema describes the key attributes of the breakdown table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "CampaignID", SortKey: "Segment"}
+
+// Dimension is a Facebook insights breakdown.
+type Dimension string
+
+const (
+	Age               Dimension = "age"
+	Gender            Dimension = "gender"
+	PublisherPlatform Dimension = "publisher_platform"
+	PlatformPosition  Dimension = "platform_position"
+	DevicePlatform    Dimension = "device_platform"
+	Region            Dimension = "region"
+)
+
+// groups are the dimensions Facebook lets a single request combine.
+var groups = [][]Dimension{
+	{Age, Gender},
+	{PublisherPlatform, PlatformPosition, DevicePlatform},
+	{Region},
+}
+
+// Validate reports whether Facebook accepts the given dimensions in a single request: age and gender, any of the
+// placement dimensions, or region alone. platform_position also requires publisher_platform.
+func Validate(dd []Dimension) error {
+
+	if len(dd) == 0 {
+		return errors.New("Invalid Breakdowns: at least one breakdown is required")
+	}
+
+	group := -1
+	seen := map[Dimension]bool{}
+	for _, d := range dd {
+		g := groupOf(d)
+		if g < 0 {
+			return fmt.Errorf("Invalid Breakdown: [%s], must be age, gender, publisher_platform, platform_position, device_platform or region", d)
+		} else if group >= 0 && g != group {
+			return fmt.Errorf("Invalid Breakdowns: %v cannot be combined", dd)
+		} else if seen[d] {
+			return fmt.Errorf("Invalid Breakdowns: %s repeats", d)
+		}
+		group = g
+		seen[d] = true
+	}
+
+	if seen[PlatformPosition] && !seen[PublisherPlatform] {
+		return errors.New("Invalid Breakdowns: platform_position requires publisher_platform")
+	}
+
+	return nil
+}
+
+func groupOf(d Dimension) int {
+	for i, g := range groups {
+		for _, x := range g {
+			if x == d {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Parse reads a csv of dimensions, e.g. "age,gender", in a canonical order.
+func Parse(csv string) (dd []Dimension, err error) {
+	for _, s := range strings.Split(csv, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			dd = append(dd, Dimension(s))
+		}
+	}
+	sort.Slice(dd, func(i, j int) bool { return dd[i] < dd[j] })
+	return dd, Validate(dd)
+}
+
+// Join returns the dimensions as a csv, e.g. "age,gender".
+func Join(dd []Dimension) string {
+	ss := make([]string, len(dd))
+	for i, d := range dd {
+		ss[i] = string(d)
+	}
+	return strings.Join(ss, ",")
+}
+
+// Segment is the value of each dimension of a breakdown, e.g. {"age": "18-24", "gender": "female"}.
+type Segment map[Dimension]string
+
+// Key returns the segment in a canonical form, e.g. "age=18-24,gender=female".
+func (s Segment) Key() string {
+	kk := make([]string, 0, len(s))
+	for d, v := range s {
+		kk = append(kk, string(d)+"="+v)
+	}
+	sort.Strings(kk)
+	return strings.Join(kk, ",")
+}
+
+// Dimensions returns the dimensions of the segment in a canonical order.
+func (s Segment) Dimensions() (dd []Dimension) {
+	for d := range s {
+		dd = append(dd, d)
+	}
+	sort.Slice(dd, func(i, j int) bool { return dd[i] < dd[j] })
+	return
+}
+
+// Validate reports whether the segment names valid dimensions Facebook can combine, each with a value.
+func (s Segment) Validate() error {
+	for d, v := range s {
+		if v == "" {
+			return fmt.Errorf("Invalid Segment: %s has no value", d)
+		}
+	}
+	return Validate(s.Dimensions())
+}
+
+// Entity is the performance of a campaign within a single segment of a breakdown as of its last refresh.
+type Entity struct {
+
+	// CampaignID is the partition key.
+	CampaignID string `json:"campaign_id"`
+
+	// Segment is the sort key; the canonical key of Values, e.g. "age=18-24,gender=female".
+	Segment string `json:"segment"`
+
+	// AccountID is the account which owns the campaign.
+	AccountID string `json:"account_id"`
+
+	// Breakdown is the csv of the dimensions of the segment, e.g. "age,gender".
+	Breakdown string `json:"breakdown"`
+
+	// Values are the value of each dimension of the segment.
+	Values Segment `json:"values"`
+
+	// Date is the UTC day of the insights as YYYY-MM-DD.
+	Date string `json:"date"`
+
+	Clicks      string `json:"clicks"`
+	Impressions string `json:"impressions"`
+	Spend       string `json:"spend"`
+	CPC         string `json:"cpc"`
+	CPM         string `json:"cpm"`
+	CTR         string `json:"ctr"`
+
+	Refreshed time.Time `json:"refreshed"`
+}
+
+// New returns the entity of an insights row of a breakdown request, which holds the insight fields and the value
+// of each requested dimension.
+func New(row map[string]string, dd []Dimension, date string, now time.Time) Entity {
+	s := Segment{}
+	for _, d := range dd {
+		s[d] = row[string(d)]
+	}
+	return Entity{
+		CampaignID:  row["campaign_id"],
+		Segment:     s.Key(),
+		AccountID:   row["account_id"],
+		Breakdown:   Join(s.Dimensions()),
+		Values:      s,
+		Date:        date,
+		Clicks:      row["clicks"],
+		Impressions: row["impressions"],
+		Spend:       row["spend"],
+		CPC:         row["cpc"],
+		CPM:         row["cpm"],
+		CTR:         row["ctr"],
+		Refreshed:   now,
+	}
+}
+
+// Campaign returns the campaign with its insights replaced by those of this segment, so that rules evaluate the
+// segment like a campaign; revenue is only tracked per campaign, so the revenue, profit and ROI are zero.
+func (e Entity) Campaign(c campaign.Entity) campaign.Entity {
+	c.Clicks, c.Impressions, c.Spend = e.Clicks, e.Impressions, e.Spend
+	c.CPC, c.CPM, c.CTR, c.CPP = e.CPC, e.CPM, e.CTR, ""
+	c.Revenue, c.Profit, c.ROI = 0, 0, 0
+	c.Insights = nil
+	return c
+}
+
+func (e Entity) WriteRequest() (out types.WriteRequest, err error) {
+	var item map[string]types.AttributeValue
+	if item, err = attributevalue.MarshalMap(&e); err == nil {
+		out.PutRequest = &types.PutRequest{Item: item}
+	}
+	return
+}
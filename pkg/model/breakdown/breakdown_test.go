@@ -0,0 +1,37 @@
+package breakdown
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+
+	for _, csv := range []string{"age", "gender,age", "publisher_platform,platform_position", "device_platform", "region"} {
+		if _, err := Parse(csv); err != nil {
+			t.Error(csv, err)
+		}
+	}
+
+	for _, csv := range []string{"", "country", "age,region", "age,device_platform", "platform_position", "age,age"} {
+		if _, err := Parse(csv); err == nil {
+			t.Error("expected an error for ", csv)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	row := map[string]string{"campaign_id": "c1", "account_id": "1", "gender": "female", "age": "18-24", "spend": "12.5", "ctr": "0.8"}
+	e := New(row, []Dimension{Gender, Age}, "2026-10-18", time.Now())
+
+	if e.Segment != "age=18-24,gender=female" || e.Breakdown != "age,gender" || e.Values[Age] != "18-24" || e.Spend != "12.5" {
+		t.Error("unexpected entity ", e)
+	}
+
+	if s := (Segment{Gender: "female", Age: "18-24"}); s.Key() != e.Segment || s.Validate() != nil {
+		t.Error("expected the segment key to be canonical ", s.Key())
+	} else if (Segment{Age: ""}).Validate() == nil {
+		t.Error("expected a segment without a value to be invalid")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/repo"
 	"plumbus/pkg/util/nums"
 	"plumbus/pkg/util/pretty"
 	"regexp"
@@ -17,6 +18,9 @@ var (
 	statusRegexp = regexp.MustCompile("ACTIVE|PAUSED|DELETED|ARCHIVED")
 )
 
+// Schema describes the key attributes of the campaign table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "AccountID", SortKey: "ID"}
+
 type Status string
 
 const (
@@ -102,6 +106,12 @@ type Entity struct {
 	// CTR is the percentage of times people saw your ad and performed a click (all).
 	CTR string `json:"ctr"`
 
+	// Day is the day of the metrics above as YYYY-MM-DD, which is today in the time zone of the ad account.
+	Day string `json:"date_start,omitempty"`
+
+	// Insights are the metrics above over lookback windows other than today.
+	Insights map[Window]Insight `json:"insights,omitempty"`
+
 	/*
 		Arbo / Sovrn Tracking Data
 	*/
@@ -166,7 +176,7 @@ func (e *Entity) SetFormat() {
 }
 
 func (e *Entity) item() map[string]types.AttributeValue {
-	return map[string]types.AttributeValue{
+	item := map[string]types.AttributeValue{
 		"ID":              &types.AttributeValueMemberS{Value: e.ID},
 		"AccountID":       &types.AttributeValueMemberS{Value: e.AccountID},
 		"Named":           &types.AttributeValueMemberS{Value: e.Named},
@@ -188,6 +198,13 @@ func (e *Entity) item() map[string]types.AttributeValue {
 		"Profit":          &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", e.Profit)},
 		"ROI":             &types.AttributeValueMemberN{Value: fmt.Sprintf("%f", e.ROI)},
 	}
+	if e.Day != "" {
+		item["Day"] = &types.AttributeValueMemberS{Value: e.Day}
+	}
+	if len(e.Insights) > 0 {
+		item["Insights"] = insightsValue(e.Insights)
+	}
+	return item
 }
 
 func (e *Entity) WriteRequest() types.WriteRequest {
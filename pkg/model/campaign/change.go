@@ -0,0 +1,44 @@
+package campaign
+
+import (
+	"plumbus/pkg/repo"
+	"time"
+)
+
+// ChangeTable holds the most recent status or budget change of each campaign, ad set and ad, whether made by a rule
+// or a user. Changes of every level are keyed by the ID of the changed node, which Facebook keeps unique across
+// levels, so that rules look up the cooldown of any node alike.
+var ChangeTable = "plumbus_fb_campaign_change"
+
+// ChangeSchema describes the key attributes of the change table.
+var ChangeSchema = repo.Schema{Table: ChangeTable, PartitionKey: "CampaignID"}
+
+// Change records the last time a campaign, ad set or ad status or budget was changed through this system.
+type Change struct {
+
+	// CampaignID is the partition key; It represents the changed campaign, or the ad set or ad of a Level.
+	CampaignID string `json:"campaign_id"`
+
+	// Level is the level of the changed node, "adset" or "ad", and empty for campaigns.
+	Level string `json:"level,omitempty"`
+
+	// AccountID is the account which owns the campaign.
+	AccountID string `json:"account_id"`
+
+	// RuleID is the rule which made the change, or empty when the change was made manually.
+	RuleID string `json:"rule_id,omitempty"`
+
+	// Stated is the status the campaign was changed to, if the change was to its status.
+	Stated Status `json:"status,omitempty"`
+
+	// DailyBudget is the daily budget the campaign was changed to, if the change was to its budget.
+	DailyBudget string `json:"daily_budget,omitempty"`
+
+	// Changed is when the change was made.
+	Changed time.Time `json:"changed"`
+}
+
+// Manual reports whether the change was made by a user rather than a rule.
+func (c Change) Manual() bool {
+	return c.RuleID == ""
+}
@@ -0,0 +1,71 @@
+package campaign
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Window is a lookback period over which Facebook aggregates campaign insights.
+type Window string
+
+const (
+	Today     Window = "today"
+	Yesterday Window = "yesterday"
+	Last3d    Window = "last_3d"
+	Last7d    Window = "last_7d"
+	Last14d   Window = "last_14d"
+	Last30d   Window = "last_30d"
+	Lifetime  Window = "lifetime"
+)
+
+// Windows are the lookback periods stored in Entity.Insights; today is stored in the entity itself.
+var Windows = []Window{Yesterday, Last3d, Last7d, Last14d, Last30d, Lifetime}
+
+func (w Window) Validate() error {
+	if w == Today {
+		return nil
+	}
+	for _, x := range Windows {
+		if w == x {
+			return nil
+		}
+	}
+	return errors.New("Invalid Window: [" + string(w) + "], must be today, yesterday, last_3d, last_7d, last_14d, last_30d or lifetime")
+}
+
+// Preset returns the Graph API date_preset of the window.
+func (w Window) Preset() string {
+	if w == Lifetime {
+		return "maximum"
+	}
+	return string(w)
+}
+
+// Insight holds the Facebook metrics of a campaign over a single window.
+type Insight struct {
+	Clicks      string `json:"clicks"`
+	Impressions string `json:"impressions"`
+	Spend       string `json:"spend"`
+	CPC         string `json:"cpc"`
+	CPP         string `json:"cpp"`
+	CPM         string `json:"cpm"`
+	CTR         string `json:"ctr"`
+}
+
+// Insight returns the Facebook metrics of this campaign over the given window and whether they are known.
+func (e *Entity) Insight(w Window) (Insight, bool) {
+	if w == Today || w == "" {
+		return Insight{e.Clicks, e.Impressions, e.Spend, e.CPC, e.CPP, e.CPM, e.CTR}, true
+	}
+	in, ok := e.Insights[w]
+	return in, ok
+}
+
+func insightsValue(ii map[Window]Insight) types.AttributeValue {
+	av, err := attributevalue.Marshal(ii)
+	if err != nil {
+		return &types.AttributeValueMemberNULL{Value: true}
+	}
+	return av
+}
@@ -3,12 +3,15 @@ package fb
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	faas "github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/smithy-go/ptr"
 	log "github.com/sirupsen/logrus"
-	"io/ioutil"
-	"net/http"
 	"os"
+	"plumbus/pkg/graph"
+	"plumbus/pkg/model/campaign"
 	"plumbus/pkg/repo"
 	"plumbus/pkg/util/logs"
 	"strings"
@@ -16,90 +19,91 @@ import (
 )
 
 const (
-	api     = "https://graph.facebook.com/v12.0"
 	Handler = "plumbus_fbHandler"
 )
 
-type Payload struct {
-	Data []interface{} `json:"data"`
-	Page struct {
-		Next string `json:"next"`
-	} `json:"paging"`
-}
-
 func init() {
 	logs.Init()
 }
 
-func Get(url string) (data []interface{}, err error) {
-	return getAttempt(url, 1)
+// Err returns the error with which an invocation of the Handler failed, if any,
+// matching graph.ErrThrottled when Facebook throttled it.
+func Err(out *faas.InvokeOutput) error {
+	if out == nil || out.FunctionError == nil {
+		return nil
+	}
+	var e struct {
+		Message string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal(out.Payload, &e); err != nil || e.Message == "" {
+		return errors.New(*out.FunctionError)
+	}
+	if strings.HasPrefix(e.Message, graph.ErrThrottled.Error()) {
+		return throttled(e.Message)
+	}
+	return errors.New(e.Message)
 }
 
-func getAttempt(url string, attempt int) (data []interface{}, err error) {
+// throttled carries the message of a throttling error across the Lambda boundary.
+type throttled string
 
-	var res *http.Response
-	if res, err = http.Get(url); err != nil {
+func (t throttled) Error() string { return string(t) }
 
-		if attempt > 9 {
-			log.WithError(err).Error()
-			return
-		}
+func (t throttled) Is(target error) bool { return target == graph.ErrThrottled }
 
-		if !strings.Contains(err.Error(), "too many open files") &&
-			!strings.Contains(err.Error(), "no such host") {
-			log.WithError(err).Trace()
-		} else if strings.Contains(err.Error(), "connection refused") {
-			log.WithError(err).Warn()
-		}
+// Status is a change of status of a campaign through the "campaigns_status" node of the Handler,
+// and its outcome once submitted, Error being empty when Facebook confirmed it.
+type Status struct {
+	ID     string          `json:"ID"`
+	Status campaign.Status `json:"status"`
+	Error  string          `json:"error,omitempty"`
+}
 
-		time.Sleep(time.Second * time.Duration(attempt))
+// TokenStatus is the health of the Facebook token as reported by the "token" node of the Handler.
+type TokenStatus struct {
+	Valid             bool       `json:"valid"`
+	Type              string     `json:"type,omitempty"`
+	Scopes            []string   `json:"scopes,omitempty"`
+	Expires           *time.Time `json:"expires,omitempty"`
+	DataAccessExpires *time.Time `json:"data_access_expires,omitempty"`
 
-		return getAttempt(url, attempt+1)
-	}
+	// Warning explains why the token needs attention, if it does.
+	Warning string `json:"warning,omitempty"`
+}
 
-	var body []byte
-	if body, err = ioutil.ReadAll(res.Body); err != nil {
-		log.WithError(err).Error()
-		return
-	}
+// Check returns the status of a token at the given time, warning when it is invalid or it, or its access to
+// user data, expires within the given duration.
+func Check(t graph.Token, now time.Time, within time.Duration) (s TokenStatus) {
 
-	var p Payload
-	if err = json.Unmarshal(body, &p); err != nil {
-		log.WithError(err).Error()
-		return
+	s = TokenStatus{Valid: t.Valid, Type: t.Type, Scopes: t.Scopes}
+	if e := t.Expires(); !e.IsZero() {
+		s.Expires = &e
 	}
-
-	if data = append(data, p.Data...); p.Page.Next == "" {
-		return
+	if e := t.DataAccessExpires(); !e.IsZero() {
+		s.DataAccessExpires = &e
 	}
 
-	var next []interface{}
-	if next, err = Get(p.Page.Next); err != nil {
-		log.WithError(err).Error()
-		return
+	switch {
+	case !t.Valid:
+		s.Warning = "the token is invalid"
+	case s.Expires != nil && s.Expires.Sub(now) < within:
+		s.Warning = fmt.Sprintf("the token expires in %s", s.Expires.Sub(now).Round(time.Hour))
+	case s.DataAccessExpires != nil && s.DataAccessExpires.Sub(now) < within:
+		s.Warning = fmt.Sprintf("the data access of the token expires in %s", s.DataAccessExpires.Sub(now).Round(time.Hour))
 	}
 
-	data = append(data, next...)
 	return
 }
 
-func Token() string {
-	return "?access_token=" + os.Getenv("tkn")
-}
-
 func User() string {
 	return os.Getenv("usr")
 }
 
-func API() string {
-	return api
-}
-
-func AccountsToIgnore(ctx context.Context) (map[string]interface{}, error) {
+func AccountsToIgnore(ctx context.Context, db repo.Store) (map[string]interface{}, error) {
 
 	var in = dynamodb.ScanInput{TableName: ptr.String("plumbus_ignored_ad_accounts")}
 	var out interface{}
-	if err := repo.Scan(ctx, &in, &out); err != nil {
+	if err := db.Scan(ctx, &in, &out); err != nil {
 		log.WithError(err).Error()
 		return nil, err
 	}
@@ -0,0 +1,67 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// BudgetMode is how a Budget effect interprets its Amount.
+type BudgetMode string
+
+const (
+	Percent  BudgetMode = "percent"
+	Absolute BudgetMode = "absolute"
+)
+
+// Budget is a rule effect which scales the daily budget of a campaign rather than changing its status.
+// Budgets are in the minor units of the account currency, e.g. cents, as Facebook reports them.
+type Budget struct {
+
+	// Mode is either "percent" or "absolute".
+	Mode BudgetMode `json:"mode"`
+
+	// Amount is the change to the daily budget; positive amounts increase it and negative amounts decrease it.
+	Amount float64 `json:"amount"`
+
+	// Floor is the lowest daily budget the effect may set; none when zero.
+	Floor int64 `json:"floor,omitempty"`
+
+	// Ceiling is the highest daily budget the effect may set; none when zero.
+	Ceiling int64 `json:"ceiling,omitempty"`
+}
+
+func (b *Budget) Validate() error {
+	if b.Mode != Percent && b.Mode != Absolute {
+		return errors.New("Invalid Budget Mode: [" + string(b.Mode) + "], must be percent or absolute")
+	}
+	if b.Amount == 0 || (b.Mode == Percent && b.Amount <= -100) {
+		return fmt.Errorf("Invalid Budget Amount: [%v], must be non-zero and above -100 percent", b.Amount)
+	}
+	if b.Floor < 0 || b.Ceiling < 0 || (b.Ceiling > 0 && b.Ceiling < b.Floor) {
+		return fmt.Errorf("Invalid Budget Caps: [%d-%d]", b.Floor, b.Ceiling)
+	}
+	return nil
+}
+
+// Apply returns the daily budget the effect sets given the current one, rounded and clamped to the caps.
+func (b *Budget) Apply(current int64) int64 {
+
+	next := float64(current) + b.Amount
+	if b.Mode == Percent {
+		next = float64(current) * (1 + b.Amount/100)
+	}
+
+	out := int64(math.Round(next))
+	if b.Ceiling > 0 && out > b.Ceiling {
+		out = b.Ceiling
+	}
+	if out < b.Floor {
+		out = b.Floor
+	}
+	if out < 1 {
+		out = 1
+	}
+
+	return out
+}
@@ -0,0 +1,45 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestBudgetApply(t *testing.T) {
+	tests := []struct {
+		b    Budget
+		in   int64
+		want int64
+	}{
+		{Budget{Mode: Percent, Amount: 20}, 5000, 6000},
+		{Budget{Mode: Percent, Amount: -25}, 5000, 3750},
+		{Budget{Mode: Percent, Amount: 50, Ceiling: 7000}, 5000, 7000},
+		{Budget{Mode: Absolute, Amount: -4000, Floor: 2000}, 5000, 2000},
+		{Budget{Mode: Absolute, Amount: 1000}, 5000, 6000},
+		{Budget{Mode: Percent, Amount: 10}, 15, 17},
+	}
+	for _, tt := range tests {
+		if err := tt.b.Validate(); err != nil {
+			t.Error(tt.b, err)
+		} else if got := tt.b.Apply(tt.in); got != tt.want {
+			t.Error(tt.b, " expected ", tt.want, " got ", got)
+		}
+	}
+}
+
+func TestBudgetValidate(t *testing.T) {
+	for _, b := range []Budget{
+		{Mode: "double", Amount: 1},
+		{Mode: Percent},
+		{Mode: Percent, Amount: -100},
+		{Mode: Absolute, Amount: 1, Floor: -1},
+		{Mode: Absolute, Amount: 1, Floor: 10, Ceiling: 5},
+	} {
+		if err := b.Validate(); err == nil {
+			t.Error("expected an error for ", b)
+		}
+	}
+	e := Entity{Effect: "PAUSED", Budget: &Budget{Mode: Percent, Amount: 10}}
+	if err := e.Validate(); err == nil {
+		t.Error("expected an error for a rule with both effects")
+	}
+}
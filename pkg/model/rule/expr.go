@@ -0,0 +1,279 @@
+package rule
+
+import (
+	"fmt"
+	"plumbus/pkg/model/campaign"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed rule expression; see Parse.
+type Expr interface {
+
+	// Eval reports whether the given metrics satisfy the expression.
+	Eval(m Metrics) bool
+
+	// Conditions returns every comparison within the expression, in order of appearance.
+	Conditions() []Condition
+}
+
+type and struct{ l, r Expr }
+
+func (x and) Eval(m Metrics) bool     { return x.l.Eval(m) && x.r.Eval(m) }
+func (x and) Conditions() []Condition { return append(x.l.Conditions(), x.r.Conditions()...) }
+
+type or struct{ l, r Expr }
+
+func (x or) Eval(m Metrics) bool     { return x.l.Eval(m) || x.r.Eval(m) }
+func (x or) Conditions() []Condition { return append(x.l.Conditions(), x.r.Conditions()...) }
+
+type not struct{ x Expr }
+
+func (x not) Eval(m Metrics) bool     { return !x.x.Eval(m) }
+func (x not) Conditions() []Condition { return x.x.Conditions() }
+
+type comparison struct{ c Condition }
+
+func (x comparison) Eval(m Metrics) bool     { return x.c.Eval(m) }
+func (x comparison) Conditions() []Condition { return []Condition{x.c} }
+
+// metrics maps metric names, ignoring case and underscores, to their LHS.
+var metrics = func() map[string]LHS {
+	out := map[string]LHS{}
+	for l := range Values(campaign.Entity{}) {
+		out[strings.ReplaceAll(string(l), "_", "")] = l
+	}
+	return out
+}()
+
+// Parse compiles a rule expression such as "(ROI < -20 AND SPEND > 50) OR (PROFIT < -100)".
+//
+// Comparisons take the form METRIC OP NUMBER, where OP is one of >, <, >=, <=, == or !=,
+// or METRIC BETWEEN NUMBER AND NUMBER with inclusive bounds. Comparisons combine with
+// AND, OR, NOT and parentheses, where NOT binds tightest and OR loosest. Keywords and
+// metric names are case insensitive, and underscores in metric names are optional.
+// A metric may name a lookback window in brackets, e.g. SPEND[last_7d] > 100; today is the default.
+func Parse(s string) (Expr, error) {
+
+	tt, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tt: tt}
+
+	var x Expr
+	if x, err = p.or(); err != nil {
+		return nil, err
+	} else if t := p.peek(); t.kind != eof {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+
+	return x, nil
+}
+
+const (
+	eof = iota
+	word
+	number
+	operator
+	lparen
+	rparen
+	lbracket
+	rbracket
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+func tokenize(s string) (tt []token, err error) {
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tt = append(tt, token{lparen, "(", i})
+			i++
+		case c == ')':
+			tt = append(tt, token{rparen, ")", i})
+			i++
+		case c == '[':
+			tt = append(tt, token{lbracket, "[", i})
+			i++
+		case c == ']':
+			tt = append(tt, token{rbracket, "]", i})
+			i++
+		case strings.ContainsRune("<>=!", c):
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			op := Op(s[i:j])
+			if op.Validate() != nil {
+				return nil, fmt.Errorf("invalid operator %q at position %d", s[i:j], i+1)
+			}
+			tt = append(tt, token{operator, s[i:j], i})
+			i = j
+		case c == '-' || c == '+' || c == '.' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tt = append(tt, token{number, s[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tt = append(tt, token{word, s[i:j], i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i+1)
+		}
+	}
+	return append(tt, token{eof, "end of expression", len(s)}), nil
+}
+
+type parser struct {
+	tt  []token
+	pos int
+}
+
+func (p *parser) peek() token {
+	return p.tt[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tt[p.pos]
+	if t.kind != eof {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == word && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid Expression: "+format+" at position %d", append(args, t.pos+1)...)
+}
+
+func (p *parser) or() (Expr, error) {
+	l, err := p.and()
+	for err == nil && p.keyword("OR") {
+		var r Expr
+		if r, err = p.and(); err == nil {
+			l = or{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) and() (Expr, error) {
+	l, err := p.not()
+	for err == nil && p.keyword("AND") {
+		var r Expr
+		if r, err = p.not(); err == nil {
+			l = and{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.keyword("NOT") {
+		x, err := p.not()
+		return not{x}, err
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Expr, error) {
+
+	if p.peek().kind == lparen {
+		p.next()
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != rparen {
+			return nil, p.errorf(t, "expected ) but found %q", t.text)
+		}
+		return x, nil
+	}
+
+	t := p.next()
+	if t.kind != word {
+		return nil, p.errorf(t, "expected a metric but found %q", t.text)
+	}
+
+	lhs, ok := metrics[strings.ToUpper(strings.ReplaceAll(t.text, "_", ""))]
+	if !ok {
+		return nil, p.errorf(t, "unknown metric %q", t.text)
+	}
+
+	c := Condition{LHS: lhs}
+	var err error
+
+	if p.peek().kind == lbracket {
+		p.next()
+		if t = p.next(); t.kind != word {
+			return nil, p.errorf(t, "expected a window but found %q", t.text)
+		}
+		c.Window = campaign.Window(strings.ToLower(t.text))
+		if err = c.Window.Validate(); err != nil {
+			return nil, p.errorf(t, "unknown window %q", t.text)
+		} else if err = c.validateWindow(); err != nil {
+			return nil, p.errorf(t, "%s is only known for today", lhs)
+		}
+		if t = p.next(); t.kind != rbracket {
+			return nil, p.errorf(t, "expected ] but found %q", t.text)
+		}
+	}
+
+	if p.keyword(string(Between)) {
+		c.Op = Between
+		if c.RHS, err = p.number(); err != nil {
+			return nil, err
+		} else if t = p.peek(); !p.keyword("AND") {
+			return nil, p.errorf(t, "expected AND but found %q", t.text)
+		} else if c.Max, err = p.number(); err != nil {
+			return nil, err
+		}
+		return comparison{c}, c.Validate()
+	}
+
+	if t = p.next(); t.kind != operator {
+		return nil, p.errorf(t, "expected an operator but found %q", t.text)
+	}
+
+	c.Op = Op(t.text)
+	if c.RHS, err = p.number(); err != nil {
+		return nil, err
+	}
+
+	return comparison{c}, nil
+}
+
+func (p *parser) number() (float64, error) {
+	t := p.next()
+	if t.kind != number {
+		return 0, p.errorf(t, "expected a number but found %q", t.text)
+	}
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return 0, p.errorf(t, "invalid number %q", t.text)
+	}
+	return f, nil
+}
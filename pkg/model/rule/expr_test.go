@@ -0,0 +1,105 @@
+package rule
+
+import (
+	"plumbus/pkg/model/campaign"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	loser := Values(campaign.Entity{ROI: -25, Spend: "60", Profit: -15})
+	whale := Values(campaign.Entity{ROI: 10, Spend: "600", Profit: -150})
+	winner := Values(campaign.Entity{ROI: 40, Spend: "60", Profit: 24, Clicks: "1200"})
+
+	tests := []struct {
+		expr string
+		in   Metrics
+		want bool
+	}{
+		{"(ROI < -20 AND SPEND > 50) OR (PROFIT < -100)", loser, true},
+		{"(ROI < -20 AND SPEND > 50) OR (PROFIT < -100)", whale, true},
+		{"(ROI < -20 AND SPEND > 50) OR (PROFIT < -100)", winner, false},
+		{"roi between 30 and 50 and clicks >= 1200", winner, true},
+		{"NOT ROI >= 0", loser, true},
+		{"daily_budget == 0 and BudgetRemaining != 1", winner, true},
+		{"ROI > 0 OR ROI < 0 AND SPEND > 1000", loser, false},
+	}
+
+	for _, tt := range tests {
+		x, err := Parse(tt.expr)
+		if err != nil {
+			t.Error(tt.expr, err)
+		} else if got := x.Eval(tt.in); got != tt.want {
+			t.Error(tt.expr, " expected ", tt.want, " got ", got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"ROI <",
+		"ROI = 5",
+		"BOGUS > 1",
+		"(ROI > 1",
+		"ROI > 1 SPEND > 2",
+		"ROI BETWEEN 5 AND 1",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Error("expected an error for ", expr)
+		}
+	}
+}
+
+func TestEntityMetLegacyConditions(t *testing.T) {
+	e := Entity{Conditions: []Condition{{LHS: Spend, Op: GT, RHS: 50}, {LHS: ROI, Op: LT, RHS: -20}}}
+	if met, err := e.Met(Values(campaign.Entity{ROI: -25, Spend: "60"})); err != nil || !met {
+		t.Error("expected legacy conditions to be met ", err)
+	}
+	if met, _ := e.Met(Values(campaign.Entity{ROI: -25, Spend: "40"})); met {
+		t.Error("expected legacy conditions to be joined by AND")
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+
+	c := campaign.Entity{Spend: "5", Insights: map[campaign.Window]campaign.Insight{
+		campaign.Last7d:    {Spend: "350", Clicks: "90"},
+		campaign.Yesterday: {Spend: "50"},
+	}}
+	m := Values(c)
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"SPEND[last_7d] > 300 AND SPEND < 10", true},
+		{"spend[YESTERDAY] >= 50 and clicks[last_7d] < 100", true},
+		{"SPEND[today] > 300", false},
+		{"SPEND[last_30d] < 1000", false},
+	}
+
+	for _, tt := range tests {
+		x, err := Parse(tt.expr)
+		if err != nil {
+			t.Error(tt.expr, err)
+		} else if got := x.Eval(m); got != tt.want {
+			t.Error(tt.expr, " expected ", tt.want, " got ", got)
+		}
+	}
+
+	for _, expr := range []string{"SPEND[last_week] > 1", "ROI[last_7d] > 1", "SPEND[last_7d > 1", "SPEND[] > 1"} {
+		if _, err := Parse(expr); err == nil {
+			t.Error("expected an error for ", expr)
+		}
+	}
+
+	e := Entity{Expression: "SPEND[last_7d] > 300 OR ROI < 0"}
+	if tt := e.Triggers(m); len(tt) != 2 || tt["SPEND[last_7d]"] != 350 {
+		t.Error("unexpected triggers ", tt)
+	}
+
+	if err := (Condition{LHS: Profit, Op: GT, Window: campaign.Last3d}).Validate(); err == nil {
+		t.Error("expected profit to be unknown beyond today")
+	}
+}
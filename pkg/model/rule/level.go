@@ -0,0 +1,76 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is the kind of node a rule evaluates and changes.
+type Level string
+
+const (
+	CampaignLevel Level = "campaign"
+	AdSetLevel    Level = "adset"
+	AdLevel       Level = "ad"
+)
+
+// Of returns the level of a rule, which is the campaign level unless set.
+func (e *Entity) Of() Level {
+	if e.Level == "" {
+		return CampaignLevel
+	}
+	return e.Level
+}
+
+func (l Level) Validate() error {
+	switch l {
+	case "", CampaignLevel, AdSetLevel, AdLevel:
+		return nil
+	default:
+		return fmt.Errorf("Invalid Level: [%s], must be campaign, adset or ad", l)
+	}
+}
+
+// validateLevel rejects what a rule below the campaign level, or targeting a segment, cannot evaluate or change.
+func (e *Entity) validateLevel() error {
+
+	l := e.Of()
+	if err := l.Validate(); err != nil {
+		return err
+	}
+
+	if len(e.Segment) > 0 {
+		if l != CampaignLevel {
+			return fmt.Errorf("Invalid Segment: [%s], segments are only available to campaign rules", e.Segment.Key())
+		} else if err := e.Segment.Validate(); err != nil {
+			return err
+		}
+		return e.totalsOnly(fmt.Sprintf("Invalid Segment: [%s]", e.Segment.Key()))
+	}
+
+	if l == CampaignLevel {
+		return nil
+	} else if l == AdLevel && e.Budget != nil {
+		return fmt.Errorf("Invalid Level: [%s], ads have no budget", l)
+	}
+
+	return e.totalsOnly(fmt.Sprintf("Invalid Level: [%s]", l))
+}
+
+// totalsOnly rejects what is only kept for the totals of a campaign: revenue is tracked per campaign,
+// and lookback windows and daily snapshots are only stored for campaign totals.
+func (e *Entity) totalsOnly(invalid string) error {
+	if len(e.Trends) > 0 {
+		return fmt.Errorf("%s, trends are only available to campaign totals", invalid)
+	}
+	for _, k := range e.Referenced() {
+		if strings.Contains(string(k), "[") {
+			return fmt.Errorf("%s, %s needs a lookback window only available to campaign totals", invalid, k)
+		}
+		switch k {
+		case ROI, Profit, Revenue:
+			return fmt.Errorf("%s, %s is only tracked for campaign totals", invalid, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestLevelValidate(t *testing.T) {
+
+	valid := []Entity{
+		{Expression: "ROI < 0", Effect: "PAUSED"},
+		{Level: CampaignLevel, Expression: "SPEND[last_7d] > 100", Effect: "PAUSED"},
+		{Level: AdSetLevel, Expression: "SPEND > 50 AND CTR < 1", Effect: "PAUSED"},
+		{Level: AdSetLevel, Expression: "CPC > 2", Budget: &Budget{Mode: Percent, Amount: -20}},
+		{Level: AdLevel, Expression: "CLICKS < 10", Effect: "PAUSED"},
+	}
+	for _, e := range valid {
+		if err := e.Validate(); err != nil {
+			t.Error(e, err)
+		}
+	}
+
+	invalid := []Entity{
+		{Level: "creative", Expression: "SPEND > 1", Effect: "PAUSED"},
+		{Level: AdSetLevel, Expression: "ROI < 0", Effect: "PAUSED"},
+		{Level: AdSetLevel, Expression: "SPEND[last_7d] > 10", Effect: "PAUSED"},
+		{Level: AdLevel, Expression: "SPEND > 10", Budget: &Budget{Mode: Percent, Amount: 10}},
+		{Level: AdLevel, Expression: "SPEND > 10", Effect: "PAUSED", Trends: []Trend{{LHS: Spend, Aggregate: Avg, Days: 7, Mode: AbsoluteMode, Op: ">", RHS: 1}}},
+	}
+	for _, e := range invalid {
+		if err := e.Validate(); err == nil {
+			t.Error("expected an error for ", e)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package rule
+
+import (
+	"fmt"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/notify"
+	"sort"
+	"strings"
+)
+
+// Result is the outcome of evaluating a single rule against the campaigns in its scope.
+type Result struct {
+	RuleID   string   `json:"rule_id"`
+	RuleName string   `json:"rule_name"`
+	Level    Level    `json:"level,omitempty"`
+	DryRun   bool     `json:"dry_run"`
+	Changes  []Change `json:"changes"`
+	Error    string   `json:"error,omitempty"`
+
+	// Skipped is why the system did not evaluate the rule, if it did not.
+	Skipped string `json:"skipped,omitempty"`
+
+	// Cooling are changes withheld because the campaign changed within the rule cooldown.
+	Cooling []Change `json:"cooling,omitempty"`
+
+	// Overruled are changes left to a rule of higher precedence which met the same campaign in the same run.
+	Overruled []Change `json:"overruled,omitempty"`
+
+	// Unmet are the campaigns the rule evaluated without a change, each with the Reason why; they are only kept
+	// for the audit log.
+	Unmet []Change `json:"-"`
+}
+
+// Report is the outcome of evaluating every rule in a single run.
+type Report struct {
+	Results       []Result       `json:"results"`
+	Conflicts     []Conflict     `json:"conflicts"`
+	Notifications []Notification `json:"notifications,omitempty"`
+}
+
+// Notification is the outcome of sending a digest to a channel.
+type Notification struct {
+	Channel   notify.Channel `json:"channel"`
+	Rules     []string       `json:"rules"`
+	Campaigns int            `json:"campaigns"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// Conflict describes a campaign met by more than one rule in the same run, of which only the winner changes it.
+type Conflict struct {
+	AccountID    string  `json:"account_id"`
+	CampaignID   string  `json:"campaign_id"`
+	CampaignName string  `json:"campaign_name"`
+	Winner       string  `json:"winner"`
+	Claims       []Claim `json:"claims"`
+}
+
+// Claim is the status, or daily budget, a rule would change a conflicted campaign to.
+type Claim struct {
+	RuleID   string          `json:"rule_id"`
+	To       campaign.Status `json:"to"`
+	BudgetTo int64           `json:"budget_to,omitempty"`
+}
+
+// Resolve leaves each campaign to the first result which changes it, given results in order of precedence,
+// moving the change of every later result to its Overruled changes and reporting every campaign claimed more
+// than once. Noop changes never claim a campaign.
+func Resolve(rr []Result) (out []Conflict) {
+
+	index := map[string]int{}
+	for i := range rr {
+		var kept []Change
+		for _, c := range rr[i].Changes {
+			if c.Noop() {
+				kept = append(kept, c)
+				continue
+			}
+			claim := Claim{RuleID: rr[i].RuleID, To: c.To, BudgetTo: c.BudgetTo}
+			if x, ok := index[c.CampaignID]; ok {
+				out[x].Claims = append(out[x].Claims, claim)
+				c.Reason = "overruled by rule " + out[x].Winner
+				rr[i].Overruled = append(rr[i].Overruled, c)
+				continue
+			}
+			index[c.CampaignID] = len(out)
+			out = append(out, Conflict{
+				AccountID:    c.AccountID,
+				CampaignID:   c.CampaignID,
+				CampaignName: c.CampaignName,
+				Winner:       rr[i].RuleID,
+				Claims:       []Claim{claim},
+			})
+			kept = append(kept, c)
+		}
+		if rr[i].Changes = kept; kept == nil {
+			rr[i].Changes = []Change{}
+		}
+	}
+
+	conflicts := []Conflict{}
+	for _, c := range out {
+		if len(c.Claims) > 1 {
+			conflicts = append(conflicts, c)
+		}
+	}
+
+	return conflicts
+}
+
+// Change describes a campaign which met a rule and the status it was, or in a dry run would be, changed to.
+type Change struct {
+	AccountID    string          `json:"account_id"`
+	CampaignID   string          `json:"campaign_id"`
+	CampaignName string          `json:"campaign_name"`
+	From         campaign.Status `json:"from"`
+	To           campaign.Status `json:"to"`
+
+	// BudgetFrom and BudgetTo are the daily budgets before and after a budget effect; both are zero otherwise.
+	BudgetFrom int64 `json:"budget_from,omitempty"`
+	BudgetTo   int64 `json:"budget_to,omitempty"`
+
+	// Triggers are the campaign values of every metric referenced by the rule conditions.
+	Triggers Metrics `json:"triggers"`
+
+	// Error is why the change could not be applied; it is always empty in a dry run.
+	Error string `json:"error,omitempty"`
+
+	// Reason is why the change was withheld, or why the campaign did not meet the rule; it is empty for changes
+	// the rule effects.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Noop reports whether the change leaves the campaign as it was, as for rules which only notify.
+func (c Change) Noop() bool {
+	return c.From == c.To && c.BudgetTo == 0
+}
+
+// Referenced returns the keys of the metrics compared by the expression of this rule or, lacking one, its conditions,
+// followed by those compared by its trends.
+func (e *Entity) Referenced() (ll []LHS) {
+	cc := append([]Condition{}, e.Conditions...)
+	if e.Expression != "" {
+		if x, err := Parse(e.Expression); err == nil {
+			cc = x.Conditions()
+		}
+	}
+	for _, t := range e.Trends {
+		cc = append(cc, Condition{LHS: t.LHS})
+	}
+	seen := map[LHS]bool{}
+	for _, c := range cc {
+		if k := c.Key(); !seen[k] {
+			seen[k] = true
+			ll = append(ll, k)
+		}
+	}
+	return
+}
+
+// Triggers returns the subset of the given metrics referenced by this rule.
+func (e *Entity) Triggers(m Metrics) Metrics {
+	out := Metrics{}
+	for _, l := range e.Referenced() {
+		if v, ok := m[l]; ok {
+			out[l] = v
+		}
+	}
+	return out
+}
+
+// Digest summarizes the changes of the given results in a single message.
+func Digest(rr []Result) notify.Message {
+
+	var n int
+	var b strings.Builder
+	for _, r := range rr {
+		if len(r.Changes) == 0 {
+			continue
+		}
+		n += len(r.Changes)
+		name := r.RuleName
+		if name == "" {
+			name = r.RuleID
+		}
+		fmt.Fprintf(&b, "%s met %d campaigns\n", name, len(r.Changes))
+		for _, c := range r.Changes {
+			fmt.Fprintf(&b, "• %s (%s)", c.CampaignName, c.CampaignID)
+			switch {
+			case c.Error != "":
+				fmt.Fprintf(&b, " failed: %s", c.Error)
+			case c.BudgetTo > 0:
+				fmt.Fprintf(&b, " daily budget %d → %d", c.BudgetFrom, c.BudgetTo)
+			case !c.Noop():
+				fmt.Fprintf(&b, " %s → %s", c.From, c.To)
+			}
+			b.WriteString(triggers(c.Triggers))
+			b.WriteString("\n")
+		}
+	}
+
+	return notify.Message{Subject: fmt.Sprintf("Plumbus rules met %d campaigns", n), Text: b.String()}
+}
+
+func triggers(m Metrics) string {
+	if len(m) == 0 {
+		return ""
+	}
+	var ss []string
+	for l, v := range m {
+		ss = append(ss, fmt.Sprintf("%s=%v", l, v))
+	}
+	sort.Strings(ss)
+	return " [" + strings.Join(ss, ", ") + "]"
+}
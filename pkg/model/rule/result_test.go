@@ -0,0 +1,31 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestOrderAndResolve(t *testing.T) {
+
+	ee := []Entity{{ID: "b"}, {ID: "c", Priority: 2}, {ID: "a"}}
+	Order(ee)
+	if ee[0].ID != "c" || ee[1].ID != "a" || ee[2].ID != "b" {
+		t.Fatal("unexpected order ", ee)
+	}
+
+	rr := []Result{
+		{RuleID: "c", Changes: []Change{{CampaignID: "1", To: "PAUSED"}}},
+		{RuleID: "a", Changes: []Change{{CampaignID: "1", To: "ACTIVE"}, {CampaignID: "2", To: "ACTIVE"}}},
+		{RuleID: "b", Changes: []Change{{CampaignID: "1", To: "PAUSED"}}},
+	}
+
+	cc := Resolve(rr)
+	if len(cc) != 1 || cc[0].Winner != "c" || len(cc[0].Claims) != 3 || cc[0].Claims[1] != (Claim{RuleID: "a", To: "ACTIVE"}) {
+		t.Error("unexpected conflicts ", cc)
+	}
+	if len(rr[0].Changes) != 1 || len(rr[1].Changes) != 1 || rr[1].Changes[0].CampaignID != "2" || len(rr[2].Changes) != 0 {
+		t.Error("expected losing changes to be removed ", rr)
+	}
+	if len(rr[1].Overruled) != 1 || rr[1].Overruled[0].CampaignID != "1" || rr[1].Overruled[0].Reason != "overruled by rule c" || len(rr[2].Overruled) != 1 {
+		t.Error("expected losing changes to be overruled ", rr)
+	}
+}
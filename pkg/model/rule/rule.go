@@ -1,7 +1,14 @@
 package rule
 
 import (
+	"errors"
+	"fmt"
+	"plumbus/pkg/model/breakdown"
 	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/notify"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/nums"
+	"sort"
 	"time"
 )
 
@@ -11,6 +18,9 @@ const (
 
 var table = "plumbus_rule"
 
+// Schema describes the key attributes of the rule table.
+var Schema = repo.Schema{Table: table, PartitionKey: "ID"}
+
 func TableName() *string {
 	return &table
 }
@@ -30,15 +40,49 @@ type Entity struct {
 	// Active is a flag used to determine rule inclusion.
 	Active bool `json:"status"`
 
-	// Conditions are statements which much prove true to
+	// Schedule limits when an active rule is evaluated by the system; always when nil.
+	Schedule *Schedule `json:"schedule,omitempty"`
+
+	// Conditions are statements which must all prove true for the rule to take effect; they are the legacy
+	// form of Expression, where every condition is implicitly joined by AND.
 	Conditions []Condition `json:"conditions"`
 
+	// Expression is a boolean statement of campaign metrics, e.g. "(ROI < -20 AND SPEND > 50) OR PROFIT < -100".
+	// When present, it takes precedence over Conditions.
+	Expression string `json:"expression,omitempty"`
+
+	// Trends compare metrics against their own daily history; every trend must be met in addition to
+	// the conditions or expression.
+	Trends []Trend `json:"trends,omitempty"`
+
 	// Effect is the outcome of satisfactory rules on Ads.
 	Effect campaign.Status `json:"effect"`
 
-	// Nodes are a graph of Campaign ID's mapped by an Account ID.
+	// Budget is an alternative to Effect which scales the daily budget of satisfactory campaigns.
+	Budget *Budget `json:"budget,omitempty"`
+
+	// Channels receive a digest of the campaigns this rule met in each run, in addition to its effect;
+	// a rule with channels but neither Effect nor Budget only notifies.
+	Channels []notify.Channel `json:"channels,omitempty"`
+
+	// Level is whether this rule evaluates and changes campaigns, ad sets or ads; campaigns when empty.
+	Level Level `json:"level,omitempty"`
+
+	// Segment narrows a campaign rule to the insights of a breakdown segment, e.g. {"age": "18-24"}, refreshed
+	// today through the campaign handler; the rule still changes the whole campaign.
+	Segment breakdown.Segment `json:"segment,omitempty"`
+
+	// Nodes are a graph of Campaign ID's, or ad set or ad ID's as per Level, mapped by an Account ID.
 	Nodes map[string][]string `json:"scope"`
 
+	// Priority decides which rule changes a campaign matched by several rules in the same run; highest wins,
+	// and ties go to the lowest ID.
+	Priority int `json:"priority,omitempty"`
+
+	// Cooldown is the number of minutes after any change to a campaign, by a rule or a user,
+	// during which this rule leaves the campaign alone.
+	Cooldown int `json:"cooldown,omitempty"`
+
 	// Updated is the time this entity was last updated.
 	Updated time.Time `json:"updated"`
 
@@ -46,27 +90,281 @@ type Entity struct {
 	Created time.Time `json:"created"`
 }
 
+// LHS is a numeric campaign metric which conditions compare.
 type LHS string
 
 const (
-	ROI    LHS = "ROI"
-	Spend      = "SPEND"
-	Profit     = "PROFIT"
+	ROI             LHS = "ROI"
+	Spend           LHS = "SPEND"
+	Profit          LHS = "PROFIT"
+	Revenue         LHS = "REVENUE"
+	CPC             LHS = "CPC"
+	CPM             LHS = "CPM"
+	CPP             LHS = "CPP"
+	CTR             LHS = "CTR"
+	Clicks          LHS = "CLICKS"
+	Impressions     LHS = "IMPRESSIONS"
+	DailyBudget     LHS = "DAILY_BUDGET"
+	BudgetRemaining LHS = "BUDGET_REMAINING"
 )
 
+// Metrics are campaign metric values keyed by LHS.
+type Metrics map[LHS]float64
+
+// Values returns every metric of the given campaign.
+func Values(c campaign.Entity) Metrics {
+	m := Metrics{
+		ROI:             c.ROI,
+		Spend:           c.Spent(),
+		Profit:          c.Profit,
+		Revenue:         c.Revenue,
+		CPC:             nums.Float64(c.CPC),
+		CPM:             nums.Float64(c.CPM),
+		CPP:             nums.Float64(c.CPP),
+		CTR:             nums.Float64(c.CTR),
+		Clicks:          nums.Float64(c.Clicks),
+		Impressions:     nums.Float64(c.Impressions),
+		DailyBudget:     nums.Float64(c.DailyBudget),
+		BudgetRemaining: nums.Float64(c.BudgetRemaining),
+	}
+	for w, in := range c.Insights {
+		for l, v := range fbValues(in) {
+			m[windowed(l, w)] = v
+		}
+	}
+	return m
+}
+
+// fbValues returns the metrics Facebook reports over any window; revenue and budget metrics are only known for today.
+func fbValues(in campaign.Insight) Metrics {
+	return Metrics{
+		Spend:       nums.Float64(in.Spend),
+		CPC:         nums.Float64(in.CPC),
+		CPM:         nums.Float64(in.CPM),
+		CPP:         nums.Float64(in.CPP),
+		CTR:         nums.Float64(in.CTR),
+		Clicks:      nums.Float64(in.Clicks),
+		Impressions: nums.Float64(in.Impressions),
+	}
+}
+
+func (l LHS) Validate() error {
+	if _, ok := Values(campaign.Entity{})[l]; !ok {
+		return errors.New("Invalid LHS: [" + string(l) + "]")
+	}
+	return nil
+}
+
+// Op is a comparison operator.
 type Op string
 
 const (
-	GT Op = ">"
-	LT    = "<"
+	GT      Op = ">"
+	LT      Op = "<"
+	GE      Op = ">="
+	LE      Op = "<="
+	EQ      Op = "=="
+	NE      Op = "!="
+	Between Op = "BETWEEN"
 )
 
+func (o Op) Validate() error {
+	switch o {
+	case GT, LT, GE, LE, EQ, NE, Between:
+		return nil
+	default:
+		return errors.New("Invalid Op: [" + string(o) + "]")
+	}
+}
+
 type Condition struct {
 	LHS LHS     `json:"lhs"`
 	Op  Op      `json:"op"`
 	RHS float64 `json:"rhs"`
+
+	// Max is the inclusive upper bound of a BETWEEN condition, where RHS is the inclusive lower bound.
+	Max float64 `json:"max,omitempty"`
+
+	// Window is the lookback period of the compared metric; today when empty.
+	Window campaign.Window `json:"window,omitempty"`
+}
+
+// Key returns the key of the compared metric within Metrics, which is the LHS qualified by any window but today,
+// e.g. "SPEND[last_7d]".
+func (c Condition) Key() LHS {
+	return windowed(c.LHS, c.Window)
+}
+
+func windowed(l LHS, w campaign.Window) LHS {
+	if w == "" || w == campaign.Today {
+		return l
+	}
+	return l + "[" + LHS(w) + "]"
+}
+
+// Eval reports whether the given metrics satisfy the condition; a metric missing from them never does.
+func (c Condition) Eval(m Metrics) bool {
+	v, ok := m[c.Key()]
+	return ok && c.Met(v)
 }
 
 func (c Condition) Met(val float64) bool {
-	return (c.Op == GT && val > c.RHS) || (c.Op == LT && val < c.RHS)
+	switch c.Op {
+	case GT:
+		return val > c.RHS
+	case LT:
+		return val < c.RHS
+	case GE:
+		return val >= c.RHS
+	case LE:
+		return val <= c.RHS
+	case EQ:
+		return val == c.RHS
+	case NE:
+		return val != c.RHS
+	case Between:
+		return val >= c.RHS && val <= c.Max
+	default:
+		return false
+	}
+}
+
+func (c Condition) Validate() error {
+	if err := c.LHS.Validate(); err != nil {
+		return err
+	} else if err = c.Op.Validate(); err != nil {
+		return err
+	} else if c.Op == Between && c.Max < c.RHS {
+		return fmt.Errorf("Invalid BETWEEN: [%v] is greater than [%v]", c.RHS, c.Max)
+	}
+	return c.validateWindow()
+}
+
+func (c Condition) validateWindow() error {
+	if c.Window == "" {
+		return nil
+	} else if err := c.Window.Validate(); err != nil {
+		return err
+	} else if _, ok := fbValues(campaign.Insight{})[c.LHS]; !ok && c.Key() != c.LHS {
+		return errors.New("Invalid Window: [" + string(c.LHS) + "] is only known for today")
+	}
+	return nil
+}
+
+// Validate returns the first problem found with the effect, conditions or expression of this rule.
+func (e *Entity) Validate() error {
+	if e.Budget != nil {
+		if e.Effect != "" {
+			return errors.New("Invalid Effect: a rule may change either status or budget, not both")
+		} else if err := e.Budget.Validate(); err != nil {
+			return err
+		}
+	} else if e.Effect != "" || len(e.Channels) == 0 {
+		if err := e.Effect.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, c := range e.Channels {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, c := range e.Conditions {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	if e.Expression != "" {
+		if _, err := Parse(e.Expression); err != nil {
+			return err
+		}
+	}
+	for _, t := range e.Trends {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := e.validateLevel(); err != nil {
+		return err
+	}
+	if e.Cooldown < 0 {
+		return fmt.Errorf("Invalid Cooldown: [%d], must not be negative", e.Cooldown)
+	}
+	if e.Schedule != nil {
+		if err := e.Schedule.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyOnly reports whether this rule only notifies its channels rather than changing campaigns.
+func (e *Entity) NotifyOnly() bool {
+	return e.Effect == "" && e.Budget == nil
+}
+
+// Cooling reports whether a campaign last changed at the given time is still within the cooldown of this rule.
+func (e *Entity) Cooling(changed, now time.Time) bool {
+	return e.Cooldown > 0 && now.Sub(changed) < time.Duration(e.Cooldown)*time.Minute
+}
+
+// Order sorts rules by precedence, highest priority first and then by ID.
+func Order(ee []Entity) {
+	sort.SliceStable(ee, func(i, j int) bool {
+		if ee[i].Priority != ee[j].Priority {
+			return ee[i].Priority > ee[j].Priority
+		}
+		return ee[i].ID < ee[j].ID
+	})
+}
+
+// Due reports whether the system should evaluate this rule at the given instant, and if not, why.
+func (e *Entity) Due(t time.Time) (bool, string) {
+	if !e.Active {
+		return false, "inactive"
+	} else if e.Schedule != nil && !e.Schedule.In(t) {
+		return false, "outside schedule"
+	}
+	return true, ""
+}
+
+// Met reports whether the given metrics satisfy the expression of this rule or, lacking one, every condition.
+func (e *Entity) Met(m Metrics) (bool, error) {
+	if e.Expression != "" {
+		x, err := Parse(e.Expression)
+		if err != nil {
+			return false, err
+		}
+		return x.Eval(m), nil
+	}
+	for _, c := range e.Conditions {
+		if !c.Eval(m) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Days returns the number of days of history the trends of this rule need, which is zero without trends.
+func (e *Entity) Days() (n int) {
+	for _, t := range e.Trends {
+		if t.Days > n {
+			n = t.Days
+		}
+	}
+	return
+}
+
+// Trending reports whether every trend of this rule is met given today's metrics and the daily metrics before today,
+// most recent first, and returns the baseline of every trend by its key.
+func (e *Entity) Trending(m Metrics, days []Metrics) (bool, Metrics) {
+	bases := Metrics{}
+	for _, t := range e.Trends {
+		ok, base := t.Met(m, days)
+		if !ok {
+			return false, nil
+		}
+		bases[t.Key()] = base
+	}
+	return true, bases
 }
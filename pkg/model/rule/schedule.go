@@ -0,0 +1,103 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Lambda images are not guaranteed to ship zoneinfo.
+)
+
+const dateLayout = "2006-01-02"
+
+// Schedule limits when a rule is evaluated by the system; every field is optional.
+type Schedule struct {
+
+	// Days are the weekdays, e.g. "Saturday", on which the rule is evaluated; any day when empty.
+	Days []string `json:"days,omitempty"`
+
+	// From is the hour of the day, 0 through 23, at which the evaluation window opens.
+	From int `json:"from"`
+
+	// To is the hour of the day, 1 through 24, at which the evaluation window closes.
+	// A To less than From wraps past midnight, and a To equal to From spans the whole day.
+	To int `json:"to"`
+
+	// Zone is the IANA time zone of the days, hours and dates of this schedule, e.g. "America/New_York"; UTC when empty.
+	Zone string `json:"zone,omitempty"`
+
+	// Start is the first date, as YYYY-MM-DD, on which the rule is evaluated.
+	Start string `json:"start,omitempty"`
+
+	// End is the last date, as YYYY-MM-DD, on which the rule is evaluated.
+	End string `json:"end,omitempty"`
+}
+
+func (s *Schedule) Validate() error {
+	if _, err := time.LoadLocation(s.Zone); err != nil {
+		return errors.New("Invalid Zone: [" + s.Zone + "]")
+	}
+	for _, d := range s.Days {
+		if _, ok := weekday(d); !ok {
+			return errors.New("Invalid Day: [" + d + "]")
+		}
+	}
+	if s.From < 0 || s.From > 23 || s.To < 0 || s.To > 24 {
+		return fmt.Errorf("Invalid Hours: [%d-%d], must be from 0-23 to 1-24", s.From, s.To)
+	}
+	for _, d := range []string{s.Start, s.End} {
+		if _, err := time.Parse(dateLayout, d); d != "" && err != nil {
+			return errors.New("Invalid Date: [" + d + "], must be YYYY-MM-DD")
+		}
+	}
+	if s.Start != "" && s.End != "" && s.End < s.Start {
+		return errors.New("Invalid Dates: [" + s.Start + " - " + s.End + "], start is after end")
+	}
+	return nil
+}
+
+// In reports whether the given instant falls within this schedule.
+func (s *Schedule) In(t time.Time) bool {
+
+	loc, err := time.LoadLocation(s.Zone)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+
+	date := t.Format(dateLayout)
+	if (s.Start != "" && date < s.Start) || (s.End != "" && date > s.End) {
+		return false
+	}
+
+	if len(s.Days) > 0 {
+		var ok bool
+		for _, d := range s.Days {
+			if w, _ := weekday(d); w == t.Weekday() {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	h := t.Hour()
+	switch {
+	case s.From == s.To:
+		return true
+	case s.From < s.To:
+		return h >= s.From && h < s.To
+	default:
+		return h >= s.From || h < s.To
+	}
+}
+
+func weekday(s string) (time.Weekday, bool) {
+	for w := time.Sunday; w <= time.Saturday; w++ {
+		if strings.EqualFold(s, w.String()) || strings.EqualFold(s, w.String()[:3]) {
+			return w, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,51 @@
+package rule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleIn(t *testing.T) {
+
+	// Saturday 2021-11-06 23:30 UTC is 19:30 in New York.
+	sat := time.Date(2021, 11, 6, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		s    Schedule
+		want bool
+	}{
+		{Schedule{}, true},
+		{Schedule{From: 18, To: 24, Zone: "America/New_York"}, true},
+		{Schedule{From: 9, To: 17}, false},
+		{Schedule{From: 22, To: 6, Zone: "America/New_York"}, false},
+		{Schedule{From: 22, To: 6}, true},
+		{Schedule{Days: []string{"saturday", "Sun"}}, true},
+		{Schedule{Days: []string{"Mon"}}, false},
+		{Schedule{Start: "2021-11-06", End: "2021-11-06", Zone: "America/New_York"}, true},
+		{Schedule{Start: "2021-11-07"}, false},
+		{Schedule{End: "2021-11-05"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.s.Validate(); err != nil {
+			t.Error(tt.s, err)
+		} else if got := tt.s.In(sat); got != tt.want {
+			t.Error(tt.s, " expected ", tt.want, " got ", got)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	for _, s := range []Schedule{
+		{Zone: "Mars/Olympus_Mons"},
+		{Days: []string{"Caturday"}},
+		{From: 24},
+		{To: 25},
+		{Start: "11/06/2021"},
+		{Start: "2021-11-07", End: "2021-11-06"},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Error("expected an error for ", s)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Aggregate reduces the daily values of a metric over a window to a single baseline.
+type Aggregate string
+
+const (
+	Avg Aggregate = "avg"
+	Sum Aggregate = "sum"
+	Min Aggregate = "min"
+	Max Aggregate = "max"
+)
+
+// Mode is what a Trend compares to its RHS.
+type Mode string
+
+const (
+	// AbsoluteMode compares the baseline itself, e.g. the max profit of the last 3 days < 0.
+	AbsoluteMode Mode = "absolute"
+
+	// DeltaMode compares today's value minus the baseline, e.g. spend today is 100 over the 7 day average.
+	DeltaMode Mode = "delta"
+
+	// PercentMode compares the percent change of today's value from the baseline, e.g. ROI fell 30% below the 7 day average.
+	PercentMode Mode = "percent"
+)
+
+// trendMetrics are the metrics retained per day, which trends may compare.
+var trendMetrics = map[LHS]bool{Spend: true, Revenue: true, Profit: true, ROI: true, Clicks: true, Impressions: true}
+
+// Trend is a condition comparing a metric against its own history.
+type Trend struct {
+	LHS       LHS       `json:"lhs"`
+	Aggregate Aggregate `json:"aggregate"`
+
+	// Days is the number of complete days before today aggregated into the baseline; 1 is yesterday.
+	Days int `json:"days"`
+
+	Mode Mode    `json:"mode"`
+	Op   Op      `json:"op"`
+	RHS  float64 `json:"rhs"`
+}
+
+func (t Trend) Validate() error {
+	if !trendMetrics[t.LHS] {
+		return errors.New("Invalid Trend LHS: [" + string(t.LHS) + "], must be SPEND, REVENUE, PROFIT, ROI, CLICKS or IMPRESSIONS")
+	}
+	switch t.Aggregate {
+	case Avg, Sum, Min, Max:
+	default:
+		return errors.New("Invalid Aggregate: [" + string(t.Aggregate) + "], must be avg, sum, min or max")
+	}
+	switch t.Mode {
+	case AbsoluteMode, DeltaMode, PercentMode:
+	default:
+		return errors.New("Invalid Mode: [" + string(t.Mode) + "], must be absolute, delta or percent")
+	}
+	if t.Days < 1 || t.Days > 90 {
+		return fmt.Errorf("Invalid Days: [%d], must be from 1 to 90", t.Days)
+	}
+	if err := t.Op.Validate(); err != nil || t.Op == Between {
+		return errors.New("Invalid Trend Op: [" + string(t.Op) + "]")
+	}
+	return nil
+}
+
+// Key returns the key of the baseline within trigger metrics, e.g. "ROI[avg_7d]".
+func (t Trend) Key() LHS {
+	return t.LHS + "[" + LHS(t.Aggregate) + "_" + LHS(strconv.Itoa(t.Days)) + "d]"
+}
+
+// Baseline aggregates the metric over the given days, most recent first, reporting false when any day is missing.
+func (t Trend) Baseline(days []Metrics) (float64, bool) {
+
+	if len(days) < t.Days {
+		return 0, false
+	}
+
+	var out float64
+	for i, m := range days[:t.Days] {
+		v, ok := m[t.LHS]
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case i == 0:
+			out = v
+		case t.Aggregate == Min:
+			out = math.Min(out, v)
+		case t.Aggregate == Max:
+			out = math.Max(out, v)
+		default:
+			out += v
+		}
+	}
+
+	if t.Aggregate == Avg {
+		out /= float64(t.Days)
+	}
+
+	return out, true
+}
+
+// Met reports whether today's metrics compare to the baseline of the given days as the trend requires,
+// and returns the baseline.
+func (t Trend) Met(today Metrics, days []Metrics) (bool, float64) {
+
+	base, ok := t.Baseline(days)
+	if !ok {
+		return false, 0
+	}
+
+	v := base
+	switch t.Mode {
+	case DeltaMode:
+		v = today[t.LHS] - base
+	case PercentMode:
+		if base == 0 {
+			return false, base
+		}
+		v = (today[t.LHS] - base) / math.Abs(base) * 100
+	}
+
+	return Condition{LHS: t.LHS, Op: t.Op, RHS: t.RHS}.Met(v), base
+}
@@ -0,0 +1,50 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestTrendMet(t *testing.T) {
+
+	day := func(roi, spend, profit float64) Metrics { return Metrics{ROI: roi, Spend: spend, Profit: profit} }
+	week := []Metrics{day(30, 40, -1), day(40, 50, -2), day(50, 60, -3), day(40, 50, 4), day(40, 50, 5), day(40, 50, 6), day(40, 50, 7)}
+	today := day(20, 100, -5)
+
+	tests := []struct {
+		name string
+		t    Trend
+		days []Metrics
+		want bool
+		base float64
+	}{
+		{"ROI dropped more than 30% vs the 7 day average", Trend{LHS: ROI, Aggregate: Avg, Days: 7, Mode: PercentMode, Op: LT, RHS: -30}, week, true, 40},
+		{"spend today exceeds yesterday by 2x", Trend{LHS: Spend, Aggregate: Sum, Days: 1, Mode: PercentMode, Op: GE, RHS: 100}, week, true, 40},
+		{"three consecutive days of negative profit", Trend{LHS: Profit, Aggregate: Max, Days: 3, Mode: AbsoluteMode, Op: LT, RHS: 0}, week, true, -1},
+		{"four consecutive days of negative profit", Trend{LHS: Profit, Aggregate: Max, Days: 4, Mode: AbsoluteMode, Op: LT, RHS: 0}, week, false, 4},
+		{"spend is 50 over the 3 day minimum", Trend{LHS: Spend, Aggregate: Min, Days: 3, Mode: DeltaMode, Op: GE, RHS: 60}, week, true, 40},
+		{"missing history", Trend{LHS: ROI, Aggregate: Avg, Days: 7, Mode: AbsoluteMode, Op: GT, RHS: 0}, week[:6], false, 0},
+		{"missing day", Trend{LHS: ROI, Aggregate: Avg, Days: 2, Mode: AbsoluteMode, Op: GT, RHS: 0}, []Metrics{week[0], nil}, false, 0},
+	}
+
+	for _, tt := range tests {
+		if err := tt.t.Validate(); err != nil {
+			t.Error(tt.name, err)
+		} else if got, base := tt.t.Met(today, tt.days); got != tt.want || base != tt.base {
+			t.Error(tt.name, " expected ", tt.want, tt.base, " got ", got, base)
+		}
+	}
+}
+
+func TestTrendValidate(t *testing.T) {
+	for _, tt := range []Trend{
+		{LHS: CPC, Aggregate: Avg, Days: 7, Mode: AbsoluteMode, Op: GT},
+		{LHS: ROI, Aggregate: "median", Days: 7, Mode: AbsoluteMode, Op: GT},
+		{LHS: ROI, Aggregate: Avg, Days: 0, Mode: AbsoluteMode, Op: GT},
+		{LHS: ROI, Aggregate: Avg, Days: 7, Mode: "ratio", Op: GT},
+		{LHS: ROI, Aggregate: Avg, Days: 7, Mode: AbsoluteMode, Op: Between},
+	} {
+		if err := tt.Validate(); err == nil {
+			t.Error("expected an error for ", tt)
+		}
+	}
+}
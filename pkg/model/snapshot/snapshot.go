@@ -0,0 +1,154 @@
+// Package snapshot models the daily history of campaign performance.
+package snapshot
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/util/nums"
+	"sort"
+	"time"
+)
+
+const (
+	Table        = "plumbus_fb_campaign_snapshot"
+	AccountIndex = "AccountID-Date-index"
+
+	// Layout is the format of snapshot dates, which are the days Facebook reports insights for,
+	// in the time zone of the ad account.
+	Layout = "2006-01-02"
+)
+
+// Schema describes the key attributes of the snapshot table and its index.
+var Schema = repo.Schema{
+	Table:        Table,
+	PartitionKey: "CampaignID",
+	SortKey:      "Date",
+	Indexes: []repo.Index{
+		{Name: AccountIndex, PartitionKey: "AccountID", SortKey: "Date"},
+	},
+}
+
+// Entity is the performance of a campaign on a single day as of its last refresh that day.
+type Entity struct {
+
+	// CampaignID is the partition key; It represents the campaign of this snapshot, and is empty for account totals.
+	CampaignID string `json:"campaign_id,omitempty"`
+
+	// Date is the sort key; the day of this snapshot as YYYY-MM-DD in the time zone of the ad account.
+	Date string `json:"date"`
+
+	// AccountID is the account which owns the campaign.
+	AccountID string `json:"account_id"`
+
+	// CampaignName is the name of the campaign at the time of the snapshot.
+	CampaignName string `json:"campaign_name,omitempty"`
+
+	// Stated is the status of the campaign at the time of the snapshot.
+	Stated campaign.Status `json:"status,omitempty"`
+
+	Spend       float64 `json:"spend"`
+	Revenue     float64 `json:"revenue"`
+	Profit      float64 `json:"profit"`
+	ROI         float64 `json:"roi"`
+	Clicks      float64 `json:"clicks"`
+	Impressions float64 `json:"impressions"`
+
+	// Refreshed is when the campaign was last refreshed that day.
+	Refreshed time.Time `json:"refreshed"`
+}
+
+// New returns the snapshot of a campaign refreshed at the given time.
+func New(c campaign.Entity, t time.Time) Entity {
+	return Entity{
+		CampaignID:   c.ID,
+		Date:         Today(c, t),
+		AccountID:    c.AccountID,
+		CampaignName: c.Named,
+		Stated:       c.Stated,
+		Spend:        c.Spent(),
+		Revenue:      c.Revenue,
+		Profit:       c.Profit,
+		ROI:          c.ROI,
+		Clicks:       nums.Float64(c.Clicks),
+		Impressions:  nums.Float64(c.Impressions),
+		Refreshed:    t.UTC(),
+	}
+}
+
+// Date returns the UTC day of the given time, for campaigns refreshed before Facebook reported the day of
+// their insights.
+func Date(t time.Time) string {
+	return t.UTC().Format(Layout)
+}
+
+// Today returns the day a campaign refreshed at the given time reports insights for: the day Facebook reported,
+// which is in the time zone of the ad account and may differ from the UTC day, or else the UTC day.
+func Today(c campaign.Entity, t time.Time) string {
+	if c.Day != "" {
+		return c.Day
+	}
+	return Date(t)
+}
+
+// Range parses optional from and to dates, defaulting to the 30 days through today.
+func Range(from, to string) (string, string, error) {
+	if to == "" {
+		to = Date(time.Now())
+	}
+	end, err := time.Parse(Layout, to)
+	if err != nil {
+		return "", "", errors.New("invalid to date " + to + ", must be YYYY-MM-DD")
+	}
+	if from == "" {
+		from = end.AddDate(0, 0, -29).Format(Layout)
+	} else if _, err = time.Parse(Layout, from); err != nil {
+		return "", "", errors.New("invalid from date " + from + ", must be YYYY-MM-DD")
+	}
+	if from > to {
+		return "", "", errors.New("from date " + from + " is after to date " + to)
+	}
+	return from, to, nil
+}
+
+// Totals sums the snapshots of many campaigns by date, in date order, recalculating ROI from the sums.
+func Totals(accountID string, ee []Entity) []Entity {
+
+	days := map[string]*Entity{}
+	for _, e := range ee {
+		d, ok := days[e.Date]
+		if !ok {
+			d = &Entity{Date: e.Date, AccountID: accountID}
+			days[e.Date] = d
+		}
+		d.Spend += e.Spend
+		d.Revenue += e.Revenue
+		d.Profit += e.Profit
+		d.Clicks += e.Clicks
+		d.Impressions += e.Impressions
+		if e.Refreshed.After(d.Refreshed) {
+			d.Refreshed = e.Refreshed
+		}
+	}
+
+	out := make([]Entity, 0, len(days))
+	for _, d := range days {
+		if d.Spend != 0 {
+			d.ROI = d.Profit / d.Spend * 100
+		}
+		out = append(out, *d)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+	return out
+}
+
+func (e Entity) WriteRequest() (out types.WriteRequest, err error) {
+	var item map[string]types.AttributeValue
+	if item, err = attributevalue.MarshalMap(&e); err == nil {
+		out.PutRequest = &types.PutRequest{Item: item}
+	}
+	return
+}
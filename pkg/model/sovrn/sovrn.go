@@ -3,10 +3,14 @@ package sovrn
 import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/pkg/repo"
 )
 
 var Table = "plumbus_fb_sovrn"
 
+// Schema describes the key attributes of the sovrn table.
+var Schema = repo.Schema{Table: Table, PartitionKey: "UTM"}
+
 type Entity struct {
 	UTM         string  `json:"UTM"`
 	Revenue     float64 `json:"Revenue"`
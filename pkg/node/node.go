@@ -0,0 +1,424 @@
+// Package node serves the nodes of an account below its campaigns, ad sets and ads, whose handlers only differ
+// by the Level of the nodes they serve.
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	faas "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/smithy-go/ptr"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"plumbus/pkg/api"
+	"plumbus/pkg/graph"
+	"plumbus/pkg/model/campaign"
+	"plumbus/pkg/model/fb"
+	"plumbus/pkg/repo"
+	"plumbus/pkg/sam"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node is a single ad set or ad.
+type Node interface {
+	SetRefreshed(t string)
+	SetFormat()
+	WriteRequest() (types.WriteRequest, error)
+}
+
+// List is a list of nodes, which payloads of the fb handler and items of the db decode into through a pointer.
+type List interface {
+	// Nodes returns pointers to the nodes of the list.
+	Nodes() []Node
+}
+
+// Filter is a query parameter which selects the nodes holding its value in an attribute.
+type Filter struct {
+	Param     string
+	Attribute string
+}
+
+// Level describes the nodes a handler serves.
+type Level struct {
+
+	// Name is the name of a node in messages, e.g. "ad set".
+	Name string
+
+	// Node is the node of the fb handler returning every node of an account with today's insights.
+	Node string
+
+	// Kind is the level of the nodes in change records, "adset" or "ad", see campaign.Change.
+	Kind string
+
+	// Table holds the nodes keyed by AccountID and ID.
+	Table string
+
+	// Budget tells whether the nodes have a daily budget of their own.
+	Budget bool
+
+	// Filters are the parameters a get may filter the nodes by.
+	Filters []Filter
+
+	// List returns an empty list for the nodes of this level to decode into.
+	List func() List
+}
+
+// Handle serves a request of the nodes handler, reading and writing the given store.
+func (l Level) Handle(ctx context.Context, db repo.Store, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	log.WithFields(log.Fields{"ctx": ctx, "req": req}).Info()
+	switch req.RequestContext.HTTP.Method {
+	case http.MethodOptions:
+		return api.K()
+	case http.MethodGet:
+		return l.get(ctx, db, req.QueryStringParameters)
+	case http.MethodPatch:
+		return l.patch(ctx, db, req.QueryStringParameters)
+	case http.MethodPut:
+		return l.put(ctx, db, req.QueryStringParameters["accountID"])
+	default:
+		return api.Nada()
+	}
+}
+
+// put gets every node of the given account with today's insights from the fb handler and writes them to the db.
+func (l Level) put(ctx context.Context, db repo.Store, accountID string) (events.APIGatewayV2HTTPResponse, error) {
+
+	if accountID == "" {
+		return api.Err(errors.New("request missing accountID"))
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"node": l.Node, "ID": accountID})
+
+	var err error
+	var out *faas.InvokeOutput
+	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
+		log.WithError(err).Error()
+		return api.Err(err)
+	} else if err = fb.Err(out); errors.Is(err, graph.ErrThrottled) {
+		return api.Throttled(err)
+	} else if err != nil {
+		return api.Err(err)
+	}
+
+	list := l.List()
+	if err = json.Unmarshal(out.Payload, list); err != nil {
+		log.WithError(err).Error()
+		return api.Err(err)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+
+	var rr []types.WriteRequest
+	for _, n := range list.Nodes() {
+		n.SetRefreshed(now)
+		if r, err := n.WriteRequest(); err != nil {
+			log.WithError(err).Error("while marshalling an ", l.Name)
+		} else {
+			rr = append(rr, r)
+		}
+	}
+
+	if err = db.BatchWrite(ctx, l.Table, rr); err != nil {
+		return api.Err(err)
+	}
+
+	for _, n := range list.Nodes() {
+		n.SetFormat()
+	}
+
+	return api.JSON(list)
+}
+
+// get returns the nodes of the given accountID from the db, either those of the given IDs (csv) or every node,
+// in pages given a limit, optionally only those matching the filters of the level. These are applied within the
+// query so that cursors stay consistent, which leaves pages holding fewer nodes than the limit.
+func (l Level) get(ctx context.Context, db repo.Store, params map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+
+	accountID, found := params["accountID"]
+	if !found {
+		return api.Err(errors.New("request missing accountID"))
+	}
+
+	size, err := api.Limit(params["limit"])
+	if err != nil {
+		return api.Err(err)
+	}
+
+	var next string
+	list := l.List()
+
+	if csv, ok := params["IDs"]; ok {
+		var items []map[string]types.AttributeValue
+		if items, err = l.batch(ctx, db, accountID, split(csv)); err == nil {
+			err = attributevalue.UnmarshalListOfMaps(l.filter(items, params), list)
+		}
+	} else if size > 0 {
+		next, err = repo.QueryPage(ctx, db, l.queryInput(accountID, params), size, params["cursor"], list)
+	} else {
+		err = l.query(ctx, db, accountID, params, list)
+	}
+
+	if err != nil {
+		return api.Err(err)
+	}
+
+	nn := list.Nodes()
+	if len(nn) == 0 && next == "" {
+		return api.Empty()
+	}
+
+	for _, n := range nn {
+		n.SetFormat()
+	}
+
+	if size > 0 {
+		return api.Page(list, next)
+	}
+
+	return api.JSON(list)
+}
+
+// patch modifies the status of a single node given an ID, of the given IDs (csv) or of every node of an account,
+// or given a budget parameter in minor currency units, the daily budget of a single node of a level with budgets;
+// the optional ruleID parameter identifies the rule making the change. The fb nodes for campaigns update any node
+// by its ID.
+func (l Level) patch(ctx context.Context, db repo.Store, params map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+
+	var err error
+	status := campaign.Status(params["status"])
+	accountID, ID, ruleID := params["accountID"], params["ID"], params["ruleID"]
+
+	if budget, ok := params["budget"]; ok {
+		if !l.Budget {
+			return api.Err(fmt.Errorf("%ss have no budget of their own, see their ad set or campaign", l.Name))
+		} else if ID == "" {
+			return api.Err(fmt.Errorf("budget requires an %s ID", l.Name))
+		} else if n, err := strconv.ParseInt(budget, 10, 64); err != nil || n <= 0 {
+			return api.Err(errors.New("budget must be a positive integer of minor currency units"))
+		}
+		err = l.update(ctx, db, accountID, ID, ruleID, map[string]interface{}{"node": "budget", "ID": ID, "daily_budget": budget},
+			"set DailyBudget = :v1", budget, campaign.Change{DailyBudget: budget})
+	} else if err = status.Validate(); err != nil {
+		return api.Err(err)
+	} else if ID != "" {
+		err = l.update(ctx, db, accountID, ID, ruleID, map[string]interface{}{"node": "campaign", "ID": ID, "status": status},
+			"set Stated = :v1", status.String(), campaign.Change{Stated: status})
+	} else {
+		return l.patchAll(ctx, db, accountID, status, ruleID, params["IDs"])
+	}
+
+	if errors.Is(err, graph.ErrThrottled) {
+		return api.Throttled(err)
+	} else if err != nil {
+		return api.Err(err)
+	}
+
+	return api.K()
+}
+
+// patchAll modifies the status of the given nodes (csv), or of every node of an account, through a single batch
+// and persists each change fb confirmed, returning the outcome of each.
+func (l Level) patchAll(ctx context.Context, db repo.Store, accountID string, status campaign.Status, ruleID, csv string) (events.APIGatewayV2HTTPResponse, error) {
+
+	var ss []fb.Status
+	if csv != "" {
+		for _, ID := range split(csv) {
+			ss = append(ss, fb.Status{ID: ID, Status: status})
+		}
+	} else {
+		var keys []struct{ ID string }
+		if err := l.query(ctx, db, accountID, nil, &keys); err != nil {
+			return api.Err(err)
+		}
+		for _, k := range keys {
+			ss = append(ss, fb.Status{ID: k.ID, Status: status})
+		}
+	}
+
+	if len(ss) == 0 {
+		return api.JSON([]fb.Status{})
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"node": "campaigns_status", "campaigns": ss})
+
+	var err error
+	var out *faas.InvokeOutput
+	if out, err = sam.NewReqRes(ctx, fb.Handler, data); err != nil {
+		return api.Err(err)
+	} else if err = fb.Err(out); errors.Is(err, graph.ErrThrottled) {
+		return api.Throttled(err)
+	} else if err != nil {
+		return api.Err(err)
+	} else if err = json.Unmarshal(out.Payload, &ss); err != nil {
+		return api.Err(err)
+	}
+
+	for i, s := range ss {
+		if s.Error == "" {
+			if err = l.persist(ctx, db, accountID, s.ID, ruleID, "set Stated = :v1", s.Status.String(), campaign.Change{Stated: s.Status}); err != nil {
+				ss[i].Error = err.Error()
+			}
+		}
+	}
+
+	return api.JSON(ss)
+}
+
+// update sends the given request to the fb handler and only once fb confirmed it, persists the change.
+func (l Level) update(ctx context.Context, db repo.Store, accountID, ID, ruleID string, req map[string]interface{}, expr, value string, change campaign.Change) error {
+
+	data, _ := json.Marshal(req)
+
+	out, err := sam.NewReqRes(ctx, fb.Handler, data)
+	if err != nil {
+		log.WithError(err).Error()
+		return err
+	} else if err = fb.Err(out); err != nil {
+		log.WithError(err).Error()
+		return err
+	}
+
+	var res graph.Result
+	if err = json.Unmarshal(out.Payload, &res); err != nil || !res.Success {
+		return fmt.Errorf("facebook did not confirm the update of %s %s: %s", l.Name, ID, out.Payload)
+	}
+
+	return l.persist(ctx, db, accountID, ID, ruleID, expr, value, change)
+}
+
+// persist applies the update expression to a node in the db and records the change in the campaign change table,
+// which keys the changes of every level by node ID, so that rules can honor their cooldowns.
+func (l Level) persist(ctx context.Context, db repo.Store, accountID, ID, ruleID, expr, value string, change campaign.Change) (err error) {
+
+	in := &dynamodb.UpdateItemInput{
+		TableName: ptr.String(l.Table),
+		Key: map[string]types.AttributeValue{
+			"AccountID": &types.AttributeValueMemberS{Value: accountID},
+			"ID":        &types.AttributeValueMemberS{Value: ID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: value},
+		},
+		UpdateExpression: ptr.String(expr),
+	}
+
+	if _, err = db.Update(ctx, in); err != nil {
+		log.WithError(err).Error()
+		return
+	}
+
+	change.CampaignID, change.Level, change.AccountID, change.RuleID, change.Changed = ID, l.Kind, accountID, ruleID, time.Now()
+
+	var item map[string]types.AttributeValue
+	if item, err = attributevalue.MarshalMap(&change); err != nil {
+		log.WithError(err).Error()
+	} else if err = db.Put(ctx, &dynamodb.PutItemInput{TableName: ptr.String(campaign.ChangeTable), Item: item}); err != nil {
+		log.WithError(err).Error()
+	}
+
+	return
+}
+
+// batch returns the items of the nodes of an account with the given ids from the db.
+func (l Level) batch(ctx context.Context, db repo.Store, accountID string, ids []string) (items []map[string]types.AttributeValue, err error) {
+
+	if len(ids) == 0 {
+		return
+	}
+
+	var keys []map[string]types.AttributeValue
+	for _, id := range ids {
+		keys = append(keys, map[string]types.AttributeValue{
+			"AccountID": &types.AttributeValueMemberS{Value: accountID},
+			"ID":        &types.AttributeValueMemberS{Value: id},
+		})
+	}
+
+	in := &dynamodb.BatchGetItemInput{RequestItems: map[string]types.KeysAndAttributes{l.Table: {Keys: keys}}}
+
+	var out *dynamodb.BatchGetItemOutput
+	if out, err = db.BatchGet(ctx, in); err != nil {
+		log.WithError(err).Error()
+	} else {
+		items = out.Responses[l.Table]
+	}
+
+	return
+}
+
+// filter returns the items matching the filters of the level given in params, for batches which cannot filter
+// within the db.
+func (l Level) filter(items []map[string]types.AttributeValue, params map[string]string) []map[string]types.AttributeValue {
+
+	var out []map[string]types.AttributeValue
+	for _, item := range items {
+		ok := true
+		for _, f := range l.Filters {
+			if v := params[f.Param]; v != "" {
+				s, _ := item[f.Attribute].(*types.AttributeValueMemberS)
+				ok = ok && s != nil && s.Value == v
+			}
+		}
+		if ok {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+// query decodes every node of an account matching the filters of the level given in params from the db into v.
+func (l Level) query(ctx context.Context, db repo.Store, accountID string, params map[string]string, v interface{}) (err error) {
+
+	var out *dynamodb.QueryOutput
+	if out, err = db.Query(ctx, l.queryInput(accountID, params)); err != nil {
+		log.WithError(err).Error()
+	} else if err = attributevalue.UnmarshalListOfMaps(out.Items, v); err != nil {
+		log.WithError(err).Error()
+	}
+
+	return
+}
+
+// queryInput returns the input for querying every node owned by the given account, filtered by the filters of the
+// level given in params.
+func (l Level) queryInput(accountID string, params map[string]string) *dynamodb.QueryInput {
+
+	in := &dynamodb.QueryInput{
+		TableName:              ptr.String(l.Table),
+		KeyConditionExpression: ptr.String("AccountID = :v1"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: accountID},
+		},
+	}
+
+	var ff []string
+	for i, f := range l.Filters {
+		if v := params[f.Param]; v != "" {
+			k := fmt.Sprint(":v", i+2)
+			ff = append(ff, f.Attribute+" = "+k)
+			in.ExpressionAttributeValues[k] = &types.AttributeValueMemberS{Value: v}
+		}
+	}
+	if len(ff) > 0 {
+		in.FilterExpression = ptr.String(strings.Join(ff, " AND "))
+	}
+
+	return in
+}
+
+func split(csv string) (ids []string) {
+	for _, ID := range strings.Split(csv, ",") {
+		if ID = strings.TrimSpace(ID); ID != "" {
+			ids = append(ids, ID)
+		}
+	}
+	return
+}
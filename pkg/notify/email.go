@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Sender is a transport for email, which keeps the email Notifier independent of any one provider.
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+type email struct {
+	sender Sender
+}
+
+// NewEmail returns a Notifier which emails messages through the given sender.
+func NewEmail(s Sender) Notifier {
+	return &email{sender: s}
+}
+
+func (e *email) Notify(ctx context.Context, to string, m Message) error {
+	return e.sender.Send(ctx, to, m.Subject, m.Text)
+}
+
+// smtpSender sends plain text email through an SMTP relay.
+type smtpSender struct {
+	addr, from, user, pass string
+}
+
+// NewSMTP returns a Sender configured by the smtp_addr (host:port), smtp_from, smtp_user and smtp_pass variables.
+func NewSMTP() Sender {
+	return &smtpSender{
+		addr: os.Getenv("smtp_addr"),
+		from: os.Getenv("smtp_from"),
+		user: os.Getenv("smtp_user"),
+		pass: os.Getenv("smtp_pass"),
+	}
+}
+
+func (s *smtpSender) Send(_ context.Context, to, subject, body string) error {
+
+	if s.addr == "" || s.from == "" {
+		return errors.New("email is not configured, missing smtp_addr or smtp_from")
+	}
+
+	var auth smtp.Auth
+	if s.user != "" {
+		host, _, _ := net.SplitHostPort(s.addr)
+		auth = smtp.PlainAuth("", s.user, s.pass, host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + s.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(s.addr, auth, s.from, []string{to}, []byte(msg))
+}
@@ -0,0 +1,68 @@
+// Package notify delivers messages to alerting channels such as Slack compatible webhooks and email.
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Kind identifies the transport of a Channel.
+type Kind string
+
+const (
+	Webhook Kind = "webhook"
+	Email   Kind = "email"
+)
+
+// Channel is a destination for messages; Target is a URL for webhooks and an address for email.
+type Channel struct {
+	Kind   Kind   `json:"kind"`
+	Target string `json:"target"`
+}
+
+func (c Channel) Validate() error {
+	if c.Kind != Webhook && c.Kind != Email {
+		return errors.New("Invalid Channel Kind: [" + string(c.Kind) + "], must be webhook or email")
+	} else if c.Target == "" {
+		return errors.New("Invalid Channel: missing target for " + string(c.Kind))
+	}
+	return nil
+}
+
+// Message is a titled plain text message.
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// Notifier delivers a message to a target of a single kind of channel.
+type Notifier interface {
+	Notify(ctx context.Context, target string, m Message) error
+}
+
+var (
+	mu        sync.RWMutex
+	notifiers = map[Kind]Notifier{
+		Webhook: NewWebhook(),
+		Email:   NewEmail(NewSMTP()),
+	}
+)
+
+// Use replaces the notifier of a kind of channel, which lets tests stand in for webhooks and email.
+func Use(k Kind, n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifiers[k] = n
+}
+
+// Send delivers a message to the given channel.
+func Send(ctx context.Context, c Channel, m Message) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	mu.RLock()
+	n := notifiers[c.Kind]
+	mu.RUnlock()
+	return n.Notify(ctx, c.Target, m)
+}
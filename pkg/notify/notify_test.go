@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	c := Channel{Kind: Webhook, Target: srv.URL}
+	if err := Send(context.TODO(), c, Message{Subject: "digest", Text: "2 campaigns"}); err != nil {
+		t.Fatal(err)
+	} else if got["text"] != "*digest*\n2 campaigns" {
+		t.Error("unexpected payload ", got)
+	}
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer fail.Close()
+
+	if err := Send(context.TODO(), Channel{Kind: Webhook, Target: fail.URL}, Message{}); err == nil || !strings.Contains(err.Error(), "invalid_token") {
+		t.Error("expected the webhook error, got ", err)
+	}
+}
+
+func TestSendStubAndValidate(t *testing.T) {
+
+	s := &Stub{}
+	Use(Email, s)
+	defer Use(Email, NewEmail(NewSMTP()))
+
+	if err := Send(context.TODO(), Channel{Kind: Email, Target: "buyer@example.com"}, Message{Subject: "s"}); err != nil {
+		t.Fatal(err)
+	} else if len(s.Sent["buyer@example.com"]) != 1 {
+		t.Error("expected the stub to record the message ", s.Sent)
+	}
+
+	for _, c := range []Channel{{Kind: "pager", Target: "x"}, {Kind: Email}} {
+		if err := Send(context.TODO(), c, Message{}); err == nil {
+			t.Error("expected an error for ", c)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Stub is a Notifier which records messages instead of delivering them.
+type Stub struct {
+	mu   sync.Mutex
+	Sent map[string][]Message
+}
+
+func (s *Stub) Notify(_ context.Context, target string, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Sent == nil {
+		s.Sent = map[string][]Message{}
+	}
+	s.Sent[target] = append(s.Sent[target], m)
+	return nil
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// webhook posts messages as Slack compatible JSON.
+type webhook struct {
+	client *http.Client
+}
+
+// NewWebhook returns a Notifier posting {"text": ...} to the target URL, as Slack incoming webhooks expect.
+func NewWebhook() Notifier {
+	return &webhook{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *webhook) Notify(ctx context.Context, url string, m Message) error {
+
+	text := m.Text
+	if m.Subject != "" {
+		text = "*" + m.Subject + "*\n" + text
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("webhook responded %d: %s", res.StatusCode, data)
+	}
+
+	return nil
+}
@@ -0,0 +1,267 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// options configure how the dynamo Store submits batch requests.
+type options struct {
+	workers  int
+	attempts int
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+var defaultOptions = options{
+	workers:  1,
+	attempts: 8,
+	delay:    50 * time.Millisecond,
+	maxDelay: 5 * time.Second,
+}
+
+// Option customizes the dynamo Store.
+type Option func(*options)
+
+// WithWorkers submits up to n batch chunks concurrently; the default of 1 submits chunks in order.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithRetry resubmits unprocessed items and keys up to attempts times in total,
+// sleeping a jittered exponential backoff starting at delay and capped at max between attempts.
+func WithRetry(attempts int, delay, max time.Duration) Option {
+	return func(o *options) {
+		if attempts > 0 {
+			o.attempts = attempts
+		}
+		o.delay, o.maxDelay = delay, max
+	}
+}
+
+// BatchError lists, by table, the keys a batch operation never processed even after retries.
+// For put requests the whole item is listed as it contains the key.
+type BatchError struct {
+	Keys map[string][]map[string]types.AttributeValue
+	Err  error
+}
+
+func (e *BatchError) Error() string {
+	var n int
+	var tables []string
+	for t, kk := range e.Keys {
+		n += len(kk)
+		tables = append(tables, t)
+	}
+	msg := fmt.Sprintf("%d keys unprocessed for %s", n, strings.Join(tables, ", "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BatchError) add(table string, kk ...map[string]types.AttributeValue) {
+	if e.Keys == nil {
+		e.Keys = map[string][]map[string]types.AttributeValue{}
+	}
+	e.Keys[table] = append(e.Keys[table], kk...)
+}
+
+// backoff returns a full jitter exponential delay for the given zero based attempt.
+func (o options) backoff(attempt int) time.Duration {
+	d := o.delay << attempt
+	if d <= 0 || d > o.maxDelay {
+		d = o.maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// run calls fn for every chunk index using at most the configured number of workers.
+func (o options) run(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.workers)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (d *dynamo) BatchWrite(ctx context.Context, table string, rr []types.WriteRequest) error {
+
+	var mu sync.Mutex
+	var be BatchError
+
+	chunks := chunkWriteRequests(rr)
+	d.opts.run(len(chunks), func(i int) {
+		failed, err := d.batchWrite(ctx, table, chunks[i])
+		if len(failed) == 0 {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range failed {
+			if r.PutRequest != nil {
+				be.add(table, r.PutRequest.Item)
+			} else if r.DeleteRequest != nil {
+				be.add(table, r.DeleteRequest.Key)
+			}
+		}
+		if err != nil {
+			be.Err = err
+		}
+	})
+
+	if be.Keys != nil {
+		return &be
+	}
+	return nil
+}
+
+// batchWrite submits a single chunk, resubmitting unprocessed items until none remain or attempts run out.
+func (d *dynamo) batchWrite(ctx context.Context, table string, rr []types.WriteRequest) ([]types.WriteRequest, error) {
+
+	for attempt := 0; len(rr) > 0; attempt++ {
+
+		if attempt == d.opts.attempts {
+			return rr, fmt.Errorf("gave up after %d attempts", attempt)
+		}
+
+		if attempt > 0 {
+			log.Trace("resubmitting ", len(rr), " unprocessed items for ", table, " attempt ", attempt+1)
+			if err := sleep(ctx, d.opts.backoff(attempt-1)); err != nil {
+				return rr, err
+			}
+		}
+
+		out, err := d.db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{table: rr},
+		})
+		if err != nil {
+			return rr, err
+		}
+
+		rr = out.UnprocessedItems[table]
+	}
+
+	return nil, nil
+}
+
+func (d *dynamo) BatchGet(ctx context.Context, in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+
+	type chunk struct {
+		table string
+		keys  types.KeysAndAttributes
+	}
+
+	var chunks []chunk
+	for table, ka := range in.RequestItems {
+		for i := 0; i < len(ka.Keys); i += maxKeysSize {
+			end := i + maxKeysSize
+			if end > len(ka.Keys) {
+				end = len(ka.Keys)
+			}
+			c := chunk{table: table, keys: ka}
+			c.keys.Keys = ka.Keys[i:end]
+			chunks = append(chunks, c)
+		}
+	}
+
+	var mu sync.Mutex
+	var be BatchError
+	out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}}
+
+	d.opts.run(len(chunks), func(i int) {
+		c := chunks[i]
+		items, failed, err := d.batchGet(ctx, c.table, c.keys, in.ReturnConsumedCapacity)
+		mu.Lock()
+		defer mu.Unlock()
+		out.Responses[c.table] = append(out.Responses[c.table], items...)
+		if len(failed) > 0 {
+			be.add(c.table, failed...)
+		}
+		if err != nil {
+			be.Err = err
+		}
+	})
+
+	if be.Keys != nil {
+		return out, &be
+	}
+	return out, nil
+}
+
+// batchGet requests a single chunk of keys, re-requesting unprocessed keys until none remain or attempts run out.
+func (d *dynamo) batchGet(ctx context.Context, table string, ka types.KeysAndAttributes, rcc types.ReturnConsumedCapacity) (items, failed []map[string]types.AttributeValue, err error) {
+
+	for attempt := 0; len(ka.Keys) > 0; attempt++ {
+
+		if attempt == d.opts.attempts {
+			return items, ka.Keys, fmt.Errorf("gave up after %d attempts", attempt)
+		}
+
+		if attempt > 0 {
+			log.Trace("re-requesting ", len(ka.Keys), " unprocessed keys for ", table, " attempt ", attempt+1)
+			if err = sleep(ctx, d.opts.backoff(attempt-1)); err != nil {
+				return items, ka.Keys, err
+			}
+		}
+
+		var out *dynamodb.BatchGetItemOutput
+		if out, err = d.db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems:           map[string]types.KeysAndAttributes{table: ka},
+			ReturnConsumedCapacity: rcc,
+		}); err != nil {
+			return items, ka.Keys, err
+		}
+
+		items = append(items, out.Responses[table]...)
+		ka.Keys = out.UnprocessedKeys[table].Keys
+	}
+
+	return items, nil, nil
+}
+
+func chunkWriteRequests(in []types.WriteRequest) (out [][]types.WriteRequest) {
+	var end int
+	for i := 0; i < len(in); i += maxRequestSize {
+		if end = i + maxRequestSize; end > len(in) {
+			end = len(in)
+		}
+		out = append(out, in[i:end])
+	}
+	return
+}
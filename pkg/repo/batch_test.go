@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/test"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// throttled is a fake client which leaves the first key of every request unprocessed a number of times.
+type throttled struct {
+	client
+	mu       sync.Mutex
+	failures int
+	calls    int
+	sizes    []int
+}
+
+func (f *throttled) throttle() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		return true
+	}
+	return false
+}
+
+func (f *throttled) BatchWriteItem(_ context.Context, in *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	out := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}
+	for table, rr := range in.RequestItems {
+		f.mu.Lock()
+		f.sizes = append(f.sizes, len(rr))
+		f.mu.Unlock()
+		if f.throttle() {
+			out.UnprocessedItems[table] = rr[:1]
+		}
+	}
+	return out, nil
+}
+
+func (f *throttled) BatchGetItem(_ context.Context, in *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]types.AttributeValue{},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{},
+	}
+	for table, ka := range in.RequestItems {
+		f.mu.Lock()
+		f.sizes = append(f.sizes, len(ka.Keys))
+		f.mu.Unlock()
+		keys := ka.Keys
+		if f.throttle() {
+			out.UnprocessedKeys[table] = types.KeysAndAttributes{Keys: keys[:1]}
+			keys = keys[1:]
+		}
+		out.Responses[table] = append(out.Responses[table], keys...)
+	}
+	return out, nil
+}
+
+func testKeys(n int) (kk []map[string]types.AttributeValue) {
+	for i := 0; i < n; i++ {
+		kk = append(kk, map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: strconv.Itoa(i)}})
+	}
+	return
+}
+
+func TestBatchWriteRetries(t *testing.T) {
+
+	f := &throttled{failures: 2}
+	d := newDynamo(f, WithWorkers(3), WithRetry(3, 0, 0))
+
+	var rr []types.WriteRequest
+	for _, k := range testKeys(60) {
+		rr = append(rr, types.WriteRequest{PutRequest: &types.PutRequest{Item: k}})
+	}
+
+	if err := d.BatchWrite(test.CTX, "test", rr); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range f.sizes {
+		if n > maxRequestSize {
+			t.Error("chunk exceeds 25 write requests: ", n)
+		}
+	}
+
+	if f.calls != 5 {
+		t.Error("expected 3 chunks and 2 retries, got ", f.calls, " calls")
+	}
+}
+
+func TestBatchWriteGivesUp(t *testing.T) {
+
+	f := &throttled{failures: 10}
+	d := newDynamo(f, WithRetry(2, 0, 0))
+
+	rr := []types.WriteRequest{{PutRequest: &types.PutRequest{Item: testKeys(1)[0]}}}
+
+	var be *BatchError
+	if err := d.BatchWrite(test.CTX, "test", rr); !errors.As(err, &be) {
+		t.Fatal("expected a batch error, got ", err)
+	} else if len(be.Keys["test"]) != 1 {
+		t.Error("expected the unprocessed key to be reported, got ", be.Keys)
+	}
+}
+
+func TestBatchGetChunksAndRetries(t *testing.T) {
+
+	f := &throttled{failures: 1}
+	d := newDynamo(f, WithRetry(3, 0, 0))
+
+	out, err := d.BatchGet(test.CTX, &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{"test": {Keys: testKeys(250)}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if n := len(out.Responses["test"]); n != 250 {
+		t.Error("expected 250 items, got ", n)
+	}
+
+	for _, n := range f.sizes {
+		if n > maxKeysSize {
+			t.Error("chunk exceeds 100 keys: ", n)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	log "github.com/sirupsen/logrus"
+	"plumbus/pkg/util/logs"
+)
+
+// client is the subset of the DynamoDB client used by dynamo, which lets tests substitute a fake.
+type client interface {
+	GetItem(ctx context.Context, in *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, in *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context, in *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+}
+
+// dynamo is the DynamoDB backed Store used in production.
+type dynamo struct {
+	db   client
+	opts options
+}
+
+// NewDynamo returns a Store backed by a DynamoDB client built from the default AWS config.
+func NewDynamo(oo ...Option) Store {
+	logs.Init()
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.WithError(err).Fatal()
+	}
+	return newDynamo(dynamodb.NewFromConfig(cfg), oo...)
+}
+
+func newDynamo(db client, oo ...Option) *dynamo {
+	d := &dynamo{db: db, opts: defaultOptions}
+	for _, o := range oo {
+		o(&d.opts)
+	}
+	return d
+}
+
+func (d *dynamo) Get(ctx context.Context, table, key, val string, v interface{}) error {
+
+	input := &dynamodb.GetItemInput{
+		TableName: &table,
+		Key: map[string]types.AttributeValue{
+			key: &types.AttributeValueMemberS{
+				Value: val,
+			},
+		},
+	}
+
+	if output, err := d.db.GetItem(ctx, input); err != nil {
+		return err
+	} else if err = attributevalue.UnmarshalMap(output.Item, v); err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (d *dynamo) Delete(ctx context.Context, in *dynamodb.DeleteItemInput) (err error) {
+	_, err = d.db.DeleteItem(ctx, in)
+	return
+}
+
+func (d *dynamo) Scan(ctx context.Context, in *dynamodb.ScanInput, v interface{}) error {
+	var all []map[string]types.AttributeValue
+	if err := d.ScanPages(ctx, in, func(items []map[string]types.AttributeValue, _ map[string]types.AttributeValue) bool {
+		all = append(all, items...)
+		return true
+	}); err != nil {
+		return err
+	}
+	return attributevalue.UnmarshalListOfMaps(all, v)
+}
+
+func (d *dynamo) ScanPages(ctx context.Context, in *dynamodb.ScanInput, fn PageFunc) error {
+	params := *in
+	for {
+		out, err := d.db.Scan(ctx, &params)
+		if err != nil {
+			return err
+		}
+		if !fn(out.Items, out.LastEvaluatedKey) || len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		params.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func (d *dynamo) Put(ctx context.Context, input *dynamodb.PutItemInput) error {
+	_, err := d.db.PutItem(ctx, input)
+	return err
+}
+
+func (d *dynamo) Update(ctx context.Context, in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	return d.db.UpdateItem(ctx, in)
+}
+
+func (d *dynamo) Query(ctx context.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	all := &dynamodb.QueryOutput{}
+	if err := d.QueryPages(ctx, in, func(items []map[string]types.AttributeValue, _ map[string]types.AttributeValue) bool {
+		all.Items = append(all.Items, items...)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	all.Count = int32(len(all.Items))
+	return all, nil
+}
+
+func (d *dynamo) QueryPages(ctx context.Context, in *dynamodb.QueryInput, fn PageFunc) error {
+	params := *in
+	for {
+		out, err := d.db.Query(ctx, &params)
+		if err != nil {
+			return err
+		}
+		if !fn(out.Items, out.LastEvaluatedKey) || len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		params.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,544 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// item is a single DynamoDB row as held by the memory Store.
+type item = map[string]types.AttributeValue
+
+// predicate reports whether an item satisfies a condition, key condition or filter expression.
+type predicate func(item) bool
+
+// operand resolves a value from an item, either an attribute path or an expression attribute value.
+type operand func(item) types.AttributeValue
+
+// token kinds produced by lex.
+const (
+	tEOF = iota
+	tName
+	tValue
+	tOp
+	tLParen
+	tRParen
+	tComma
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// lex splits a DynamoDB expression into tokens.
+func lex(s string) (tt []token, err error) {
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tt = append(tt, token{tLParen, "("})
+			i++
+		case c == ')':
+			tt = append(tt, token{tRParen, ")"})
+			i++
+		case c == ',':
+			tt = append(tt, token{tComma, ","})
+			i++
+		case c == '=' || c == '+' || c == '-':
+			tt = append(tt, token{tOp, string(c)})
+			i++
+		case c == '<' || c == '>':
+			if i+1 < len(s) && (s[i+1] == '=' || (c == '<' && s[i+1] == '>')) {
+				tt = append(tt, token{tOp, s[i : i+2]})
+				i += 2
+			} else {
+				tt = append(tt, token{tOp, string(c)})
+				i++
+			}
+		case c == ':' || c == '#' || c == '_' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || s[j] == '#' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			if c == ':' {
+				tt = append(tt, token{tValue, s[i:j]})
+			} else {
+				tt = append(tt, token{tName, s[i:j]})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in expression %q", c, s)
+		}
+	}
+	return append(tt, token{kind: tEOF}), nil
+}
+
+// parser is a recursive descent parser for the subset of DynamoDB expressions used by this project.
+type parser struct {
+	tt     []token
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newParser(s string, names map[string]string, values map[string]types.AttributeValue) (*parser, error) {
+	tt, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tt: tt, names: names, values: values}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tt[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tt[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tName && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind int, text string) error {
+	if t := p.next(); t.kind != kind {
+		return fmt.Errorf("expected %s but found %q", text, t.text)
+	}
+	return nil
+}
+
+// parseCondition compiles a condition expression into a predicate; an empty expression matches everything.
+func parseCondition(s string, names map[string]string, values map[string]types.AttributeValue) (predicate, error) {
+	if strings.TrimSpace(s) == "" {
+		return func(item) bool { return true }, nil
+	}
+	p, err := newParser(s, names, values)
+	if err != nil {
+		return nil, err
+	}
+	var f predicate
+	if f, err = p.or(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tEOF {
+		return nil, fmt.Errorf("unexpected %q in expression %q", t.text, s)
+	}
+	return f, nil
+}
+
+func (p *parser) or() (predicate, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		var r predicate
+		if r, err = p.and(); err != nil {
+			return nil, err
+		}
+		l = func(x, y predicate) predicate { return func(i item) bool { return x(i) || y(i) } }(l, r)
+	}
+	return l, nil
+}
+
+func (p *parser) and() (predicate, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		var r predicate
+		if r, err = p.not(); err != nil {
+			return nil, err
+		}
+		l = func(x, y predicate) predicate { return func(i item) bool { return x(i) && y(i) } }(l, r)
+	}
+	return l, nil
+}
+
+func (p *parser) not() (predicate, error) {
+	if p.keyword("NOT") {
+		f, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(i item) bool { return !f(i) }, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (predicate, error) {
+
+	if p.peek().kind == tLParen {
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(tRParen, ")")
+	}
+
+	if t := p.peek(); t.kind == tName && p.tt[p.pos+1].kind == tLParen {
+		return p.function()
+	}
+
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("BETWEEN") {
+		var lo, hi operand
+		if lo, err = p.operand(); err != nil {
+			return nil, err
+		} else if !p.keyword("AND") {
+			return nil, errors.New("expected AND in BETWEEN")
+		} else if hi, err = p.operand(); err != nil {
+			return nil, err
+		}
+		return func(i item) bool {
+			a, b, c := l(i), lo(i), hi(i)
+			x, ok1 := compare(a, b)
+			y, ok2 := compare(a, c)
+			return ok1 && ok2 && x >= 0 && y <= 0
+		}, nil
+	}
+
+	if p.keyword("IN") {
+		if err = p.expect(tLParen, "("); err != nil {
+			return nil, err
+		}
+		var rr []operand
+		for {
+			var r operand
+			if r, err = p.operand(); err != nil {
+				return nil, err
+			}
+			rr = append(rr, r)
+			if p.peek().kind != tComma {
+				break
+			}
+			p.next()
+		}
+		if err = p.expect(tRParen, ")"); err != nil {
+			return nil, err
+		}
+		return func(i item) bool {
+			a := l(i)
+			for _, r := range rr {
+				if equal(a, r(i)) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	op := p.next()
+	if op.kind != tOp {
+		return nil, fmt.Errorf("expected comparator but found %q", op.text)
+	}
+
+	var r operand
+	if r, err = p.operand(); err != nil {
+		return nil, err
+	}
+
+	switch op.text {
+	case "=":
+		return func(i item) bool { return equal(l(i), r(i)) }, nil
+	case "<>":
+		return func(i item) bool { return !equal(l(i), r(i)) }, nil
+	case "<", "<=", ">", ">=":
+		return func(i item) bool {
+			c, ok := compare(l(i), r(i))
+			if !ok {
+				return false
+			}
+			switch op.text {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			default:
+				return c >= 0
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported comparator %q", op.text)
+	}
+}
+
+func (p *parser) function() (predicate, error) {
+
+	name := strings.ToLower(p.next().text)
+	p.next()
+
+	var args []operand
+	for p.peek().kind != tRParen {
+		a, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		if p.peek().kind == tComma {
+			p.next()
+		}
+	}
+	p.next()
+
+	switch {
+	case name == "attribute_exists" && len(args) == 1:
+		return func(i item) bool { return args[0](i) != nil }, nil
+	case name == "attribute_not_exists" && len(args) == 1:
+		return func(i item) bool { return args[0](i) == nil }, nil
+	case name == "begins_with" && len(args) == 2:
+		return func(i item) bool {
+			a, ok1 := args[0](i).(*types.AttributeValueMemberS)
+			b, ok2 := args[1](i).(*types.AttributeValueMemberS)
+			return ok1 && ok2 && strings.HasPrefix(a.Value, b.Value)
+		}, nil
+	case name == "contains" && len(args) == 2:
+		return func(i item) bool {
+			switch a := args[0](i).(type) {
+			case *types.AttributeValueMemberS:
+				b, ok := args[1](i).(*types.AttributeValueMemberS)
+				return ok && strings.Contains(a.Value, b.Value)
+			case *types.AttributeValueMemberSS:
+				b, ok := args[1](i).(*types.AttributeValueMemberS)
+				for _, s := range a.Value {
+					if ok && s == b.Value {
+						return true
+					}
+				}
+			case *types.AttributeValueMemberL:
+				for _, v := range a.Value {
+					if equal(v, args[1](i)) {
+						return true
+					}
+				}
+			}
+			return false
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported function %s with %d arguments", name, len(args))
+	}
+}
+
+func (p *parser) operand() (operand, error) {
+	switch t := p.next(); t.kind {
+	case tValue:
+		v, ok := p.values[t.text]
+		if !ok {
+			return nil, fmt.Errorf("missing expression attribute value %s", t.text)
+		}
+		return func(item) types.AttributeValue { return v }, nil
+	case tName:
+		path, err := p.path(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return func(i item) types.AttributeValue { return lookup(i, path) }, nil
+	default:
+		return nil, fmt.Errorf("expected operand but found %q", t.text)
+	}
+}
+
+// path resolves expression attribute names in a dotted document path.
+func (p *parser) path(s string) ([]string, error) {
+	parts := strings.Split(s, ".")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "#") {
+			name, ok := p.names[part]
+			if !ok {
+				return nil, fmt.Errorf("missing expression attribute name %s", part)
+			}
+			parts[i] = name
+		}
+	}
+	return parts, nil
+}
+
+// lookup returns the attribute at path within the item, or nil when absent.
+func lookup(i item, path []string) types.AttributeValue {
+	var v types.AttributeValue = &types.AttributeValueMemberM{Value: i}
+	for _, part := range path {
+		m, ok := v.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil
+		}
+		if v, ok = m.Value[part]; !ok {
+			return nil
+		}
+	}
+	return v
+}
+
+// equal reports whether two scalar attribute values are the same.
+func equal(a, b types.AttributeValue) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch x := a.(type) {
+	case *types.AttributeValueMemberBOOL:
+		y, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && x.Value == y.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	}
+	c, ok := compare(a, b)
+	return ok && c == 0
+}
+
+// compare orders two string or number attribute values; ok is false when they are not comparable.
+func compare(a, b types.AttributeValue) (c int, ok bool) {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		if y, is := b.(*types.AttributeValueMemberS); is {
+			return strings.Compare(x.Value, y.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if y, is := b.(*types.AttributeValueMemberN); is {
+			f, err1 := strconv.ParseFloat(x.Value, 64)
+			g, err2 := strconv.ParseFloat(y.Value, 64)
+			if err1 != nil || err2 != nil {
+				return 0, false
+			} else if f < g {
+				return -1, true
+			} else if f > g {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// assignment is a single SET or REMOVE action of an update expression.
+type assignment struct {
+	path   []string
+	value  func(item) (types.AttributeValue, error)
+	remove bool
+}
+
+// parseUpdate compiles the SET and REMOVE clauses of an update expression.
+func parseUpdate(s string, names map[string]string, values map[string]types.AttributeValue) (aa []assignment, err error) {
+
+	var p *parser
+	if p, err = newParser(s, names, values); err != nil {
+		return
+	}
+
+	var clause string
+	for p.peek().kind != tEOF {
+
+		if t := p.peek(); t.kind == tName && (strings.EqualFold(t.text, "SET") || strings.EqualFold(t.text, "REMOVE")) {
+			clause = strings.ToUpper(p.next().text)
+		} else if t.kind == tComma {
+			p.next()
+		} else if clause == "" {
+			return nil, fmt.Errorf("unsupported update expression %q", s)
+		}
+
+		t := p.next()
+		if t.kind != tName {
+			return nil, fmt.Errorf("expected attribute but found %q", t.text)
+		}
+
+		var path []string
+		if path, err = p.path(t.text); err != nil {
+			return
+		}
+
+		if clause == "REMOVE" {
+			aa = append(aa, assignment{path: path, remove: true})
+			continue
+		}
+
+		if err = p.expect(tOp, "="); err != nil {
+			return
+		}
+
+		var a assignment
+		if a, err = p.setValue(path); err != nil {
+			return
+		}
+		aa = append(aa, a)
+	}
+
+	return
+}
+
+// setValue parses the right hand side of a SET action: an operand, if_not_exists, or a numeric sum or difference.
+func (p *parser) setValue(path []string) (a assignment, err error) {
+
+	var l operand
+	if t := p.peek(); t.kind == tName && strings.EqualFold(t.text, "if_not_exists") {
+		p.next()
+		var x, y operand
+		if err = p.expect(tLParen, "("); err != nil {
+			return
+		} else if x, err = p.operand(); err != nil {
+			return
+		} else if err = p.expect(tComma, ","); err != nil {
+			return
+		} else if y, err = p.operand(); err != nil {
+			return
+		} else if err = p.expect(tRParen, ")"); err != nil {
+			return
+		}
+		l = func(i item) types.AttributeValue {
+			if v := x(i); v != nil {
+				return v
+			}
+			return y(i)
+		}
+	} else if l, err = p.operand(); err != nil {
+		return
+	}
+
+	a.path = path
+	if t := p.peek(); t.kind != tOp || (t.text != "+" && t.text != "-") {
+		a.value = func(i item) (types.AttributeValue, error) { return l(i), nil }
+		return
+	}
+
+	sign := p.next().text
+	var r operand
+	if r, err = p.operand(); err != nil {
+		return
+	}
+
+	a.value = func(i item) (types.AttributeValue, error) {
+		x, ok1 := l(i).(*types.AttributeValueMemberN)
+		y, ok2 := r(i).(*types.AttributeValueMemberN)
+		if !ok1 || !ok2 {
+			return nil, errors.New("arithmetic requires number operands")
+		}
+		f, _ := strconv.ParseFloat(x.Value, 64)
+		g, _ := strconv.ParseFloat(y.Value, 64)
+		if sign == "-" {
+			g = -g
+		}
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(f+g, 'f', -1, 64)}, nil
+	}
+	return
+}
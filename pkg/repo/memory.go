@@ -0,0 +1,452 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
+	"sync"
+)
+
+// defaultKey is the partition key assumed for tables without a registered Schema.
+const defaultKey = "ID"
+
+// memory is a Store holding every table in process memory; it exists so handlers can be tested offline.
+type memory struct {
+	mu      sync.RWMutex
+	schemas map[string]Schema
+	tables  map[string]map[string]item
+}
+
+// NewMemory returns an empty in-memory Store which identifies items by the given table schemas.
+// Tables without a schema are keyed by an "ID" partition key.
+func NewMemory(ss ...Schema) Store {
+	m := &memory{schemas: map[string]Schema{}, tables: map[string]map[string]item{}}
+	for _, s := range ss {
+		m.schemas[s.Table] = s
+	}
+	return m
+}
+
+func (m *memory) schema(table string) Schema {
+	if s, ok := m.schemas[table]; ok {
+		return s
+	}
+	return Schema{Table: table, PartitionKey: defaultKey}
+}
+
+// key returns the identity of an item within its table, erring when a key attribute is missing.
+func (m *memory) key(table string, i item) (string, error) {
+	s := m.schema(table)
+	pk, ok := i[s.PartitionKey]
+	if !ok {
+		return "", fmt.Errorf("missing partition key %s for table %s", s.PartitionKey, table)
+	}
+	k := fmt.Sprintf("%T:%v", pk, scalar(pk))
+	if s.SortKey == "" {
+		return k, nil
+	}
+	sk, ok := i[s.SortKey]
+	if !ok {
+		return "", fmt.Errorf("missing sort key %s for table %s", s.SortKey, table)
+	}
+	return k + "|" + fmt.Sprintf("%T:%v", sk, scalar(sk)), nil
+}
+
+func scalar(v types.AttributeValue) interface{} {
+	switch x := v.(type) {
+	case *types.AttributeValueMemberS:
+		return x.Value
+	case *types.AttributeValueMemberN:
+		return x.Value
+	case *types.AttributeValueMemberB:
+		return string(x.Value)
+	default:
+		return v
+	}
+}
+
+func (m *memory) table(name string) map[string]item {
+	t, ok := m.tables[name]
+	if !ok {
+		t = map[string]item{}
+		m.tables[name] = t
+	}
+	return t
+}
+
+// items returns a copy of every item in a table ordered by the given partition and sort keys.
+func (m *memory) items(table, pk, sk string) (ii []item) {
+	for _, i := range m.tables[table] {
+		ii = append(ii, clone(i))
+	}
+	sortItems(ii, pk, sk)
+	return
+}
+
+// sortItems orders items by the given partition and sort keys.
+func sortItems(ii []item, pk, sk string) {
+	sort.SliceStable(ii, func(x, y int) bool {
+		return order(ii[x], ii[y], []string{pk, sk}) < 0
+	})
+}
+
+// order compares two items by each of the given key attributes in turn.
+func order(x, y item, attrs []string) int {
+	for _, a := range attrs {
+		if c, _ := compare(x[a], y[a]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func clone(i item) item {
+	if i == nil {
+		return nil
+	}
+	o := make(item, len(i))
+	for k, v := range i {
+		o[k] = v
+	}
+	return o
+}
+
+// check evaluates a condition expression against the current item, if any.
+func check(cond *string, names map[string]string, values map[string]types.AttributeValue, i item) error {
+	if cond == nil {
+		return nil
+	}
+	f, err := parseCondition(*cond, names, values)
+	if err != nil {
+		return err
+	}
+	if i == nil {
+		i = item{}
+	}
+	if !f(i) {
+		return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	return nil
+}
+
+func (m *memory) Get(_ context.Context, table, key, val string, v interface{}) error {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := m.schema(table)
+	if s.PartitionKey != key || s.SortKey != "" {
+		return fmt.Errorf("key %s does not match the schema of table %s", key, table)
+	}
+
+	k, _ := m.key(table, item{key: &types.AttributeValueMemberS{Value: val}})
+	return attributevalue.UnmarshalMap(clone(m.tables[table][k]), v)
+}
+
+func (m *memory) Put(_ context.Context, in *dynamodb.PutItemInput) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := aws.ToString(in.TableName)
+	k, err := m.key(table, in.Item)
+	if err != nil {
+		return err
+	}
+
+	t := m.table(table)
+	if err = check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, t[k]); err != nil {
+		return err
+	}
+
+	t[k] = clone(in.Item)
+	return nil
+}
+
+func (m *memory) Scan(ctx context.Context, in *dynamodb.ScanInput, v interface{}) error {
+	var all []item
+	if err := m.ScanPages(ctx, in, func(items []item, _ item) bool {
+		all = append(all, items...)
+		return true
+	}); err != nil {
+		return err
+	}
+	return attributevalue.UnmarshalListOfMaps(all, v)
+}
+
+func (m *memory) ScanPages(_ context.Context, in *dynamodb.ScanInput, fn PageFunc) error {
+
+	table := aws.ToString(in.TableName)
+	f, err := parseCondition(aws.ToString(in.FilterExpression), in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	s := m.schema(table)
+	ii := m.items(table, s.PartitionKey, s.SortKey)
+	m.mu.RUnlock()
+
+	pages(ii, keyAttributes(s, s.PartitionKey, s.SortKey), in.ExclusiveStartKey, false, in.Limit, f, fn)
+	return nil
+}
+
+func (m *memory) Query(ctx context.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	out := &dynamodb.QueryOutput{}
+	if err := m.QueryPages(ctx, in, func(items []item, _ item) bool {
+		out.Items = append(out.Items, items...)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	out.Count = int32(len(out.Items))
+	return out, nil
+}
+
+func (m *memory) QueryPages(_ context.Context, in *dynamodb.QueryInput, fn PageFunc) error {
+
+	if in.KeyConditionExpression == nil {
+		return errors.New("query requires a key condition expression")
+	}
+
+	kc, err := parseCondition(*in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return err
+	}
+
+	var f predicate
+	if f, err = parseCondition(aws.ToString(in.FilterExpression), in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return err
+	}
+
+	table := aws.ToString(in.TableName)
+	m.mu.RLock()
+	s := m.schema(table)
+	all := m.items(table, s.PartitionKey, s.SortKey)
+	m.mu.RUnlock()
+
+	pk, sk := s.PartitionKey, s.SortKey
+	if in.IndexName != nil {
+		var found bool
+		for _, x := range s.Indexes {
+			if found = x.Name == *in.IndexName; found {
+				pk, sk = x.PartitionKey, x.SortKey
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("index %s not found for table %s", *in.IndexName, table)
+		}
+	}
+
+	sortItems(all, pk, sk)
+
+	var ii []item
+	for _, i := range all {
+		if kc(i) {
+			ii = append(ii, i)
+		}
+	}
+
+	desc := in.ScanIndexForward != nil && !*in.ScanIndexForward
+	if desc {
+		for x, y := 0, len(ii)-1; x < y; x, y = x+1, y-1 {
+			ii[x], ii[y] = ii[y], ii[x]
+		}
+	}
+
+	pages(ii, keyAttributes(s, pk, sk), in.ExclusiveStartKey, desc, in.Limit, f, fn)
+	return nil
+}
+
+// keyAttributes returns the attributes making up a LastEvaluatedKey for a table or index.
+func keyAttributes(s Schema, pk, sk string) (aa []string) {
+	for _, a := range []string{pk, sk, s.PartitionKey, s.SortKey} {
+		if a == "" {
+			continue
+		}
+		var dup bool
+		for _, b := range aa {
+			dup = dup || a == b
+		}
+		if !dup {
+			aa = append(aa, a)
+		}
+	}
+	return
+}
+
+// pages emulates DynamoDB pagination over items ordered by the key attributes, descending if desc: evaluation
+// resumes at the first item past the start key, whether or not an item still has that key, and stops after limit
+// items, where every evaluated item counts toward the limit whether or not it matches.
+func pages(ii []item, attrs []string, start item, desc bool, limit *int32, f predicate, fn PageFunc) {
+
+	if len(start) > 0 {
+		x := 0
+		for ; x < len(ii); x++ {
+			if c := order(ii[x], start, attrs); c > 0 && !desc || c < 0 && desc {
+				break
+			}
+		}
+		ii = ii[x:]
+	}
+
+	size := len(ii)
+	if limit != nil && *limit > 0 {
+		size = int(*limit)
+	}
+
+	for {
+		n := size
+		if n > len(ii) {
+			n = len(ii)
+		}
+
+		var page []item
+		for _, i := range ii[:n] {
+			if f(i) {
+				page = append(page, i)
+			}
+		}
+
+		var last item
+		if n < len(ii) && n > 0 {
+			last = item{}
+			for _, a := range attrs {
+				last[a] = ii[n-1][a]
+			}
+		}
+
+		if !fn(page, last) || last == nil {
+			return
+		}
+		ii = ii[n:]
+	}
+}
+
+func (m *memory) BatchWrite(_ context.Context, table string, rr []types.WriteRequest) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.table(table)
+	for _, r := range rr {
+		var i item
+		if r.PutRequest != nil {
+			i = r.PutRequest.Item
+		} else if r.DeleteRequest != nil {
+			i = r.DeleteRequest.Key
+		} else {
+			return errors.New("write request has neither a put nor a delete request")
+		}
+		k, err := m.key(table, i)
+		if err != nil {
+			return err
+		}
+		if r.PutRequest != nil {
+			t[k] = clone(i)
+		} else {
+			delete(t, k)
+		}
+	}
+
+	return nil
+}
+
+func (m *memory) BatchGet(_ context.Context, in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}}
+	for table, ka := range in.RequestItems {
+		for _, key := range ka.Keys {
+			k, err := m.key(table, key)
+			if err != nil {
+				return nil, err
+			}
+			if i, ok := m.tables[table][k]; ok {
+				out.Responses[table] = append(out.Responses[table], clone(i))
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (m *memory) Update(_ context.Context, in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := aws.ToString(in.TableName)
+	k, err := m.key(table, in.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	t := m.table(table)
+	if err = check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, t[k]); err != nil {
+		return nil, err
+	}
+
+	var aa []assignment
+	if aa, err = parseUpdate(aws.ToString(in.UpdateExpression), in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+
+	old := t[k]
+	i := clone(old)
+	if i == nil {
+		i = clone(in.Key)
+	}
+
+	for _, a := range aa {
+		if a.remove {
+			delete(i, a.path[0])
+			continue
+		}
+		var v types.AttributeValue
+		if v, err = a.value(old); err != nil {
+			return nil, err
+		}
+		i[a.path[0]] = v
+	}
+
+	t[k] = i
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch in.ReturnValues {
+	case types.ReturnValueAllNew:
+		out.Attributes = clone(i)
+	case types.ReturnValueAllOld:
+		out.Attributes = clone(old)
+	}
+
+	return out, nil
+}
+
+func (m *memory) Delete(_ context.Context, in *dynamodb.DeleteItemInput) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := aws.ToString(in.TableName)
+	k, err := m.key(table, in.Key)
+	if err != nil {
+		return err
+	}
+
+	t := m.table(table)
+	if err = check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, t[k]); err != nil {
+		return err
+	}
+
+	delete(t, k)
+	return nil
+}
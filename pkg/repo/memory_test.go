@@ -0,0 +1,185 @@
+package repo
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/test"
+	"strconv"
+	"testing"
+)
+
+var testSchema = Schema{Table: "test", PartitionKey: "AccountID", SortKey: "ID"}
+
+type testEntity struct {
+	AccountID string
+	ID        string
+	Spend     float64
+	Included  bool
+}
+
+func newTestMemory(t *testing.T) Store {
+	m := NewMemory(testSchema)
+	var rr []types.WriteRequest
+	for i := 1; i <= 5; i++ {
+		rr = append(rr, types.WriteRequest{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+			"AccountID": &types.AttributeValueMemberS{Value: "a"},
+			"ID":        &types.AttributeValueMemberS{Value: strconv.Itoa(i)},
+			"Spend":     &types.AttributeValueMemberN{Value: strconv.Itoa(i * 10)},
+			"Included":  &types.AttributeValueMemberBOOL{Value: i%2 == 0},
+		}}})
+	}
+	if err := m.BatchWrite(test.CTX, testSchema.Table, rr); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMemoryQuery(t *testing.T) {
+
+	m := newTestMemory(t)
+
+	out, err := m.Query(test.CTX, &dynamodb.QueryInput{
+		TableName:              aws.String(testSchema.Table),
+		KeyConditionExpression: aws.String("AccountID = :v1 AND #id BETWEEN :v2 AND :v3"),
+		FilterExpression:       aws.String("Spend > :v4 OR Included = :v5"),
+		ExpressionAttributeNames: map[string]string{
+			"#id": "ID",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: "a"},
+			":v2": &types.AttributeValueMemberS{Value: "2"},
+			":v3": &types.AttributeValueMemberS{Value: "4"},
+			":v4": &types.AttributeValueMemberN{Value: "35"},
+			":v5": &types.AttributeValueMemberBOOL{Value: true},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if out.Count != 2 {
+		t.Fatal("expected 2 items, got ", out.Count)
+	} else if id := out.Items[0]["ID"].(*types.AttributeValueMemberS).Value; id != "4" {
+		t.Error("expected descending order, got ", id)
+	}
+}
+
+func TestMemoryScan(t *testing.T) {
+
+	m := newTestMemory(t)
+
+	var ee []testEntity
+	err := m.Scan(test.CTX, &dynamodb.ScanInput{
+		TableName:        aws.String(testSchema.Table),
+		FilterExpression: aws.String("NOT Included = :v1 AND begins_with(ID, :v2)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberBOOL{Value: true},
+			":v2": &types.AttributeValueMemberS{Value: "3"},
+		},
+	}, &ee)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ee) != 1 || ee[0].ID != "3" || ee[0].Spend != 30 {
+		t.Error("unexpected scan result ", ee)
+	}
+}
+
+func TestMemoryPagesResumeAfterDeletedKey(t *testing.T) {
+
+	m := newTestMemory(t)
+
+	// first returns the IDs of the first page and the key to resume after it.
+	first := func(pages func(PageFunc) error) (ids []string, last map[string]types.AttributeValue) {
+		if err := pages(func(ii []map[string]types.AttributeValue, l map[string]types.AttributeValue) bool {
+			for _, i := range ii {
+				ids = append(ids, i["ID"].(*types.AttributeValueMemberS).Value)
+			}
+			last = l
+			return false
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// the item ending each first page is deleted before the next page is read.
+	del := func(key map[string]types.AttributeValue) {
+		if err := m.Delete(test.CTX, &dynamodb.DeleteItemInput{TableName: aws.String(testSchema.Table), Key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := &dynamodb.ScanInput{TableName: aws.String(testSchema.Table), Limit: aws.Int32(2)}
+	scanPages := func(fn PageFunc) error { return m.ScanPages(test.CTX, scan, fn) }
+	if ids, last := first(scanPages); len(ids) != 2 || ids[1] != "2" {
+		t.Fatal("unexpected first page ", ids)
+	} else {
+		del(last)
+		scan.ExclusiveStartKey = last
+	}
+	if ids, _ := first(scanPages); len(ids) != 2 || ids[0] != "3" || ids[1] != "4" {
+		t.Error("expected the scan to resume after the deleted key, got ", ids)
+	}
+
+	query := &dynamodb.QueryInput{
+		TableName:                 aws.String(testSchema.Table),
+		KeyConditionExpression:    aws.String("AccountID = :v1"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":v1": &types.AttributeValueMemberS{Value: "a"}},
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(1),
+	}
+	queryPages := func(fn PageFunc) error { return m.QueryPages(test.CTX, query, fn) }
+	if ids, last := first(queryPages); len(ids) != 1 || ids[0] != "5" {
+		t.Fatal("unexpected first page ", ids)
+	} else {
+		del(last)
+		query.ExclusiveStartKey = last
+	}
+	if ids, _ := first(queryPages); len(ids) != 1 || ids[0] != "4" {
+		t.Error("expected the descending query to resume after the deleted key, got ", ids)
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+
+	m := newTestMemory(t)
+	key := map[string]types.AttributeValue{
+		"AccountID": &types.AttributeValueMemberS{Value: "a"},
+		"ID":        &types.AttributeValueMemberS{Value: "1"},
+	}
+
+	out, err := m.Update(test.CTX, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(testSchema.Table),
+		Key:                 key,
+		UpdateExpression:    aws.String("set Spend = Spend + :v1, Included = :v2"),
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberN{Value: "5"},
+			":v2": &types.AttributeValueMemberBOOL{Value: true},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if spend := out.Attributes["Spend"].(*types.AttributeValueMemberN).Value; spend != "15" {
+		t.Error("expected spend of 15, got ", spend)
+	}
+
+	err = m.Delete(test.CTX, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(testSchema.Table),
+		Key:                 key,
+		ConditionExpression: aws.String("Included = :v1"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberBOOL{Value: false},
+		},
+	})
+
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		t.Error("expected a conditional check failure, got ", err)
+	}
+}
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ScanPage unmarshals a single page of a scan into v, evaluating at most limit items after the given cursor.
+// The returned cursor resumes after this page and is empty once the table is exhausted.
+func ScanPage(ctx context.Context, s Store, in *dynamodb.ScanInput, limit int32, cursor string, v interface{}) (next string, err error) {
+
+	params := *in
+	if params.ExclusiveStartKey, err = DecodeCursor(cursor); err != nil {
+		return
+	}
+	if limit > 0 {
+		params.Limit = &limit
+	}
+
+	var items []map[string]types.AttributeValue
+	var last map[string]types.AttributeValue
+	if err = s.ScanPages(ctx, &params, func(ii []map[string]types.AttributeValue, l map[string]types.AttributeValue) bool {
+		items, last = ii, l
+		return false
+	}); err != nil {
+		return
+	}
+
+	if err = attributevalue.UnmarshalListOfMaps(items, v); err != nil {
+		return
+	}
+
+	return EncodeCursor(last)
+}
+
+// QueryPage unmarshals a single page of a query into v, evaluating at most limit items after the given cursor.
+// The returned cursor resumes after this page and is empty once the results are exhausted.
+func QueryPage(ctx context.Context, s Store, in *dynamodb.QueryInput, limit int32, cursor string, v interface{}) (next string, err error) {
+
+	params := *in
+	if params.ExclusiveStartKey, err = DecodeCursor(cursor); err != nil {
+		return
+	}
+	if limit > 0 {
+		params.Limit = &limit
+	}
+
+	var items []map[string]types.AttributeValue
+	var last map[string]types.AttributeValue
+	if err = s.QueryPages(ctx, &params, func(ii []map[string]types.AttributeValue, l map[string]types.AttributeValue) bool {
+		items, last = ii, l
+		return false
+	}); err != nil {
+		return
+	}
+
+	if err = attributevalue.UnmarshalListOfMaps(items, v); err != nil {
+		return
+	}
+
+	return EncodeCursor(last)
+}
+
+// EncodeCursor converts a LastEvaluatedKey into an opaque token safe for query strings.
+// Key attributes are always strings, numbers or binary, so only those types are supported.
+func EncodeCursor(key map[string]types.AttributeValue) (string, error) {
+
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	m := map[string]map[string]string{}
+	for k, v := range key {
+		switch x := v.(type) {
+		case *types.AttributeValueMemberS:
+			m[k] = map[string]string{"S": x.Value}
+		case *types.AttributeValueMemberN:
+			m[k] = map[string]string{"N": x.Value}
+		case *types.AttributeValueMemberB:
+			m[k] = map[string]string{"B": base64.StdEncoding.EncodeToString(x.Value)}
+		default:
+			return "", errors.New("unsupported key attribute type for " + k)
+		}
+	}
+
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor converts a token produced by EncodeCursor back into an ExclusiveStartKey.
+// An empty token yields a nil key, meaning the first page.
+func DecodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var m map[string]map[string]string
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	key := map[string]types.AttributeValue{}
+	for k, v := range m {
+		if s, ok := v["S"]; ok {
+			key[k] = &types.AttributeValueMemberS{Value: s}
+		} else if n, ok := v["N"]; ok {
+			key[k] = &types.AttributeValueMemberN{Value: n}
+		} else if b, ok := v["B"]; ok {
+			var raw []byte
+			if raw, err = base64.StdEncoding.DecodeString(b); err != nil {
+				return nil, errors.New("invalid cursor")
+			}
+			key[k] = &types.AttributeValueMemberB{Value: raw}
+		} else {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"plumbus/test"
+	"testing"
+)
+
+func TestScanPage(t *testing.T) {
+
+	m := newTestMemory(t)
+	in := &dynamodb.ScanInput{TableName: aws.String(testSchema.Table)}
+
+	var ids []string
+	var cursor string
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		var ee []testEntity
+		var err error
+		if cursor, err = ScanPage(test.CTX, m, in, 2, cursor, &ee); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range ee {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	if len(ids) != 5 || ids[0] != "1" || ids[4] != "5" {
+		t.Error("unexpected ids ", ids)
+	}
+}
+
+func TestQueryPages(t *testing.T) {
+
+	m := newTestMemory(t)
+	in := &dynamodb.QueryInput{
+		TableName:              aws.String(testSchema.Table),
+		KeyConditionExpression: aws.String("AccountID = :v1"),
+		FilterExpression:       aws.String("Included = :v2"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v1": &types.AttributeValueMemberS{Value: "a"},
+			":v2": &types.AttributeValueMemberBOOL{Value: true},
+		},
+		Limit: aws.Int32(3),
+	}
+
+	var pages, items int
+	if err := m.QueryPages(test.CTX, in, func(ii []map[string]types.AttributeValue, _ map[string]types.AttributeValue) bool {
+		pages++
+		items += len(ii)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if pages != 2 || items != 2 {
+		t.Error("expected 2 matching items over 2 pages, got ", items, " over ", pages)
+	}
+
+	if out, err := m.Query(test.CTX, in); err != nil {
+		t.Fatal(err)
+	} else if out.Count != 2 {
+		t.Error("expected query to follow every page, got ", out.Count)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	if _, err := DecodeCursor("not a cursor"); err == nil {
+		t.Error("expected an invalid cursor error")
+	}
+}
@@ -4,101 +4,67 @@ package repo
 
 import (
 	"context"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	log "github.com/sirupsen/logrus"
-	"plumbus/pkg/util/logs"
 )
 
-const maxRequestSize = 25 // you can afford more than this jeff
+const (
+	maxRequestSize = 25  // you can afford more than this jeff
+	maxKeysSize    = 100 // per BatchGetItem request
+)
 
-var db *dynamodb.Client
+// Store is the set of db operations available to handlers.
+// Handlers receive a Store rather than a client so that tests can swap DynamoDB for memory.
+type Store interface {
 
-func init() {
-	logs.Init()
-	if cfg, err := config.LoadDefaultConfig(context.Background()); err != nil {
-		log.WithError(err).Fatal()
-	} else {
-		db = dynamodb.NewFromConfig(cfg)
-	}
-}
+	// Get unmarshals the item of the given table where the partition key equals val into v.
+	Get(ctx context.Context, table, key, val string, v interface{}) error
 
-func Get(ctx context.Context, table, key, val string, v interface{}) error {
-
-	input := &dynamodb.GetItemInput{
-		TableName: &table,
-		Key: map[string]types.AttributeValue{
-			key: &types.AttributeValueMemberS{
-				Value: val,
-			},
-		},
-	}
-
-	if output, err := db.GetItem(ctx, input); err != nil {
-		return err
-	} else if err = attributevalue.UnmarshalMap(output.Item, &v); err != nil {
-		return err
-	} else {
-		return nil
-	}
-}
+	// Put creates or replaces an item.
+	Put(ctx context.Context, in *dynamodb.PutItemInput) error
 
-func Delete(ctx context.Context, in *dynamodb.DeleteItemInput) (err error) {
-	_, err = db.DeleteItem(ctx, in)
-	return
-}
+	// Scan unmarshals every item of the scanned table matching the input filter into v,
+	// following LastEvaluatedKey until the table is exhausted.
+	Scan(ctx context.Context, in *dynamodb.ScanInput, v interface{}) error
 
-func Scan(ctx context.Context, in *dynamodb.ScanInput, v interface{}) error {
-	if out, err := db.Scan(ctx, in); err != nil {
-		return err
-	} else {
-		return attributevalue.UnmarshalListOfMaps(out.Items, &v)
-	}
-}
+	// ScanPages calls fn with each page of a scan until the table is exhausted or fn returns false.
+	ScanPages(ctx context.Context, in *dynamodb.ScanInput, fn PageFunc) error
 
-func Put(ctx context.Context, input *dynamodb.PutItemInput) error {
-	_, err := db.PutItem(ctx, input)
-	return err
-}
+	// Query returns every item of a table or index matching the input key condition and filter,
+	// following LastEvaluatedKey until the results are exhausted.
+	Query(ctx context.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
 
-func Update(ctx context.Context, in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
-	return db.UpdateItem(ctx, in)
-}
+	// QueryPages calls fn with each page of a query until the results are exhausted or fn returns false.
+	QueryPages(ctx context.Context, in *dynamodb.QueryInput, fn PageFunc) error
 
-func BatchWrite(ctx context.Context, table string, rr []types.WriteRequest) (err error) {
-
-	var in *dynamodb.BatchWriteItemInput
-	for _, r := range chunkWriteRequests(rr) {
-		in = &dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]types.WriteRequest{
-				table: r,
-			},
-		}
-		if _, err = db.BatchWriteItem(ctx, in); err != nil {
-			break
-		}
-	}
-
-	return
-}
+	// BatchWrite puts or deletes the given requests against a single table.
+	BatchWrite(ctx context.Context, table string, rr []types.WriteRequest) error
+
+	// BatchGet returns the items for the given keys, grouped by table.
+	BatchGet(ctx context.Context, in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
 
-func BatchGet(ctx context.Context, in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
-	return db.BatchGetItem(ctx, in)
+	// Update edits the attributes of an existing item, or creates it when absent.
+	Update(ctx context.Context, in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+
+	// Delete removes a single item by key.
+	Delete(ctx context.Context, in *dynamodb.DeleteItemInput) error
 }
 
-func Query(ctx context.Context, in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
-	return db.Query(ctx, in)
+// PageFunc receives the items of a single page and the key to resume after it, which is empty on the last page.
+// Returning false stops pagination.
+type PageFunc func(items []map[string]types.AttributeValue, last map[string]types.AttributeValue) bool
+
+// Schema describes the key attributes of a table; the memory Store needs it to identify items.
+type Schema struct {
+	Table        string
+	PartitionKey string
+	SortKey      string
+	Indexes      []Index
 }
 
-func chunkWriteRequests(in []types.WriteRequest) (out [][]types.WriteRequest) {
-	var end int
-	for i := 0; i < len(in); i += maxRequestSize {
-		if end = i + maxRequestSize; end > len(in) {
-			end = len(in)
-		}
-		out = append(out, in[i:end])
-	}
-	return
+// Index describes the key attributes of a global or local secondary index.
+type Index struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
 }
@@ -11,7 +11,17 @@ import (
 	"plumbus/pkg/util/logs"
 )
 
-var sam *faas.Client
+// Invoker is the subset of the Lambda client used to invoke other handlers.
+type Invoker interface {
+	Invoke(ctx context.Context, in *faas.InvokeInput, optFns ...func(*faas.Options)) (*faas.InvokeOutput, error)
+}
+
+var sam Invoker
+
+// Use replaces the Lambda client, which lets tests stand in for other handlers.
+func Use(i Invoker) {
+	sam = i
+}
 
 func init() {
 	logs.Init()
@@ -0,0 +1,90 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Store is an AWS service the Parameters and Secrets Lambda extension reads from.
+type Store int
+
+const (
+	SecretsManager Store = iota
+	ParameterStore
+)
+
+// Extension resolves secrets from AWS Secrets Manager or SSM Parameter Store through the AWS Parameters and Secrets
+// Lambda extension, which caches them locally and spares handlers the AWS SDK clients of either service.
+// Outside Lambda, point Endpoint at any server speaking the same protocol.
+type Extension struct {
+	Store    Store
+	Endpoint string
+	Token    string
+	HTTP     *http.Client
+}
+
+// NewExtension returns an Extension on the port of the PARAMETERS_SECRETS_EXTENSION_HTTP_PORT environment variable,
+// 2773 by default, authenticated by the AWS_SESSION_TOKEN of the function.
+func NewExtension(s Store) *Extension {
+	port := os.Getenv("PARAMETERS_SECRETS_EXTENSION_HTTP_PORT")
+	if port == "" {
+		port = "2773"
+	}
+	return &Extension{
+		Store:    s,
+		Endpoint: "http://localhost:" + port,
+		Token:    os.Getenv("AWS_SESSION_TOKEN"),
+		HTTP:     &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (e *Extension) Get(ctx context.Context, name string) (string, error) {
+
+	u := e.Endpoint + "/secretsmanager/get?" + url.Values{"secretId": {name}}.Encode()
+	if e.Store == ParameterStore {
+		u = e.Endpoint + "/systemsmanager/parameters/get?" + url.Values{"name": {name}, "withDecryption": {"true"}}.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Aws-Parameters-Secrets-Token", e.Token)
+
+	res, err := e.HTTP.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secret: %s: %w", name, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", err
+	} else if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusBadRequest {
+		return "", fmt.Errorf("%w: %s (status %d)", ErrNotFound, name, res.StatusCode)
+	} else if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secret: %s: status %d", name, res.StatusCode)
+	}
+
+	var v struct {
+		SecretString string `json:"SecretString"`
+		Parameter    struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err = json.Unmarshal(body, &v); err != nil {
+		return "", fmt.Errorf("secret: %s: %w", name, err)
+	}
+
+	if e.Store == ParameterStore {
+		return v.Parameter.Value, nil
+	}
+	return v.SecretString, nil
+}
@@ -0,0 +1,99 @@
+// Package secret resolves secrets, such as the Facebook token, from the environment, files, or AWS Secrets Manager
+// and SSM Parameter Store, so that handlers need not keep them in plain environment variables.
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by a Provider without a secret of the given name.
+var ErrNotFound = errors.New("secret: not found")
+
+// Provider resolves a secret by name.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Env resolves secrets from environment variables of the same name.
+type Env struct{}
+
+func (Env) Get(_ context.Context, name string) (string, error) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: environment variable %s", ErrNotFound, name)
+}
+
+// File resolves secrets from files named after them within a directory, such as a mounted secrets volume.
+type File struct {
+	Dir string
+}
+
+func (f File) Get(_ context.Context, name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) || name == ".." {
+		return "", fmt.Errorf("secret: invalid name %q", name)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(f.Dir, name))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: file %s in %s", ErrNotFound, name, f.Dir)
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Stub resolves secrets from memory; it stands in for the other providers in tests and local runs.
+type Stub map[string]string
+
+func (s Stub) Get(_ context.Context, name string) (string, error) {
+	if v, ok := s[name]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Cached resolves each secret once from the given provider and remembers it for the life of the process.
+func Cached(p Provider) Provider {
+	return &cached{p: p, values: map[string]string{}}
+}
+
+type cached struct {
+	p      Provider
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (c *cached) Get(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[name]; ok {
+		return v, nil
+	}
+	v, err := c.p.Get(ctx, name)
+	if err == nil {
+		c.values[name] = v
+	}
+	return v, err
+}
+
+// FromEnv returns the provider selected by the secret_provider environment variable: "env" (the default),
+// "file" reading the directory of secret_dir, "secretsmanager" or "ssm"; each cached for the life of the process.
+func FromEnv() Provider {
+	switch os.Getenv("secret_provider") {
+	case "file":
+		return Cached(File{Dir: os.Getenv("secret_dir")})
+	case "secretsmanager":
+		return Cached(NewExtension(SecretsManager))
+	case "ssm":
+		return Cached(NewExtension(ParameterStore))
+	default:
+		return Env{}
+	}
+}
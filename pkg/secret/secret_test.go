@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProviders(t *testing.T) {
+
+	ctx := context.TODO()
+
+	os.Setenv("plumbus_secret_test", "from env")
+	defer os.Unsetenv("plumbus_secret_test")
+	if v, err := (Env{}).Get(ctx, "plumbus_secret_test"); err != nil || v != "from env" {
+		t.Error(v, err)
+	} else if _, err = (Env{}).Get(ctx, "plumbus_secret_missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected a missing variable to be not found, got ", err)
+	}
+
+	dir := t.TempDir()
+	_ = ioutil.WriteFile(filepath.Join(dir, "tkn"), []byte("from file\n"), 0600)
+	if v, err := (File{Dir: dir}).Get(ctx, "tkn"); err != nil || v != "from file" {
+		t.Error(v, err)
+	} else if _, err = (File{Dir: dir}).Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected a missing file to be not found, got ", err)
+	} else if _, err = (File{Dir: dir}).Get(ctx, "../tkn"); err == nil {
+		t.Error("expected a path outside the directory to be rejected")
+	}
+
+	calls := 0
+	counted := providerFunc(func(ctx context.Context, name string) (string, error) {
+		calls++
+		return Stub{"tkn": "from stub"}.Get(ctx, name)
+	})
+	c := Cached(counted)
+	for i := 0; i < 3; i++ {
+		if v, err := c.Get(ctx, "tkn"); err != nil || v != "from stub" {
+			t.Error(v, err)
+		}
+	}
+	if calls != 1 {
+		t.Error("expected a single resolution, got ", calls)
+	}
+}
+
+type providerFunc func(ctx context.Context, name string) (string, error)
+
+func (f providerFunc) Get(ctx context.Context, name string) (string, error) { return f(ctx, name) }
+
+func TestExtension(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Aws-Parameters-Secrets-Token") != "session" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.URL.Path == "/secretsmanager/get" && r.URL.Query().Get("secretId") == "plumbus/tkn":
+			fmt.Fprint(w, `{"Name":"plumbus/tkn","SecretString":"from secrets manager"}`)
+		case r.URL.Path == "/systemsmanager/parameters/get" && r.URL.Query().Get("name") == "/plumbus/tkn" && r.URL.Query().Get("withDecryption") == "true":
+			fmt.Fprint(w, `{"Parameter":{"Name":"/plumbus/tkn","Value":"from ssm"}}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.TODO()
+	sm := &Extension{Store: SecretsManager, Endpoint: srv.URL, Token: "session", HTTP: srv.Client()}
+	ps := &Extension{Store: ParameterStore, Endpoint: srv.URL, Token: "session", HTTP: srv.Client()}
+
+	if v, err := sm.Get(ctx, "plumbus/tkn"); err != nil || v != "from secrets manager" {
+		t.Error(v, err)
+	} else if v, err = ps.Get(ctx, "/plumbus/tkn"); err != nil || v != "from ssm" {
+		t.Error(v, err)
+	} else if _, err = sm.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected a missing secret to be not found, got ", err)
+	}
+
+	sm.Token = "expired"
+	if _, err := sm.Get(ctx, "plumbus/tkn"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Error("expected a rejected session to fail, got ", err)
+	}
+}
@@ -20,6 +20,7 @@ func Init() {
 		FullTimestamp: false,
 		ForceColors:   false,
 	})
+	log.AddHook(redaction{})
 
 	isInit = true
 }
@@ -0,0 +1,48 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+)
+
+// secrets matches the values of tokens in URLs, JSON, formatted maps and Authorization headers.
+var secrets = regexp.MustCompile(`((?:access_token|appsecret_proof|input_token)"?\s*[=:]\s*"?|(?:OAuth|Bearer) )[^&\s"',)\]]+`)
+
+// Redact replaces the value of every token in s.
+func Redact(s string) string {
+	return secrets.ReplaceAllString(s, "${1}[REDACTED]")
+}
+
+// redaction is a hook which redacts tokens from the message and fields of every entry before it is written.
+type redaction struct{}
+
+func (redaction) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (redaction) Fire(e *log.Entry) error {
+	e.Message = Redact(e.Message)
+	data := make(log.Fields, len(e.Data))
+	for k, v := range e.Data {
+		switch t := v.(type) {
+		case string:
+			data[k] = Redact(t)
+		case error:
+			if s := t.Error(); secrets.MatchString(s) {
+				data[k] = errors.New(Redact(s))
+			} else {
+				data[k] = v
+			}
+		default:
+			if s := fmt.Sprintf("%+v", v); secrets.MatchString(s) {
+				data[k] = Redact(s)
+			} else {
+				data[k] = v
+			}
+		}
+	}
+	e.Data = data
+	return nil
+}
@@ -0,0 +1,40 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	for in, want := range map[string]string{
+		"GET /v12.0/me?access_token=EAAB123&fields=id": "GET /v12.0/me?access_token=[REDACTED]&fields=id",
+		`{"access_token": "EAAB123", "id": 1}`:         `{"access_token": "[REDACTED]", "id": 1}`,
+		"Authorization: OAuth EAAB123":                 "Authorization: OAuth [REDACTED]",
+		"debug_token?input_token=EAAB123":              "debug_token?input_token=[REDACTED]",
+		"appsecret_proof=abc123&access_token=EAAB123":  "appsecret_proof=[REDACTED]&access_token=[REDACTED]",
+		"nothing secret here":                          "nothing secret here",
+	} {
+		if got := Redact(in); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestHookRedactsEntries(t *testing.T) {
+
+	var buf bytes.Buffer
+	l := log.New()
+	l.SetOutput(&buf)
+	l.AddHook(redaction{})
+
+	err := errors.New(`Get "https://graph.facebook.com/v12.0/me?access_token=EAAB123": timeout`)
+	l.WithError(err).WithFields(log.Fields{"url": "/me?access_token=EAAB456", "req": map[string]string{"access_token": "EAAB789"}}).
+		Error("while calling /me?access_token=EAAB000")
+
+	if out := buf.String(); strings.Contains(out, "EAAB") || !strings.Contains(out, "[REDACTED]") {
+		t.Error("expected every token to be redacted ", out)
+	}
+}
@@ -0,0 +1,91 @@
+package nums
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Number is a number which decodes from JSON numbers and numeric strings alike, where null and empty
+// strings mean no number at all. It is stored in DynamoDB as an N attribute, or NULL when not Valid.
+type Number struct {
+	Float float64
+	Valid bool
+}
+
+// N returns a valid Number.
+func N(f float64) Number {
+	return Number{Float: f, Valid: true}
+}
+
+// ParseNumber parses a numeric string, the empty string being no number.
+func ParseNumber(s string) (Number, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return Number{}, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return Number{}, fmt.Errorf("nums: %q is not a number", s)
+	}
+	return N(f), nil
+}
+
+func (n Number) String() string {
+	if !n.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(n.Float, 'f', -1, 64)
+}
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return []byte(n.String()), nil
+}
+
+func (n *Number) UnmarshalJSON(b []byte) (err error) {
+	if b = bytes.TrimSpace(b); bytes.Equal(b, []byte("null")) {
+		*n = Number{}
+	} else if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err = json.Unmarshal(b, &s); err == nil {
+			*n, err = ParseNumber(s)
+		}
+	} else {
+		*n, err = ParseNumber(string(b))
+	}
+	return
+}
+
+// AttributeValue returns the N attribute of a valid Number, NULL otherwise.
+func (n Number) AttributeValue() types.AttributeValue {
+	if !n.Valid {
+		return &types.AttributeValueMemberNULL{Value: true}
+	}
+	return &types.AttributeValueMemberN{Value: n.String()}
+}
+
+func (n Number) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return n.AttributeValue(), nil
+}
+
+// UnmarshalDynamoDBAttributeValue reads N and NULL attributes, as well as the S attributes of items written
+// before numbers were stored as such, where a string which is not a number is no number rather than an error.
+func (n *Number) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) (err error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberN:
+		*n, err = ParseNumber(v.Value)
+	case *types.AttributeValueMemberS:
+		*n, _ = ParseNumber(v.Value)
+	case *types.AttributeValueMemberNULL:
+		*n = Number{}
+	default:
+		err = fmt.Errorf("nums: cannot read a number from %T", av)
+	}
+	return
+}
@@ -0,0 +1,53 @@
+package nums
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+)
+
+func TestNumberUnmarshalJSON(t *testing.T) {
+
+	var v struct {
+		A, B, C, D, E Number
+	}
+	if err := json.Unmarshal([]byte(`{"A":1.5,"B":"2.25","C":"","D":null,"E":" 0 "}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != N(1.5) || v.B != N(2.25) || v.C.Valid || v.D.Valid || v.E != N(0) {
+		t.Error("unexpected numbers ", v)
+	}
+
+	for _, s := range []string{`"n/a"`, `"NaN"`, `true`} {
+		var n Number
+		if err := json.Unmarshal([]byte(s), &n); err == nil {
+			t.Error("expected ", s, " to be rejected")
+		}
+	}
+
+	if b, _ := json.Marshal([]Number{N(3), {}}); string(b) != `[3,null]` {
+		t.Error("unexpected JSON ", string(b))
+	}
+}
+
+func TestNumberAttributeValue(t *testing.T) {
+
+	if n, ok := N(12.5).AttributeValue().(*types.AttributeValueMemberN); !ok || n.Value != "12.5" {
+		t.Error("expected an N attribute ", n)
+	}
+	if _, ok := (Number{}).AttributeValue().(*types.AttributeValueMemberNULL); !ok {
+		t.Error("expected a NULL attribute")
+	}
+
+	for av, want := range map[types.AttributeValue]Number{
+		&types.AttributeValueMemberN{Value: "7"}:     N(7),
+		&types.AttributeValueMemberS{Value: "7.5"}:   N(7.5),
+		&types.AttributeValueMemberS{Value: ""}:      {},
+		&types.AttributeValueMemberNULL{Value: true}: {},
+	} {
+		var n Number
+		if err := n.UnmarshalDynamoDBAttributeValue(av); err != nil || n != want {
+			t.Error("unexpected number ", n, " from ", av, err)
+		}
+	}
+}
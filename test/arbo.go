@@ -0,0 +1,121 @@
+package test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Arbotron stands in for the Arbotron site: it signs in User with Pass, serves the gzipped campaigns in Data
+// by client id to signed in sessions, after the Delay of the client if any, and redirects everyone else to
+// its login page. With Rotate, every campaign request renews the session cookie, which ends the former.
+type Arbotron struct {
+	User   string
+	Pass   string
+	Data   map[string]string
+	Delay  map[string]time.Duration
+	Rotate bool
+
+	mu        sync.Mutex
+	logins    int
+	rotations int
+	session   string
+	fetched   []string
+}
+
+// Fetched lists the campaign requests served so far as "<client id> <date range>".
+func (a *Arbotron) Fetched() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.fetched...)
+}
+
+// Logins is the number of successful sign ins so far.
+func (a *Arbotron) Logins() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.logins
+}
+
+// Expire ends the current session, as Arbotron does after a while.
+func (a *Arbotron) Expire() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.session = ""
+}
+
+func (a *Arbotron) signedIn(r *http.Request) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, err := r.Cookie("PHPSESSID")
+	return err == nil && a.session != "" && c.Value == a.session
+}
+
+func (a *Arbotron) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path == "/login.php" {
+		if r.Method == http.MethodPost && r.FormValue("email") == a.User && r.FormValue("password") == a.Pass {
+			a.mu.Lock()
+			a.logins++
+			a.session = fmt.Sprintf("s%d", a.logins)
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: a.session, Path: "/"})
+			a.mu.Unlock()
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		} else if r.Method == http.MethodPost {
+			http.Redirect(w, r, "/login.php", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		_, _ = w.Write([]byte(`<html><form method="post"><input name="email"><input type="password" name="password"></form></html>`))
+		return
+	}
+
+	if !a.signedIn(r) {
+		http.Redirect(w, r, "/login.php", http.StatusFound)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/":
+		if r.URL.Query().Get("cmd") == "switch_client" {
+			http.SetCookie(w, &http.Cookie{Name: "client", Value: r.URL.Query().Get("client_id"), Path: "/"})
+			http.Redirect(w, r, r.URL.Query().Get("redirect_url"), http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		_, _ = w.Write([]byte(`<html>dashboard</html>`))
+	case "/nac_handler.php":
+		if a.Rotate {
+			a.mu.Lock()
+			a.rotations++
+			a.session = fmt.Sprintf("s%d.%d", a.logins, a.rotations)
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: a.session, Path: "/"})
+			a.mu.Unlock()
+		}
+		var data string
+		if c, err := r.Cookie("client"); err == nil {
+			data = a.Data[c.Value]
+			a.mu.Lock()
+			a.fetched = append(a.fetched, c.Value+" "+r.URL.Query().Get("dr"))
+			a.mu.Unlock()
+			select {
+			case <-time.After(a.Delay[c.Value]):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if data == "" {
+			data = `{"data":[]}`
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(data))
+		_ = zw.Close()
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -1,5 +1,50 @@
 package test
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	faas "github.com/aws/aws-sdk-go-v2/service/lambda"
+)
 
 var CTX = context.TODO()
+
+// Lambdas stands in for the Lambda client of pkg/sam, calling the stub registered under the invoked function name.
+type Lambdas map[string]func(payload []byte) []byte
+
+func (l Lambdas) Invoke(_ context.Context, in *faas.InvokeInput, _ ...func(*faas.Options)) (*faas.InvokeOutput, error) {
+	var name string
+	if in.FunctionName != nil {
+		name = *in.FunctionName
+	}
+	if fn, ok := l[name]; !ok {
+		return nil, errors.New("no stub for function " + name)
+	} else {
+		out := &faas.InvokeOutput{StatusCode: 200, Payload: fn(in.Payload)}
+		var e struct {
+			Message string `json:"errorMessage"`
+			Type    string `json:"errorType"`
+		}
+		if json.Unmarshal(out.Payload, &e) == nil && e.Message != "" {
+			out.FunctionError = &e.Type
+		}
+		return out, nil
+	}
+}
+
+// Error is the payload of a function failing with err, as the Lambda runtime reports it.
+func Error(err error) []byte {
+	data, _ := json.Marshal(map[string]string{"errorMessage": err.Error(), "errorType": "errorString"})
+	return data
+}
+
+// API adapts an API Gateway handler stub into a Lambdas stub.
+func API(fn func(req events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse) func([]byte) []byte {
+	return func(payload []byte) []byte {
+		var req events.APIGatewayV2HTTPRequest
+		_ = json.Unmarshal(payload, &req)
+		res, _ := json.Marshal(fn(req))
+		return res
+	}
+}